@@ -0,0 +1,23 @@
+package main
+
+import (
+	m "pengoe/cmd/middlewares"
+	"pengoe/config"
+)
+
+/*
+csrfOptions reads the CSRF mode from the optional CSRF_MODE setting:
+"session" (default), "request" or "double-submit".
+*/
+func csrfOptions() (m.CSRFOptions, error) {
+	mode, err := m.ParseCSRFMode(config.Optional("CSRF_MODE", string(m.CSRFPerSession)))
+	if err != nil {
+		return m.CSRFOptions{}, err
+	}
+
+	return m.CSRFOptions{
+		Mode:   mode,
+		Secret: []byte(config.Env.JWT_SECRET),
+		Secure: config.Env.ENVIRONMENT == "production",
+	}, nil
+}
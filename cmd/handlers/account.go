@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"io"
 	"net/http"
 	"net/url"
 	"pengoe/internal/router"
@@ -14,6 +13,7 @@ import (
 	"pengoe/internal/utils"
 	"pengoe/web/templates/components"
 	"pengoe/web/templates/pages"
+	"strconv"
 	"time"
 
 	"github.com/a-h/templ"
@@ -25,35 +25,30 @@ AccountPage handles the GET request to /account/:id
 func AccountPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	token, found := r.Context().Value("token").(*t.Token)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use token middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use token middleware"))
 	}
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 	session, found := r.Context().Value("session").(*services.Session)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use session middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
 	}
 
 	accountId, found := p["id"]
 	if !found {
-		router.NotFound(w, r, p)
-		return errors.New("Path variable \"id\" not found")
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
 	}
 
-	accountService := services.NewAccountService(db)
-	accessService := services.NewAccessService(db)
-	eventService := services.NewEventService(db)
+	accountService := services.NewAccountService(r.Context(), db)
+	accessService := services.NewAccessService(r.Context(), db)
+	eventService := services.NewEventService(r.Context(), db)
 
 	// get account
 	account, err := accountService.GetById(accountId)
 	if err != nil {
-		router.NotFound(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusNotFound, "", err)
 	}
 
 	// check if the user has access to the account
@@ -66,15 +61,23 @@ func AccountPage(w http.ResponseWriter, r *http.Request, p map[string]string) er
 	// get accounts
 	accounts, err := accountService.GetByUserId(session.UserId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
-	// get events
-	events, err := eventService.GetByAccountId(accountId)
+	// get the first page of events
+	query := r.URL.Query()
+
+	filter, err := parseEventFilter(accountId, query)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	events, err := eventService.Search(filter)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	data := pages.AccountProps{
@@ -87,7 +90,21 @@ func AccountPage(w http.ResponseWriter, r *http.Request, p map[string]string) er
 		Description:          account.Description,
 		Currency:             account.Currency,
 		Token:                token,
-		Events:               events,
+		Filter: components.EventFilterFormProps{
+			AccountId:  account.Id,
+			Search:     filter.Search,
+			From:       utils.GetQueryParam(query, "from"),
+			To:         utils.GetQueryParam(query, "to"),
+			MinIncome:  utils.GetQueryParam(query, "min"),
+			MaxIncome:  utils.GetQueryParam(query, "max"),
+			Sort:       filter.Sort,
+			Descending: filter.Descending,
+		},
+		Events: components.EventListProps{
+			Currency: account.Currency,
+			Events:   events.Events,
+			NextUrl:  nextEventsUrl(accountId, query, events.NextCursor),
+		},
 	}
 
 	component := pages.Account(data)
@@ -101,89 +118,33 @@ func AccountPage(w http.ResponseWriter, r *http.Request, p map[string]string) er
 DeleteAccount handles the DELETE request to /account/:id
 */
 func DeleteAccount(w http.ResponseWriter, r *http.Request, p map[string]string) error {
-	token, found := r.Context().Value("token").(*t.Token)
-	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use token middleware")
-	}
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 	session, found := r.Context().Value("session").(*services.Session)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use session middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
 	}
 
 	accountId, found := p["id"]
 	if !found {
-		router.NotFound(w, r, p)
-		return errors.New("Path variable \"id\" not found")
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
 	}
 
-	accountService := services.NewAccountService(db)
-	accessService := services.NewAccessService(db)
+	accountService := services.NewAccountService(r.Context(), db)
+	accessService := services.NewAccessService(r.Context(), db)
 
 	// check if the user has access to the account
 	ok := accessService.Check(session.UserId, accountId)
 	if !ok {
-		return router.Unauthorized(w, r, p)
-	}
-
-	// manually parse body, (because DELETE request)
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		router.InternalError(w, r, p)
-		return err
-	}
-
-	formValues, err := url.ParseQuery(string(body))
-	if err != nil {
-		router.InternalError(w, r, p)
-		return err
-	}
-
-	formToken := html.EscapeString(formValues.Get("csrf"))
-
-	// check if the two tokens are the same
-	if token.Value != formToken {
-		router.Unauthorized(w, r, p)
-		return errors.New("CSRF token is invalid")
-	}
-
-	// check if the csrf token is expired
-	if token.Valid.Before(time.Now().UTC()) {
-		// csrf token is expired
-
-		// renew csrf token
-		newToken, err := t.Manager.RenewToken(session.Id)
-		if err != nil {
-			router.InternalError(w, r, p)
-			return err
-		}
-
-		data := components.CsrfProps{
-			Token: newToken,
-		}
-
-		w.Header().Set("HX-Trigger", "delete-account")
-
-		component := components.Csrf(data)
-		handler := templ.Handler(component)
-		handler.ServeHTTP(w, r)
-
-		return nil
+		return router.NewHTTPError(http.StatusUnauthorized, "", nil)
 	}
 
-	// token is not expired, all good
-
 	// delete account
-	err = accountService.Delete(accountId)
+	err := accountService.Delete(accountId)
 	if err != nil {
-		router.NotFound(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusNotFound, "", err)
 	}
 
 	// redirect to dashboard
@@ -203,22 +164,19 @@ func NewAccountPage(w http.ResponseWriter, r *http.Request, p map[string]string)
 	}
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 	session, found := r.Context().Value("session").(*services.Session)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use session middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
 	}
 
-	accountService := services.NewAccountService(db)
+	accountService := services.NewAccountService(r.Context(), db)
 
 	// get accounts
 	accounts, err := accountService.GetByUserId(session.UserId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	data := pages.NewAccountProps{
@@ -240,101 +198,205 @@ func NewAccountPage(w http.ResponseWriter, r *http.Request, p map[string]string)
 NewAccount handles the POST request to /account
 */
 func NewAccount(w http.ResponseWriter, r *http.Request, p map[string]string) error {
-	token, found := r.Context().Value("token").(*t.Token)
-	if !found {
-		router.RedirectToSignin(w, r, p)
-		return errors.New("Should use token middleware")
-	}
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 	session, found := r.Context().Value("session").(*services.Session)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use session middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
 	}
 
 	err := r.ParseForm()
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	form := r.Form
 
-	formToken := html.EscapeString(form.Get("csrf"))
-	if formToken == "" {
-		router.Unauthorized(w, r, p)
-		return errors.New("CSRF token is missing")
-	}
-
 	name := html.EscapeString(form.Get("name"))
 	if name == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Name is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Name is required", nil)
 	}
 
 	description := html.EscapeString(form.Get("description"))
 
 	currency := html.EscapeString(form.Get("currency"))
 	if currency == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Currency is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Currency is required", nil)
 	}
 
-	accountService := services.NewAccountService(db)
-	accessService := services.NewAccessService(db)
+	accountService := services.NewAccountService(r.Context(), db)
+	accessService := services.NewAccessService(r.Context(), db)
 
-	// check if the tokens match
-	if token.Value != formToken {
-		return router.Unauthorized(w, r, p)
-	}
+	// create new account
 
-	// token is expired
-	if token.Valid.Before(time.Now().UTC()) {
-		// renew csrf token
-		newToken, err := t.Manager.RenewToken(session.Id)
-		if err != nil {
-			router.InternalError(w, r, p)
-			return err
-		}
+	accountId := utils.NewUUID("acc")
 
-		data := components.CsrfProps{
-			Token: newToken,
-		}
+	err = accountService.New(accountId, name, description, currency)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// create new access as admin
+	accessId := utils.NewUUID("acs")
 
-		w.Header().Set("HX-Trigger", "new-account")
+	err = accessService.New(accessId, services.Admin, session.UserId, accountId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
 
-		component := components.Csrf(data)
-		handler := templ.Handler(component)
-		handler.ServeHTTP(w, r)
+	w.Header().Set("HX-Redirect", fmt.Sprintf("/account/%s", accountId))
+	return nil
+}
 
-		return nil
+/*
+AccountEvents handles the GET request to /account/:id/events.
+It renders the next page of the event list, for the infinite scroll.
+Query params are the same as on the account page, plus "cursor".
+*/
+func AccountEvents(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
 	}
 
-	// csrf token is not expired
+	accountId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
 
-	// create new account
+	accountService := services.NewAccountService(r.Context(), db)
+	accessService := services.NewAccessService(r.Context(), db)
+	eventService := services.NewEventService(r.Context(), db)
 
-	accountId := utils.NewUUID("acc")
+	// check if the user has access to the account
+	ok := accessService.Check(session.UserId, accountId)
+	if !ok {
+		return router.NewHTTPError(http.StatusUnauthorized, "", nil)
+	}
 
-	err = accountService.New(accountId, name, description, currency)
+	account, err := accountService.GetById(accountId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusNotFound, "", err)
 	}
 
-	// create new access as admin
-	accessId := utils.NewUUID("acs")
+	query := r.URL.Query()
 
-	err = accessService.New(accessId, services.Admin, session.UserId, accountId)
+	filter, err := parseEventFilter(accountId, query)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
 	}
 
-	w.Header().Set("HX-Redirect", fmt.Sprintf("/account/%s", accountId))
+	events, err := eventService.Search(filter)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	component := components.EventList(components.EventListProps{
+		Currency: account.Currency,
+		Events:   events.Events,
+		NextUrl:  nextEventsUrl(accountId, query, events.NextCursor),
+	})
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
 	return nil
 }
+
+/*
+parseEventFilter is a function that reads the event list filter from the query params:
+"q" (search), "from" and "to" (YYYY-MM-DD, both inclusive), "min" and "max" (income),
+"sort" (delivered_at, name, income or reserved), "dir" (asc or desc) and "cursor".
+The default order is the newest events first.
+*/
+func parseEventFilter(accountId string, query url.Values) (services.EventFilter, error) {
+	filter := services.EventFilter{
+		AccountId:  accountId,
+		Search:     utils.GetQueryParam(query, "q"),
+		Sort:       services.EventSortDeliveredAt,
+		Descending: true,
+		Cursor:     utils.GetQueryParam(query, "cursor"),
+	}
+
+	from := utils.GetQueryParam(query, "from")
+	if from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = date
+	}
+
+	to := utils.GetQueryParam(query, "to")
+	if to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, err
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+
+	min := utils.GetQueryParam(query, "min")
+	if min != "" {
+		value, err := strconv.Atoi(min)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinIncome = &value
+	}
+
+	max := utils.GetQueryParam(query, "max")
+	if max != "" {
+		value, err := strconv.Atoi(max)
+		if err != nil {
+			return filter, err
+		}
+		filter.MaxIncome = &value
+	}
+
+	sort := utils.GetQueryParam(query, "sort")
+	if sort != "" {
+		parsed, err := services.ParseEventSort(sort)
+		if err != nil {
+			return filter, err
+		}
+		filter.Sort = parsed
+	}
+
+	switch utils.GetQueryParam(query, "dir") {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, errors.New("Direction should be asc or desc")
+	}
+
+	return filter, nil
+}
+
+/*
+nextEventsUrl is a function that returns the url of the next page of the event list,
+with the same filter, or an empty string on the last page.
+*/
+func nextEventsUrl(accountId string, query url.Values, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	next := url.Values{}
+	for key, values := range query {
+		next[key] = values
+	}
+	next.Set("cursor", cursor)
+
+	return fmt.Sprintf("/account/%s/events?%s", accountId, next.Encode())
+}
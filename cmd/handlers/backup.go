@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"time"
+)
+
+/*
+maxBackupSize is the largest backup archive accepted by RestoreAccount.
+*/
+const maxBackupSize = 32 << 20
+
+/*
+BackupAccount handles the GET request to /account/:id/backup
+*/
+func BackupAccount(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
+
+	accessService := services.NewAccessService(r.Context(), db)
+	backupService := services.NewBackupService(r.Context(), db)
+
+	// check if the user has access to the account
+	ok := accessService.Check(session.UserId, accountId)
+	if !ok {
+		return router.NewHTTPError(http.StatusUnauthorized, "", nil)
+	}
+
+	archive, err := backupService.Export(accountId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	filename := fmt.Sprintf("pengoe-%s-%s.zip", accountId, time.Now().UTC().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(archive)
+
+	return nil
+}
+
+/*
+RestoreAccount handles the POST request to /account/restore
+*/
+func RestoreAccount(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBackupSize)
+
+	err := r.ParseMultipartForm(maxBackupSize)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	file, _, err := r.FormFile("backup")
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+	defer file.Close()
+
+	archive, err := io.ReadAll(file)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	backupService := services.NewBackupService(r.Context(), db)
+
+	accountId, err := backupService.Restore(session.UserId, archive)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	w.Header().Set("HX-Redirect", fmt.Sprintf("/account/%s", accountId))
+	return nil
+}
@@ -20,17 +20,15 @@ Sends icons.
 func CheckUser(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, dbFound := r.Context().Value("db").(*sql.DB)
 	if !dbFound {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
-	userService := services.NewUserService(db)
+	userService := services.NewUserService(r.Context(), db)
 
 	// Parse the form
 	parseErr := r.ParseForm()
 	if parseErr != nil {
-		router.InternalError(w, r, p)
-		return parseErr
+		return router.NewHTTPError(http.StatusInternalServerError, "", parseErr)
 	}
 
 	// Check if username is taken
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"pengoe/internal/router"
 	"pengoe/internal/services"
+	t "pengoe/internal/token"
+	"pengoe/web/templates/components"
 	"pengoe/web/templates/pages"
+	"time"
 
 	"github.com/a-h/templ"
 )
@@ -17,20 +20,77 @@ DashboardPage handles the GET request to /dashboard.
 func DashboardPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 	session, found := r.Context().Value("session").(*services.Session)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use session middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+	token, found := r.Context().Value("token").(*t.Token)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use token middleware"))
+	}
+
+	userService := services.NewUserService(r.Context(), db)
+	user, err := userService.GetById(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
-	accountService := services.NewAccountService(db)
+	accountService := services.NewAccountService(r.Context(), db)
 	accounts, err := accountService.GetByUserId(session.UserId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	reportService := services.NewReportService(r.Context(), db)
+
+	totals, err := reportService.ByAccount(services.ReportFilter{
+		UserId: session.UserId,
+	})
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// income of the last 12 months, including the current one
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	from := to.AddDate(0, -12, 0)
+
+	monthly, err := reportService.ByPeriod(services.ReportFilter{
+		UserId:      session.UserId,
+		From:        from,
+		To:          to,
+		Granularity: services.Month,
+	})
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	months := services.PeriodKeys(services.Month, from, to)
+
+	cards := []components.AccountSummaryCardProps{}
+	for _, account := range accounts {
+		card := components.AccountSummaryCardProps{
+			Account: account,
+			Totals:  &services.AccountTotals{AccountId: account.Id},
+			Months:  months,
+			Monthly: []*services.PeriodTotals{},
+		}
+
+		for _, total := range totals {
+			if total.AccountId == account.Id {
+				card.Totals = total
+			}
+		}
+
+		for _, month := range monthly {
+			if month.AccountId == account.Id {
+				card.Monthly = append(card.Monthly, month)
+			}
+		}
+
+		cards = append(cards, card)
 	}
 
 	data := pages.DashboardProps{
@@ -38,6 +98,9 @@ func DashboardPage(w http.ResponseWriter, r *http.Request, p map[string]string)
 		Description:          "Dashboard for pengoe",
 		Accounts:             accounts,
 		ShowNewAccountButton: true,
+		Summaries:            cards,
+		Token:                token,
+		User:                 user,
 	}
 
 	component := pages.Dashboard(data)
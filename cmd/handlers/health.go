@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pengoe/internal/db"
+	"pengoe/internal/logger"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+/*
+BuildTime is the time of the build, set by the linker:
+go build -ldflags "-X pengoe/cmd/handlers.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+*/
+var BuildTime string
+
+/*
+readyTimeout is the time the database has to answer the readiness check,
+the ping and the migrations query together.
+*/
+const readyTimeout = 2 * time.Second
+
+type healthStatus struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	CommitAt  string `json:"commitAt,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+/*
+Healthz handles the GET request to /healthz.
+The server is live if it can answer at all.
+*/
+func Healthz(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	return writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+/*
+Readyz handles the GET request to /readyz.
+The server is ready if the database answers and all migrations are applied.
+*/
+func Readyz(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	reason := notReadyReason(r.Context())
+	if reason != "" {
+		return writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Reason: reason})
+	}
+
+	return writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+/*
+Version handles the GET request to /version.
+It returns the module version and the VCS info embedded in the binary.
+*/
+func Version(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	return writeJSON(w, http.StatusOK, buildVersion())
+}
+
+/*
+notReadyReason returns why the server is not ready, or an empty string.
+The endpoint is public, so the errors are only logged.
+*/
+func notReadyReason(ctx context.Context) string {
+	log := logger.FromContext(ctx)
+
+	dbConn, err := db.Manager.GetDB()
+	if err != nil {
+		log.Error("Not ready", "error", err.Error())
+		return "database unavailable"
+	}
+
+	// the ping and the migrations query share the timeout
+	readyCtx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	err = dbConn.PingContext(readyCtx)
+	if err != nil {
+		log.Error("Not ready", "error", err.Error())
+		return "database unavailable"
+	}
+
+	pending, err := db.PendingMigrations(readyCtx, dbConn)
+	if err != nil {
+		log.Error("Not ready", "error", err.Error())
+		return "migrations unavailable"
+	}
+	if len(pending) > 0 {
+		return fmt.Sprintf("migrations: pending %s", strings.Join(pending, ", "))
+	}
+
+	return ""
+}
+
+func buildVersion() versionInfo {
+	info := versionInfo{
+		Version:   "(devel)",
+		BuildTime: BuildTime,
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = buildInfo.GoVersion
+	if buildInfo.Main.Version != "" {
+		info.Version = buildInfo.Main.Version
+	}
+
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.CommitAt = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
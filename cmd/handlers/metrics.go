@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+	"pengoe/internal/metrics"
+)
+
+/*
+Metrics handles the GET request to /metrics, in the Prometheus text format.
+*/
+func Metrics(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	metrics.Handler().ServeHTTP(w, r)
+	return nil
+}
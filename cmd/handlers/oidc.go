@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"pengoe/internal/logger"
+	"pengoe/internal/metrics"
+	"pengoe/internal/oidc"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"pengoe/internal/token"
+	"pengoe/web/templates/pages"
+
+	"github.com/a-h/templ"
+)
+
+/*
+oidcStateMaxAge is the lifetime of the OIDC state cookie in seconds,
+the same as the state.
+*/
+const oidcStateMaxAge = 10 * 60
+
+/*
+oidcName is the name of the provider on the signin page,
+empty if OIDC signin is off.
+*/
+func oidcName() string {
+	if oidc.Default == nil {
+		return ""
+	}
+	return oidc.Default.Name()
+}
+
+/*
+OIDCSignin handles the POST request to /signin/oidc,
+it sends the user to the provider.
+*/
+func OIDCSignin(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	if oidc.Default == nil {
+		return router.NewHTTPError(http.StatusNotFound, "", nil)
+	}
+
+	redirect, found := r.Context().Value("redirect").(string)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
+	}
+
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	remember := r.Form.Get("remember") == "on"
+
+	oidcService := services.NewOIDCService(r.Context(), db)
+
+	flow, err := oidcService.NewState(redirect, remember)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	authURL, err := oidc.Default.AuthURL(r.Context(), flow)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// the state of the callback must come from this browser
+	http.SetCookie(w, token.OIDCStateCookie(flow.State, oidcStateMaxAge, secureCookies()))
+
+	// htmx follows redirects itself, the provider is another site
+	w.Header().Set("HX-Redirect", authURL)
+
+	return nil
+}
+
+/*
+OIDCCallback handles the GET request to /signin/oidc/callback,
+the provider sends the user back here with a code.
+*/
+func OIDCCallback(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	if oidc.Default == nil {
+		return router.NewHTTPError(http.StatusNotFound, "", nil)
+	}
+
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+
+	cookie, err := r.Cookie("oidc_state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return oidcFailed(w, r, "%2Fdashboard", "The signin expired, try again", errors.New("OIDC state does not match the cookie"))
+	}
+
+	http.SetCookie(w, token.OIDCStateCookie("", -1, secureCookies()))
+
+	oidcService := services.NewOIDCService(r.Context(), db)
+
+	signin, err := oidcService.UseState(state)
+	if errors.Is(err, services.ErrInvalidOIDCState) {
+		return oidcFailed(w, r, "%2Fdashboard", "The signin expired, try again", err)
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// cancelled or refused at the provider
+	if query.Get("error") != "" {
+		return oidcFailed(w, r, signin.Redirect, "Could not sign in at the provider", errors.New(query.Get("error")))
+	}
+
+	claims, err := oidc.Default.Exchange(r.Context(), query.Get("code"), signin.Flow)
+	if err != nil {
+		return oidcFailed(w, r, signin.Redirect, "Could not sign in at the provider", err)
+	}
+
+	userId, err := oidcService.SignIn(claims)
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		return oidcFailed(w, r, signin.Redirect, "Verify your email at the provider first", err)
+	}
+	if errors.Is(err, services.ErrAccountNotLinked) {
+		return oidcFailed(w, r, signin.Redirect, "An account with your email exists, sign in with its password and verify the email first", err)
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	err = completeSignin(w, r, db, userId, signin.Remember, signin.Redirect)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	return nil
+}
+
+/*
+oidcFailed renders the signin page with the error of the provider signin.
+*/
+func oidcFailed(w http.ResponseWriter, r *http.Request, redirect, message string, err error) error {
+	metrics.Logins.Inc(metrics.LoginFailure)
+
+	log := logger.FromContext(r.Context())
+	log.Info("OIDC signin failed", "error", err.Error())
+
+	w.WriteHeader(http.StatusUnauthorized)
+
+	data := pages.SigninProps{
+		Title:       "pengoe - Sign in",
+		Descrtipion: "Sign in to pengoe",
+		RedirectUrl: redirect,
+		OIDCName:    oidcName(),
+		SigninErr:   message,
+	}
+
+	component := pages.Signin(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
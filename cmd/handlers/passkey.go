@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"pengoe/internal/logger"
+	"pengoe/internal/metrics"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	t "pengoe/internal/token"
+	"pengoe/internal/webauthn"
+	"pengoe/web/templates/components"
+	"pengoe/web/templates/pages"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+/*
+maxPasskeyNameLength is the longest name of a passkey.
+*/
+const maxPasskeyNameLength = 64
+
+/*
+passkeyResponse is the response of the authenticator, sent by passkey.js
+in the "credential" field, with the binary values in base64url.
+*/
+type passkeyResponse struct {
+	Id                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+
+/*
+decoded returns the binary values of the response, the missing ones are nil.
+*/
+func (p *passkeyResponse) decoded() (map[string][]byte, error) {
+	values := map[string][]byte{}
+
+	fields := map[string]string{
+		"id":                p.Id,
+		"clientDataJSON":    p.ClientDataJSON,
+		"attestationObject": p.AttestationObject,
+		"authenticatorData": p.AuthenticatorData,
+		"signature":         p.Signature,
+		"userHandle":        p.UserHandle,
+	}
+
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+
+		decoded, err := webauthn.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = decoded
+	}
+
+	return values, nil
+}
+
+/*
+parsePasskeyResponse parses the "credential" field of the form.
+*/
+func parsePasskeyResponse(r *http.Request) (map[string][]byte, error) {
+	response := &passkeyResponse{}
+
+	err := json.Unmarshal([]byte(r.Form.Get("credential")), response)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := response.decoded()
+	if err != nil {
+		return nil, err
+	}
+
+	if values["id"] == nil || values["clientDataJSON"] == nil {
+		return nil, errors.New("Passkey response is incomplete")
+	}
+
+	return values, nil
+}
+
+/*
+relyingParty is the site of the passkeys, from the optional APP_URL setting.
+*/
+func relyingParty() (*webauthn.RelyingParty, error) {
+	u, err := url.Parse(appURL())
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.RelyingParty{
+		ID:     u.Hostname(),
+		Name:   "pengoe",
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+/*
+PasskeySigninOptions handles the GET request to /signin/passkey/options,
+it returns the options of navigator.credentials.get.
+*/
+func PasskeySigninOptions(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	challenge, err := passkeyService.NewChallenge("")
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// not cached, the challenge is used once
+	return writeJSON(w, http.StatusOK, rp.RequestOptions(challenge))
+}
+
+/*
+PasskeySignin handles the POST request to /signin/passkey.
+The passkey verifies the user too, so there is no second step.
+*/
+func PasskeySignin(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	redirect, found := r.Context().Value("redirect").(string)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
+	}
+
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	remember := r.Form.Get("remember") == "on"
+
+	response, err := parsePasskeyResponse(r)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	userId, err := verifyPasskeySignin(rp, passkeyService, response)
+	if err != nil {
+		metrics.Logins.Inc(metrics.LoginFailure)
+
+		log := logger.FromContext(r.Context())
+		log.Info("Passkey signin failed", "error", err.Error())
+
+		w.WriteHeader(http.StatusUnauthorized)
+
+		data := pages.SigninProps{
+			Title:       "pengoe - Sign in",
+			Descrtipion: "Sign in to pengoe",
+			RedirectUrl: redirect,
+			OIDCName:    oidcName(),
+			SigninErr:   "Could not sign in with the passkey",
+		}
+
+		component := pages.Signin(data)
+		handler := templ.Handler(component)
+		handler.ServeHTTP(w, r)
+
+		return nil
+	}
+
+	metrics.Logins.Inc(metrics.LoginSuccess)
+
+	err = startSession(w, r, db, userId, remember)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+
+	return nil
+}
+
+/*
+verifyPasskeySignin verifies the response of a signin,
+and returns the user of the passkey.
+*/
+func verifyPasskeySignin(rp *webauthn.RelyingParty, passkeyService services.PasskeyServiceInterface, response map[string][]byte) (string, error) {
+	challenge, err := webauthn.Challenge(response["clientDataJSON"])
+	if err != nil {
+		return "", err
+	}
+
+	// a challenge of a registration has a user
+	challengeUserId, err := passkeyService.UseChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+	if challengeUserId != "" {
+		return "", services.ErrInvalidPasskeyChallenge
+	}
+
+	passkey, err := passkeyService.GetByCredentialId(response["id"])
+	if err != nil {
+		return "", err
+	}
+
+	userHandle := response["userHandle"]
+	if userHandle != nil && string(userHandle) != passkey.UserId {
+		return "", errors.New("User handle does not match the passkey")
+	}
+
+	signCount, err := rp.VerifyAssertion(
+		challenge,
+		passkey.Credential(),
+		response["clientDataJSON"],
+		response["authenticatorData"],
+		response["signature"],
+	)
+	if err != nil {
+		return "", err
+	}
+
+	err = passkeyService.Use(passkey, signCount)
+	if err != nil {
+		return "", err
+	}
+
+	return passkey.UserId, nil
+}
+
+/*
+PasskeysPage handles the GET request to /settings/passkeys.
+*/
+func PasskeysPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	token, found := r.Context().Value("token").(*t.Token)
+	if !found {
+		router.RedirectToSignin(w, r, p)
+		return errors.New("Should use token middleware")
+	}
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountService := services.NewAccountService(r.Context(), db)
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	accounts, err := accountService.GetByUserId(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	passkeys, err := passkeyService.GetByUserId(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	data := pages.PasskeysProps{
+		Title:       "pengoe - Passkeys",
+		Description: "Passkeys of your pengoe account",
+		Accounts:    accounts,
+		Token:       token,
+		Passkeys: components.PasskeysProps{
+			Passkeys: passkeys,
+		},
+	}
+
+	component := pages.Passkeys(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+PasskeyOptions handles the GET request to /settings/passkeys/options,
+it returns the options of navigator.credentials.create.
+*/
+func PasskeyOptions(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	userService := services.NewUserService(r.Context(), db)
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	user, err := userService.GetById(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	passkeys, err := passkeyService.GetByUserId(user.Id)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	exclude := [][]byte{}
+	for _, passkey := range passkeys {
+		exclude = append(exclude, passkey.CredentialId)
+	}
+
+	challenge, err := passkeyService.NewChallenge(user.Id)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	options := rp.CreationOptions(challenge, webauthn.User{
+		ID:          []byte(user.Id),
+		Name:        user.Username,
+		DisplayName: strings.TrimSpace(user.Fistname + " " + user.Lastname),
+	}, exclude)
+
+	return writeJSON(w, http.StatusOK, options)
+}
+
+/*
+NewPasskey handles the POST request to /settings/passkeys,
+it stores the passkey the authenticator created.
+*/
+func NewPasskey(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	name := strings.TrimSpace(html.EscapeString(r.Form.Get("name")))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		return router.NewHTTPError(http.StatusBadRequest, "Passkey name is too long", nil)
+	}
+
+	response, err := parsePasskeyResponse(r)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	settings := components.PasskeysProps{}
+
+	credential, err := verifyPasskeyRegistration(rp, passkeyService, session.UserId, response)
+	if err != nil {
+		log := logger.FromContext(r.Context())
+		log.Info("Passkey registration failed", "error", err.Error())
+
+		settings.Error = "Could not add the passkey, try again"
+	} else {
+		_, err = passkeyService.New(session.UserId, name, credential)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+	}
+
+	settings.Passkeys, err = passkeyService.GetByUserId(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	component := components.Passkeys(settings)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+verifyPasskeyRegistration verifies the response of a registration
+of the user, and returns the new credential.
+*/
+func verifyPasskeyRegistration(rp *webauthn.RelyingParty, passkeyService services.PasskeyServiceInterface, userId string, response map[string][]byte) (*webauthn.Credential, error) {
+	challenge, err := webauthn.Challenge(response["clientDataJSON"])
+	if err != nil {
+		return nil, err
+	}
+
+	challengeUserId, err := passkeyService.UseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if challengeUserId != userId {
+		return nil, services.ErrInvalidPasskeyChallenge
+	}
+
+	return rp.VerifyRegistration(challenge, response["clientDataJSON"], response["attestationObject"])
+}
+
+/*
+DeletePasskey handles the DELETE request to /settings/passkeys/:id.
+*/
+func DeletePasskey(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	passkeyId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
+
+	passkeyService := services.NewPasskeyService(r.Context(), db)
+
+	// only the passkeys of the user
+	err := passkeyService.Delete(passkeyId, session.UserId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return router.NewHTTPError(http.StatusNotFound, "", nil)
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// no return because delete
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"pengoe/internal/utils"
+	"time"
+)
+
+type accountReport struct {
+	Granularity services.Granularity        `json:"granularity"`
+	Periods     []*services.PeriodTotals    `json:"periods"`
+	Recipients  []*services.RecipientTotals `json:"recipients"`
+}
+
+/*
+AccountReport handles the GET request to /account/:id/report.
+Query params: "granularity" (day, week, month, quarter or year, default month),
+"from" and "to" (YYYY-MM-DD, optional).
+*/
+func AccountReport(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
+
+	query := r.URL.Query()
+
+	granularityStr := utils.GetQueryParam(query, "granularity")
+	if granularityStr == "" {
+		granularityStr = string(services.Month)
+	}
+
+	granularity, err := services.ParseGranularity(granularityStr)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, err.Error(), err)
+	}
+
+	filter := services.ReportFilter{
+		AccountIds:  []string{accountId},
+		Granularity: granularity,
+	}
+
+	fromStr := utils.GetQueryParam(query, "from")
+	if fromStr != "" {
+		filter.From, err = time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return router.NewHTTPError(http.StatusBadRequest, "Invalid from date", err)
+		}
+	}
+
+	toStr := utils.GetQueryParam(query, "to")
+	if toStr != "" {
+		filter.To, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return router.NewHTTPError(http.StatusBadRequest, "Invalid to date", err)
+		}
+	}
+
+	accessService := services.NewAccessService(r.Context(), db)
+	reportService := services.NewReportService(r.Context(), db)
+
+	// check if the user has access to the account
+	ok := accessService.Check(session.UserId, accountId)
+	if !ok {
+		return router.NewHTTPError(http.StatusUnauthorized, "", errors.New("No access to the account"))
+	}
+
+	periods, err := reportService.ByPeriod(filter)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	recipients, err := reportService.ByRecipient(filter)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	return json.NewEncoder(w).Encode(accountReport{
+		Granularity: granularity,
+		Periods:     periods,
+		Recipients:  recipients,
+	})
+}
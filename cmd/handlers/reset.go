@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"pengoe/config"
+	"pengoe/internal/logger"
+	"pengoe/internal/mail"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"pengoe/internal/token"
+	"pengoe/web/templates/pages"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+/*
+minPasswordLength is the shortest new password accepted.
+*/
+const minPasswordLength = 8
+
+/*
+ForgotPasswordPage handles the GET request to /forgot-password.
+*/
+func ForgotPasswordPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	data := pages.ForgotPasswordProps{
+		Title:       "pengoe - Forgot password",
+		Description: "Reset your pengoe password",
+	}
+
+	component := pages.ForgotPassword(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+ForgotPassword handles the POST request to /forgot-password.
+It sends a reset link if the email belongs to a user, the page is the same
+either way, so it does not tell which emails have an account.
+*/
+func ForgotPassword(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	email := html.EscapeString(r.Form.Get("email"))
+	if email == "" {
+		return router.NewHTTPError(http.StatusBadRequest, "Email is required", nil)
+	}
+
+	userService := services.NewUserService(r.Context(), db)
+	resetService := services.NewPasswordResetService(r.Context(), db)
+
+	user, err := userService.GetByEmail(email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		// the page is the same, not to tell which emails have an account
+		log := logger.FromContext(r.Context())
+		log.Error(err.Error())
+	}
+	if err == nil {
+		resetToken, err := resetService.New(user.Id)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+
+		link := fmt.Sprintf("%s/reset-password?token=%s", appURL(), url.QueryEscape(resetToken))
+
+		mail.SendInBackground(r.Context(), mail.PasswordReset(user.Email, user.Fistname, link))
+	}
+
+	data := pages.ForgotPasswordProps{
+		Title:       "pengoe - Forgot password",
+		Description: "Reset your pengoe password",
+		Email:       email,
+		Sent:        true,
+	}
+
+	component := pages.ForgotPassword(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+ResetPasswordPage handles the GET request to /reset-password?token=...
+*/
+func ResetPasswordPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	resetToken := r.URL.Query().Get("token")
+
+	resetService := services.NewPasswordResetService(r.Context(), db)
+
+	data := pages.ResetPasswordProps{
+		Title:       "pengoe - Reset password",
+		Description: "Reset your pengoe password",
+		Token:       resetToken,
+	}
+
+	_, err := resetService.Check(resetToken)
+	if errors.Is(err, services.ErrInvalidResetToken) {
+		data.Token = ""
+		data.ResetErr = "The link is invalid or expired"
+	} else if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	component := pages.ResetPassword(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+ResetPassword handles the POST request to /reset-password.
+It sets the new password, and signs the user out everywhere.
+*/
+func ResetPassword(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	resetToken := r.Form.Get("token")
+
+	password := html.EscapeString(r.Form.Get("password"))
+	if len(password) < minPasswordLength {
+		return router.NewHTTPError(http.StatusBadRequest, "Password is too short", nil)
+	}
+
+	resetService := services.NewPasswordResetService(r.Context(), db)
+	sessionService := services.NewSessionService(r.Context(), db)
+
+	userId, err := resetService.Reset(resetToken, password)
+	if errors.Is(err, services.ErrInvalidResetToken) {
+		w.WriteHeader(http.StatusForbidden)
+
+		data := pages.ResetPasswordProps{
+			Title:       "pengoe - Reset password",
+			Description: "Reset your pengoe password",
+			ResetErr:    "The link is invalid or expired",
+		}
+
+		component := pages.ResetPassword(data)
+		handler := templ.Handler(component)
+		handler.ServeHTTP(w, r)
+
+		return nil
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	// whoever knew the old password is signed out
+	revoked, err := sessionService.DeleteByUserID(userId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	for _, sessionId := range revoked {
+		err := token.Manager.Delete(sessionId)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+	}
+
+	w.Header().Set("HX-Redirect", "/signin")
+
+	return nil
+}
+
+/*
+appURL returns the public URL of the app for the links in the emails,
+from the optional APP_URL setting.
+*/
+func appURL() string {
+	return strings.TrimSuffix(config.Optional("APP_URL", "http://localhost:8080"), "/")
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	t "pengoe/internal/token"
+	"pengoe/web/templates/pages"
+
+	"github.com/a-h/templ"
+)
+
+/*
+SessionsPage handles the GET request to /settings/sessions.
+*/
+func SessionsPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	token, found := r.Context().Value("token").(*t.Token)
+	if !found {
+		router.RedirectToSignin(w, r, p)
+		return errors.New("Should use token middleware")
+	}
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountService := services.NewAccountService(r.Context(), db)
+	sessionService := services.NewSessionService(r.Context(), db)
+
+	// get accounts
+	accounts, err := accountService.GetByUserId(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	sessions, err := sessionService.GetByUserID(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	data := pages.SessionsProps{
+		Title:            "pengoe - Sessions",
+		Description:      "Sessions of your pengoe account",
+		Accounts:         accounts,
+		Token:            token,
+		Sessions:         sessions,
+		CurrentSessionId: session.Id,
+	}
+
+	component := pages.Sessions(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+RevokeSession handles the DELETE request to /settings/sessions/:id,
+it signs out the device of the session. Signing out this device
+redirects to the signin page.
+*/
+func RevokeSession(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	sessionId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
+
+	sessionService := services.NewSessionService(r.Context(), db)
+
+	// only the sessions of the user
+	revoked, err := sessionService.GetById(sessionId)
+	if err != nil || revoked.UserId != session.UserId {
+		return router.NewHTTPError(http.StatusNotFound, "", nil)
+	}
+
+	err = sessionService.Delete(revoked.Id)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	err = t.Manager.Delete(revoked.Id)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	if revoked.Id == session.Id {
+		clearSessionCookie(w)
+		w.Header().Set("HX-Redirect", "/signin")
+	}
+
+	// no return because delete
+
+	return nil
+}
+
+/*
+RevokeAllSessions handles the DELETE request to /settings/sessions,
+it signs out every device of the user, this one too.
+*/
+func RevokeAllSessions(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	sessionService := services.NewSessionService(r.Context(), db)
+
+	revoked, err := sessionService.DeleteByUserID(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	for _, sessionId := range revoked {
+		err := t.Manager.Delete(sessionId)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+	}
+
+	clearSessionCookie(w)
+	w.Header().Set("HX-Redirect", "/signin")
+
+	return nil
+}
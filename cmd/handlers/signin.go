@@ -3,14 +3,17 @@ package handlers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"html"
 	"net/http"
 	"pengoe/config"
+	"pengoe/internal/metrics"
 	"pengoe/internal/router"
 	"pengoe/internal/services"
 	"pengoe/internal/token"
 	"pengoe/internal/utils"
 	"pengoe/web/templates/pages"
+	"time"
 
 	"github.com/a-h/templ"
 )
@@ -21,14 +24,14 @@ SigninPage handles the GET request to /signin.
 func SigninPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	redirect, found := r.Context().Value("redirect").(string)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use redirect middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
 	}
 
 	data := pages.SigninProps{
 		Title:           "pengoe - Sign in",
 		Descrtipion:     "Sign in to pengoe",
 		RedirectUrl:     redirect,
+		OIDCName:        oidcName(),
 		UsernameOrEmail: "",
 		SigninErr:       "",
 	}
@@ -46,49 +49,50 @@ Signin handles the POST request to /signin.
 func Signin(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	redirect, found := r.Context().Value("redirect").(string)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use redirect middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
 	}
 
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	err := r.ParseForm()
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	form := r.Form
 
 	usernameOrEmail := html.EscapeString(form.Get("user"))
 	if usernameOrEmail == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Username or email is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Username or email is required", nil)
 	}
 
 	password := html.EscapeString(form.Get("password"))
 	if password == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Password is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Password is required", nil)
 	}
 
-	userService := services.NewUserService(db)
+	// the session outlives the browser
+	remember := form.Get("remember") == "on"
+
+	userService := services.NewUserService(r.Context(), db)
 
 	// login the user
 	userId, err := userService.Signin(usernameOrEmail, password)
 
 	// if the login was unsuccessful
 	if err != nil {
+		metrics.Logins.Inc(metrics.LoginFailure)
+
 		w.WriteHeader(http.StatusUnauthorized)
 
 		data := pages.SigninProps{
 			Title:           "pengoe - Sign in",
 			Descrtipion:     "Sign in to pengoe",
 			RedirectUrl:     redirect,
+			OIDCName:        oidcName(),
 			UsernameOrEmail: usernameOrEmail,
 			SigninErr:       "Incorrect username or password",
 		}
@@ -100,46 +104,86 @@ func Signin(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 		return nil
 	}
 
+	err = completeSignin(w, r, db, userId, remember, redirect)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	return nil
+}
+
+/*
+completeSignin finishes a signin with the first factor (a password or the
+provider): with two-factor authentication it redirects to the second step,
+otherwise it starts the session and redirects.
+*/
+func completeSignin(w http.ResponseWriter, r *http.Request, db *sql.DB, userId string, remember bool, redirect string) error {
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	// with two-factor authentication, the session waits for the code
+	twoFactor, err := totpService.Get(userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err == nil && twoFactor.Enabled() {
+		challengeService := services.NewSigninChallengeService(r.Context(), db)
+
+		challenge, err := challengeService.New(userId, remember)
+		if err != nil {
+			return err
+		}
+
+		http.SetCookie(w, token.ChallengeCookie(challenge, challengeMaxAge, secureCookies()))
+		http.Redirect(w, r, fmt.Sprintf("/signin/2fa?redirect=%s", redirect), http.StatusSeeOther)
+
+		return nil
+	}
+
 	// if the login was successful
+	metrics.Logins.Inc(metrics.LoginSuccess)
+
+	err = startSession(w, r, db, userId, remember)
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+
+	return nil
+}
 
+/*
+startSession signs in the user: it creates the session and its token,
+and sets the session cookie, until the browser closes if not remembered.
+*/
+func startSession(w http.ResponseWriter, r *http.Request, db *sql.DB, userId string, remember bool) error {
 	id := utils.NewUUID("ses")
 
-	sessionService := services.NewSessionService(db)
-	session, err := sessionService.New(id, userId)
+	sessionService := services.NewSessionService(r.Context(), db)
+	session, err := sessionService.New(id, userId, r.UserAgent(), utils.ClientIP(r), remember)
 	if err != nil {
-		router.InternalError(w, r, p)
 		return err
 	}
 
 	_, err = token.Manager.Create(session.Id)
 	if err != nil {
-		router.InternalError(w, r, p)
 		return err
 	}
 
-	secure := config.Env.ENVIRONMENT == "production"
-	var sameSite http.SameSite
-	if config.Env.ENVIRONMENT == "production" {
-		sameSite = http.SameSiteLaxMode
-	} else {
-		sameSite = http.SameSiteDefaultMode
-	}
-
-	valid := session.ValidUntil.UTC()
-
-	// set the session id to cookie
-	cookie := &http.Cookie{
-		Name:     "session",
-		Value:    session.Id,
-		Path:     "/",
-		Expires:  valid,
-		HttpOnly: true,
-		Secure:   secure,
-		SameSite: sameSite,
+	var expires time.Time
+	if session.Remember {
+		expires = session.ValidUntil
 	}
 
-	http.SetCookie(w, cookie)
-	http.Redirect(w, r, redirect, http.StatusSeeOther)
+	http.SetCookie(w, token.SessionCookie(session.Id, expires, secureCookies()))
 
 	return nil
 }
+
+/*
+secureCookies tells if the cookies should be Secure, in production.
+*/
+func secureCookies() bool {
+	return config.Env.ENVIRONMENT == "production"
+}
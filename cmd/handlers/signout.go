@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
-	"pengoe/config"
 	"pengoe/internal/router"
 	"pengoe/internal/services"
 	"pengoe/internal/token"
@@ -18,20 +17,11 @@ Access token is cleared by the client.
 func Signout(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, dbFound := r.Context().Value("db").(*sql.DB)
 	if !dbFound {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
-	}
-
-	secure := config.Env.ENVIRONMENT == "production"
-	var sameSite http.SameSite
-	if config.Env.ENVIRONMENT == "production" {
-		sameSite = http.SameSiteLaxMode
-	} else {
-		sameSite = http.SameSiteDefaultMode
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	// delete the session from the database
-	sessionService := services.NewSessionService(db)
+	sessionService := services.NewSessionService(r.Context(), db)
 
 	// get old session form cookie
 	session, sessionErr := sessionService.CheckFromCookie(r)
@@ -52,16 +42,16 @@ func Signout(w http.ResponseWriter, r *http.Request, p map[string]string) error
 	}
 
 	// delete the session cookie from the client
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour).UTC(),
-		HttpOnly: true,
-		Secure:   secure,
-		SameSite: sameSite,
-	})
+	clearSessionCookie(w)
 
 	w.Header().Set("HX-Redirect", "/signin")
 
 	return nil
 }
+
+/*
+clearSessionCookie deletes the session cookie from the client.
+*/
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, token.SessionCookie("", time.Now().Add(-1*time.Hour).UTC(), secureCookies()))
+}
@@ -22,8 +22,7 @@ SignupPage handles the GET request to /signup.
 func SignupPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	redirect, found := r.Context().Value("redirect").(string)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use redirect middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
 	}
 
 	data := pages.SignupProps{
@@ -53,55 +52,47 @@ Signup handles the POST request to /signup.
 func Signup(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	redirect, found := r.Context().Value("redirect").(string)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use redirect middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
 	}
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	err := r.ParseForm()
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	form := r.Form
 
 	username := html.EscapeString(form.Get("username"))
 	if username == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Username is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Username is required", nil)
 	}
 
 	email := html.EscapeString(form.Get("email"))
 	if email == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Email is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Email is required", nil)
 	}
 
 	firstname := html.EscapeString(form.Get("firstname"))
 	if firstname == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Firstname is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Firstname is required", nil)
 	}
 
 	lastname := html.EscapeString(form.Get("lastname"))
 	if lastname == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Lastname is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Lastname is required", nil)
 	}
 
 	password := html.EscapeString(form.Get("password"))
 	if password == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Password is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Password is required", nil)
 	}
 
 	// create user service
-	userService := services.NewUserService(db)
+	userService := services.NewUserService(r.Context(), db)
 
 	// add user
 	id := utils.NewUUID("usr")
@@ -110,7 +101,7 @@ func Signup(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 
 	// unsuccessful signup, render signup page with error message
 	if err != nil {
-		log := logger.Get()
+		log := logger.FromContext(r.Context())
 
 		log.Error(err.Error())
 
@@ -169,7 +160,18 @@ func Signup(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 		return err
 	}
 
-	// successful signup, redirect to signin page
+	// successful signup, the user can sign in before verifying the email
+	err = sendVerification(r.Context(), db, &services.User{
+		Id:       id,
+		Email:    email,
+		Fistname: firstname,
+	})
+	if err != nil {
+		log := logger.FromContext(r.Context())
+		log.Error(err.Error())
+	}
+
+	// redirect to signin page
 	http.Redirect(
 		w,
 		r,
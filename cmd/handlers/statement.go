@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"pengoe/internal/pdf"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"pengoe/internal/utils"
+	"time"
+)
+
+/*
+AccountStatement handles the GET request to /account/:id/statement.
+Query params: "period" (month or quarter) and "month" (YYYY-MM) inside the period.
+*/
+func AccountStatement(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountId, found := p["id"]
+	if !found {
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
+	}
+
+	query := r.URL.Query()
+
+	period := utils.GetQueryParam(query, "period")
+	if period == "" {
+		period = "month"
+	}
+
+	date := time.Now().UTC()
+
+	month := utils.GetQueryParam(query, "month")
+	if month != "" {
+		parsed, err := time.Parse("2006-01", month)
+		if err != nil {
+			return router.NewHTTPError(http.StatusBadRequest, "", err)
+		}
+		date = parsed
+	}
+
+	from, to, err := utils.GetPeriodBounds(period, date)
+	if err != nil {
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
+	}
+
+	accessService := services.NewAccessService(r.Context(), db)
+	statementService := services.NewStatementService(r.Context(), db)
+
+	// check if the user has access to the account
+	ok := accessService.Check(session.UserId, accountId)
+	if !ok {
+		return router.NewHTTPError(http.StatusUnauthorized, "", nil)
+	}
+
+	statement, err := statementService.Get(accountId, from, to)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	document, err := renderStatement(statement)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	filename := fmt.Sprintf("pengoe-%s-%s-%s.pdf", accountId, period, from.Format("2006-01"))
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(document)
+
+	return nil
+}
+
+/*
+statementWriter lays out lines on the pages of a PDF document,
+and starts a new page when the current one is full.
+*/
+type statementWriter struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+}
+
+const (
+	statementMargin     = 50.0
+	statementLineHeight = 16.0
+)
+
+func (s *statementWriter) line() *pdf.Page {
+	if s.page == nil || s.y > pdf.PageHeight-statementMargin {
+		s.page = s.doc.AddPage()
+		s.y = statementMargin
+	}
+
+	s.y += statementLineHeight
+	return s.page
+}
+
+/*
+row writes texts into columns, the first column is left aligned,
+the others are right aligned to their x position.
+*/
+func (s *statementWriter) row(font pdf.Font, columns []float64, texts ...string) {
+	page := s.line()
+	for i, text := range texts {
+		if i == 0 {
+			page.Text(columns[i], s.y, font, 10, text)
+		} else {
+			page.TextRight(columns[i], s.y, font, 10, text)
+		}
+	}
+}
+
+func (s *statementWriter) heading(text string) {
+	s.y += statementLineHeight / 2
+	s.line().Text(statementMargin, s.y, pdf.Bold, 12, text)
+}
+
+func (s *statementWriter) rule() {
+	s.page.Line(statementMargin, s.y+4, pdf.PageWidth-statementMargin, s.y+4, 0.5)
+}
+
+/*
+renderStatement renders the statement as a PDF document.
+*/
+func renderStatement(statement *services.Statement) ([]byte, error) {
+	currency := statement.Account.Currency
+	amount := func(value int) string {
+		return fmt.Sprintf("%s %d", currency, value)
+	}
+
+	right := pdf.PageWidth - statementMargin
+	summary := []float64{statementMargin, right}
+	events := []float64{statementMargin, 400, right}
+	shares := []float64{statementMargin, 330, 430, right}
+
+	s := &statementWriter{doc: pdf.New()}
+
+	s.line().Text(statementMargin, s.y, pdf.Bold, 18, "Account statement")
+	s.y += statementLineHeight / 2
+	s.row(pdf.Bold, summary, statement.Account.Name)
+	s.row(pdf.Regular, summary, fmt.Sprintf(
+		"%s - %s",
+		statement.From.Format("2006-01-02"),
+		statement.To.AddDate(0, 0, -1).Format("2006-01-02"),
+	))
+
+	s.heading("Summary")
+	s.rule()
+	s.row(pdf.Regular, summary, "Opening balance", amount(statement.OpeningBalance))
+	s.row(pdf.Regular, summary, "Income", amount(statement.Income))
+	s.row(pdf.Regular, summary, "Reserved", amount(statement.Reserved))
+	s.row(pdf.Regular, summary, "Paid out", amount(statement.PaidOut))
+	s.row(pdf.Bold, summary, "Closing balance", amount(statement.ClosingBalance))
+
+	s.heading("Events")
+	s.row(pdf.Bold, events, "Date / name", "Income", "Reserved")
+	s.rule()
+	if len(statement.Events) == 0 {
+		s.row(pdf.Regular, events, "- no events -")
+	}
+	for _, event := range statement.Events {
+		s.row(
+			pdf.Regular,
+			events,
+			fmt.Sprintf("%s  %s", event.DeliveredAt.Format("2006-01-02"), event.Name),
+			amount(event.Income),
+			amount(event.Reserved),
+		)
+	}
+
+	s.heading("Recipients")
+	s.row(pdf.Bold, shares, "Recipient", "Share", "Paid", "Outstanding")
+	s.rule()
+	if len(statement.Shares) == 0 {
+		s.row(pdf.Regular, shares, "- no payments -")
+	}
+	for _, share := range statement.Shares {
+		s.row(
+			pdf.Regular,
+			shares,
+			share.Name,
+			amount(share.Share),
+			amount(share.Paid),
+			amount(share.Outstanding),
+		)
+	}
+
+	return s.doc.Bytes()
+}
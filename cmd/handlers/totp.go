@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"net/http"
+	"pengoe/internal/metrics"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	t "pengoe/internal/token"
+	"pengoe/internal/totp"
+	"pengoe/web/templates/components"
+	"pengoe/web/templates/pages"
+
+	"github.com/a-h/templ"
+	"github.com/skip2/go-qrcode"
+)
+
+/*
+challengeMaxAge is the lifetime of the signin challenge cookie in seconds,
+the same as the challenge.
+*/
+const challengeMaxAge = 5 * 60
+
+/*
+totpIssuer is the name of the app in the authenticator apps.
+*/
+const totpIssuer = "pengoe"
+
+/*
+TwoFactorSigninPage handles the GET request to /signin/2fa,
+the second step of the signin.
+*/
+func TwoFactorSigninPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	redirect, found := r.Context().Value("redirect").(string)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
+	}
+
+	// no password yet
+	_, err := r.Cookie("signin_challenge")
+	if err != nil {
+		http.Redirect(w, r, "/signin?redirect="+redirect, http.StatusSeeOther)
+		return nil
+	}
+
+	data := pages.TwoFactorSigninProps{
+		Title:       "pengoe - Two-factor authentication",
+		Description: "Sign in to pengoe",
+		RedirectUrl: redirect,
+	}
+
+	component := pages.TwoFactorSignin(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+TwoFactorSignin handles the POST request to /signin/2fa.
+It takes a code of the authenticator app, or a recovery code.
+*/
+func TwoFactorSignin(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	redirect, found := r.Context().Value("redirect").(string)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use redirect middleware"))
+	}
+
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	code := html.EscapeString(r.Form.Get("code"))
+	if code == "" {
+		return router.NewHTTPError(http.StatusBadRequest, "Code is required", nil)
+	}
+
+	cookie, err := r.Cookie("signin_challenge")
+	if err != nil {
+		w.Header().Set("HX-Redirect", "/signin?redirect="+redirect)
+		return nil
+	}
+
+	challengeService := services.NewSigninChallengeService(r.Context(), db)
+
+	// expired, or too many attempts: the password again
+	challenge, err := challengeService.Attempt(cookie.Value)
+	if errors.Is(err, services.ErrInvalidChallenge) {
+		http.SetCookie(w, t.ChallengeCookie("", -1, secureCookies()))
+		w.Header().Set("HX-Redirect", "/signin?redirect="+redirect)
+		return nil
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	err = totpService.Verify(challenge.UserId, code)
+	if errors.Is(err, services.ErrInvalidCode) {
+		err = totpService.UseRecoveryCode(challenge.UserId, code)
+	}
+
+	if errors.Is(err, services.ErrInvalidCode) {
+		metrics.Logins.Inc(metrics.LoginFailure)
+
+		w.WriteHeader(http.StatusUnauthorized)
+
+		data := pages.TwoFactorSigninProps{
+			Title:       "pengoe - Two-factor authentication",
+			Description: "Sign in to pengoe",
+			RedirectUrl: redirect,
+			SigninErr:   "Incorrect code",
+		}
+
+		component := pages.TwoFactorSignin(data)
+		handler := templ.Handler(component)
+		handler.ServeHTTP(w, r)
+
+		return nil
+	}
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	metrics.Logins.Inc(metrics.LoginSuccess)
+
+	err = challengeService.Delete(cookie.Value)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	http.SetCookie(w, t.ChallengeCookie("", -1, secureCookies()))
+
+	err = startSession(w, r, db, challenge.UserId, challenge.Remember)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+
+	return nil
+}
+
+/*
+TwoFactorPage handles the GET request to /settings/two-factor.
+*/
+func TwoFactorPage(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	token, found := r.Context().Value("token").(*t.Token)
+	if !found {
+		router.RedirectToSignin(w, r, p)
+		return errors.New("Should use token middleware")
+	}
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	accountService := services.NewAccountService(r.Context(), db)
+
+	accounts, err := accountService.GetByUserId(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	settings, err := twoFactorSettings(r, db, session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	data := pages.TwoFactorProps{
+		Title:       "pengoe - Two-factor authentication",
+		Description: "Two-factor authentication of your pengoe account",
+		Accounts:    accounts,
+		Token:       token,
+		Settings:    settings,
+	}
+
+	component := pages.TwoFactor(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+SetupTwoFactor handles the POST request to /settings/two-factor/setup,
+it creates a new secret and shows its QR code.
+*/
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	_, err := totpService.Begin(session.UserId)
+	if err != nil && !errors.Is(err, services.ErrTOTPEnabled) {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	settings, err := twoFactorSettings(r, db, session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	component := components.TwoFactorSettings(settings)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+EnableTwoFactor handles the POST request to /settings/two-factor/enable,
+it finishes the setup with a code and shows the recovery codes.
+*/
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	code := html.EscapeString(r.Form.Get("code"))
+
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	codes, err := totpService.Enable(session.UserId, code)
+	if err != nil && !errors.Is(err, services.ErrInvalidCode) && !errors.Is(err, services.ErrTOTPEnabled) {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	settings, settingsErr := twoFactorSettings(r, db, session.UserId)
+	if settingsErr != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", settingsErr)
+	}
+
+	if errors.Is(err, services.ErrInvalidCode) {
+		settings.Error = "Incorrect code, try the next one"
+	}
+
+	settings.RecoveryCodes = codes
+
+	component := components.TwoFactorSettings(settings)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+DisableTwoFactor handles the POST request to /settings/two-factor/disable,
+it needs a code, so a stolen session alone can not turn it off.
+*/
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	code := html.EscapeString(r.Form.Get("code"))
+
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	err = totpService.Verify(session.UserId, code)
+	if errors.Is(err, services.ErrInvalidCode) {
+		err = totpService.UseRecoveryCode(session.UserId, code)
+	}
+
+	invalid := errors.Is(err, services.ErrInvalidCode)
+	if err != nil && !invalid && !errors.Is(err, services.ErrTOTPNotEnabled) {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	if err == nil {
+		err = totpService.Disable(session.UserId)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+	}
+
+	settings, err := twoFactorSettings(r, db, session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	if invalid {
+		settings.Error = "Incorrect code"
+	}
+
+	component := components.TwoFactorSettings(settings)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+twoFactorSettings returns the state of the two-factor settings of the user:
+off, set up but not enabled yet (with the QR code), or on.
+*/
+func twoFactorSettings(r *http.Request, db *sql.DB, userId string) (components.TwoFactorSettingsProps, error) {
+	settings := components.TwoFactorSettingsProps{}
+
+	totpService := services.NewTOTPService(r.Context(), db)
+
+	secret, err := totpService.Get(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+
+	if secret.Enabled() {
+		settings.Enabled = true
+		settings.CodesLeft, err = totpService.RecoveryCodesLeft(userId)
+		return settings, err
+	}
+
+	userService := services.NewUserService(r.Context(), db)
+
+	user, err := userService.GetById(userId)
+	if err != nil {
+		return settings, err
+	}
+
+	qr, err := qrcode.New(totp.URI(totpIssuer, user.Email, secret.Secret), qrcode.Medium)
+	if err != nil {
+		return settings, err
+	}
+
+	settings.Secret = secret.Secret
+	settings.QRCode = qr.Bitmap()
+
+	return settings, nil
+}
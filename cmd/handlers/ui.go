@@ -20,29 +20,25 @@ import (
 func NewEventForm(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	err := r.ParseForm()
 	if err != nil {
-		router.BadRequest(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusBadRequest, "", err)
 	}
 
 	form := r.Form
 
 	accountId := html.EscapeString(form.Get("account_id"))
 	if accountId == "" {
-		router.BadRequest(w, r, p)
-		return errors.New("Account ID is required")
+		return router.NewHTTPError(http.StatusBadRequest, "Account ID is required", nil)
 	}
 
-	accountService := services.NewAccountService(db)
+	accountService := services.NewAccountService(r.Context(), db)
 	account, err := accountService.GetById(accountId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	eventFormData := c.EventFormProps{
@@ -83,29 +79,25 @@ func NewEventFormButton(w http.ResponseWriter, r *http.Request, p map[string]str
 func EditEventForm(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	eventId, found := p["id"]
 	if !found {
-		router.NotFound(w, r, p)
-		return errors.New("Path variable \"id\" not found")
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
 	}
 
-	eventService := services.NewEventService(db)
-	accountService := services.NewAccountService(db)
+	eventService := services.NewEventService(r.Context(), db)
+	accountService := services.NewAccountService(r.Context(), db)
 
 	event, err := eventService.GetById(eventId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	account, err := accountService.GetById(event.AccountId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	data := c.EventFormProps{
@@ -142,29 +134,25 @@ func EditEventForm(w http.ResponseWriter, r *http.Request, p map[string]string)
 func EventCard(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 	db, found := r.Context().Value("db").(*sql.DB)
 	if !found {
-		router.InternalError(w, r, p)
-		return errors.New("Should use db middleware")
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
 	}
 
 	eventId, found := p["id"]
 	if !found {
-		router.NotFound(w, r, p)
-		return errors.New("Path variable \"id\" not found")
+		return router.NewHTTPError(http.StatusNotFound, "", errors.New("Path variable \"id\" not found"))
 	}
 
-	eventService := services.NewEventService(db)
-	accountService := services.NewAccountService(db)
+	eventService := services.NewEventService(r.Context(), db)
+	accountService := services.NewAccountService(r.Context(), db)
 
 	event, err := eventService.GetById(eventId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	account, err := accountService.GetById(event.AccountId)
 	if err != nil {
-		router.InternalError(w, r, p)
-		return err
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
 	}
 
 	data := c.EventCardProps{
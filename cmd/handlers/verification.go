@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"pengoe/internal/mail"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+	"pengoe/web/templates/components"
+	"pengoe/web/templates/pages"
+
+	"github.com/a-h/templ"
+)
+
+/*
+VerifyEmail handles the GET request to /verify-email?token=...
+It works signed out too, the link can be opened on another device.
+*/
+func VerifyEmail(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+
+	verificationService := services.NewEmailVerificationService(r.Context(), db)
+
+	_, err := verificationService.Verify(r.URL.Query().Get("token"))
+	if err != nil && !errors.Is(err, services.ErrInvalidVerificationToken) {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	data := pages.VerifyEmailProps{
+		Title:       "pengoe - Verify email",
+		Description: "Verify your email for pengoe",
+		Verified:    err == nil,
+	}
+
+	component := pages.VerifyEmail(data)
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+ResendVerification handles the POST request to /verify-email/resend.
+*/
+func ResendVerification(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	db, found := r.Context().Value("db").(*sql.DB)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+	}
+	session, found := r.Context().Value("session").(*services.Session)
+	if !found {
+		return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+	}
+
+	userService := services.NewUserService(r.Context(), db)
+
+	user, err := userService.GetById(session.UserId)
+	if err != nil {
+		return router.NewHTTPError(http.StatusInternalServerError, "", err)
+	}
+
+	message := fmt.Sprintf("We sent a new link to %s.", user.Email)
+
+	if user.Verified() {
+		message = "Your email is already verified."
+	} else {
+		err = sendVerification(r.Context(), db, user)
+		if errors.Is(err, services.ErrVerificationTooSoon) {
+			message = "We just sent you a link, try again in a minute."
+		} else if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+	}
+
+	component := components.VerifyEmailBanner(components.VerifyEmailBannerProps{
+		Message: message,
+	})
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+sendVerification creates a verification token for the user,
+and emails the link to the address of the user.
+*/
+func sendVerification(ctx context.Context, db *sql.DB, user *services.User) error {
+	verificationService := services.NewEmailVerificationService(ctx, db)
+
+	verificationToken, err := verificationService.New(user.Id)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", appURL(), url.QueryEscape(verificationToken))
+
+	mail.SendInBackground(ctx, mail.EmailVerification(user.Email, user.Fistname, link))
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"pengoe/config"
+	"pengoe/internal/logger"
+	"strconv"
+	"time"
+)
+
+/*
+setupLogger configures the process-wide logger from the -log flag
+and the optional LOG_* settings.
+*/
+func setupLogger(levelFlag string) error {
+	level, err := logger.ParseLevel(levelFlag)
+	if err != nil {
+		return err
+	}
+
+	packageLevels, err := logger.ParsePackageLevels(config.Optional("LOG_LEVELS", ""))
+	if err != nil {
+		return err
+	}
+
+	maxSizeMB, err := strconv.Atoi(config.Optional("LOG_MAX_SIZE_MB", "100"))
+	if err != nil {
+		return err
+	}
+
+	retentionDays, err := strconv.Atoi(config.Optional("LOG_RETENTION_DAYS", "14"))
+	if err != nil {
+		return err
+	}
+
+	file := logger.FileOptions{
+		Dir:     config.Optional("LOG_DIR", "logs"),
+		Name:    "server",
+		MaxSize: int64(maxSizeMB) << 20,
+		MaxAge:  time.Duration(retentionDays) * 24 * time.Hour,
+	}
+
+	// all lines to the file, errors to stderr
+	sinks, err := logger.ParseSinks(config.Optional("LOG_OUTPUT", "file:json,stderr:json:ERROR"), file)
+	if err != nil {
+		return err
+	}
+
+	return logger.Setup(logger.Options{
+		Level:         level,
+		PackageLevels: packageLevels,
+		Sinks:         sinks,
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"pengoe/config"
+	"pengoe/internal/mail"
+)
+
+/*
+setupMail sets the mailer from the optional MAIL_DRIVER setting: "log"
+(default) writes the emails to the log, "file" appends them to MAIL_FILE
+(default logs/mail.eml), "smtp" sends them through SMTP_HOST:SMTP_PORT
+(default 587) as MAIL_FROM, with SMTP_USERNAME and SMTP_PASSWORD if set.
+*/
+func setupMail() error {
+	switch driver := config.Optional("MAIL_DRIVER", "log"); driver {
+	case "log":
+		mail.Default = mail.NewLogMailer()
+		return nil
+
+	case "file":
+		mail.Default = mail.NewFileMailer(config.Optional("MAIL_FILE", "logs/mail.eml"))
+		return nil
+
+	case "smtp":
+		host := config.Optional("SMTP_HOST", "")
+		if host == "" {
+			return fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+
+		mail.Default = mail.NewSMTPMailer(
+			host,
+			config.Optional("SMTP_PORT", "587"),
+			config.Optional("SMTP_USERNAME", ""),
+			config.Optional("SMTP_PASSWORD", ""),
+			config.Optional("MAIL_FROM", "pengoe <noreply@localhost>"),
+		)
+		return nil
+
+	default:
+		return fmt.Errorf("Unknown mail driver %q", driver)
+	}
+}
@@ -1,22 +1,124 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net/http"
+	"io"
+	"math"
+	"os"
+	"os/signal"
 	h "pengoe/cmd/handlers"
 	m "pengoe/cmd/middlewares"
 	"pengoe/config"
+	"pengoe/internal/db"
 	"pengoe/internal/logger"
+	"pengoe/internal/mail"
+	"pengoe/internal/metrics"
 	"pengoe/internal/router"
+	"pengoe/internal/server"
+	"pengoe/internal/services"
+	"syscall"
 )
 
 func main() {
-	flag.StringVar(&logger.LogLevelFlag, "log", "INFO", "-log DEBUG|INFO|WARNING|ERROR")
+	var logLevel string
+	flag.StringVar(&logLevel, "log", "INFO", "-log DEBUG|INFO|WARNING|ERROR")
 	flag.Parse()
 
+	err := setupLogger(logLevel)
+	if err != nil {
+		logger.Get().Fatal(err.Error())
+	}
+	defer logger.Close()
+
+	log := logger.Get()
+
+	// access log, optionally also to a file in Apache combined format
+	var combined io.Writer
+	accessLogFile := config.Optional("ACCESS_LOG_FILE", "")
+	if accessLogFile != "" {
+		file, err := os.OpenFile(accessLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer file.Close()
+		combined = file
+	}
+
+	err = setupTracing()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// apply the pending migrations before serving
+	dbConn, err := db.Manager.GetDB()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	applied, err := db.Migrate(dbConn)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, version := range applied {
+		log.Info("Migration applied", "version", version)
+	}
+
+	err = setupMail()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	err = setupSessions()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	err = setupTOTP()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	err = setupOIDC()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	err = setupTokens(dbConn)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
 	// create router
 	r := router.NewRouter()
+	r.Use(router.AccessLog(combined), router.Metrics, router.Recover)
+
+	// health checks and build info, without auth
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	r.GET("/version", h.Version)
+
+	// prometheus metrics, only for the scraper with the token
+	metricsToken := config.Optional("METRICS_TOKEN", "")
+	if metricsToken != "" {
+		r.GET("/metrics", h.Metrics, m.BearerToken(metricsToken))
+	} else {
+		log.Info("Metrics endpoint disabled, METRICS_TOKEN is not set")
+	}
+
+	sessionService := services.NewSessionService(context.Background(), dbConn)
+	metrics.Default.NewGaugeFunc(
+		"pengoe_active_sessions",
+		"Number of active sessions.",
+		func() float64 {
+			count, err := sessionService.CountActives()
+			if err != nil {
+				return math.NaN()
+			}
+			return float64(count)
+		},
+	)
 
 	// home page
 	r.GET("/", h.HomePageHandler)
@@ -28,45 +130,109 @@ func main() {
 	// signin
 	r.GET("/signin", h.SigninPage, m.AuthPage)
 	r.POST("/signin", h.Signin, m.AuthPage, m.DB)
+	r.GET("/signin/2fa", h.TwoFactorSigninPage, m.AuthPage)
+	r.POST("/signin/2fa", h.TwoFactorSignin, m.AuthPage, m.DB)
+	r.GET("/signin/passkey/options", h.PasskeySigninOptions, m.AuthPage, m.DB)
+	r.POST("/signin/passkey", h.PasskeySignin, m.AuthPage, m.DB)
+	r.POST("/signin/oidc", h.OIDCSignin, m.AuthPage, m.DB)
+	r.GET("/signin/oidc/callback", h.OIDCCallback, m.DB)
+
+	// password reset
+	r.GET("/forgot-password", h.ForgotPasswordPage, m.AuthPage)
+	r.POST("/forgot-password", h.ForgotPassword, m.AuthPage, m.DB)
+	r.GET("/reset-password", h.ResetPasswordPage, m.AuthPage, m.DB)
+	r.POST("/reset-password", h.ResetPassword, m.AuthPage, m.DB)
+
+	csrf, err := csrfOptions()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// signed in users
+	signedIn := r.Group("", m.Token, m.DB, m.Session)
+
+	// signout, from every page (not all pages have a csrf token)
+	signedIn.POST("/signout", h.Signout)
 
-	// signout
-	r.POST("/signout", h.Signout, m.Token, m.DB, m.Session)
+	// pages and actions of signed in users, the actions check the csrf token
+	app := signedIn.Group("", m.CSRF(csrf))
 
 	// dashboard
-	r.GET("/dashboard", h.DashboardPage, m.Token, m.DB, m.Session)
+	app.GET("/dashboard", h.DashboardPage)
 
 	// account
-	r.GET("/account/new", h.NewAccountPage, m.Token, m.DB, m.Session)
-	r.POST("/account", h.NewAccount, m.Token, m.DB, m.Session)
-	r.GET("/account/:id", h.AccountPage, m.Token, m.DB, m.Session)
-	r.DELETE("/account/:id", h.DeleteAccount, m.Token, m.DB, m.Session)
+	account := app.Group("/account")
+	account.GET("/new", h.NewAccountPage)
+	account.POST("", h.NewAccount, m.Verified)
+	account.POST("/restore", h.RestoreAccount, m.Verified)
+	account.GET("/:id{uuid}", h.AccountPage)
+	account.DELETE("/:id{uuid}", h.DeleteAccount)
+	account.GET("/:id{uuid}/events", h.AccountEvents)
+	account.GET("/:id{uuid}/backup", h.BackupAccount)
+	account.GET("/:id{uuid}/statement", h.AccountStatement)
+	account.GET("/:id{uuid}/report", h.AccountReport)
+
+	// email verification, the link works signed out too
+	r.GET("/verify-email", h.VerifyEmail, m.DB)
+	app.POST("/verify-email/resend", h.ResendVerification)
+
+	// settings
+	userSettings := app.Group("/settings")
+	userSettings.GET("/sessions", h.SessionsPage)
+	userSettings.DELETE("/sessions", h.RevokeAllSessions)
+	userSettings.DELETE("/sessions/:id{uuid}", h.RevokeSession)
+	userSettings.GET("/two-factor", h.TwoFactorPage)
+	userSettings.POST("/two-factor/setup", h.SetupTwoFactor)
+	userSettings.POST("/two-factor/enable", h.EnableTwoFactor)
+	userSettings.POST("/two-factor/disable", h.DisableTwoFactor)
+	userSettings.GET("/passkeys", h.PasskeysPage)
+	userSettings.GET("/passkeys/options", h.PasskeyOptions)
+	userSettings.POST("/passkeys", h.NewPasskey)
+	userSettings.DELETE("/passkeys/:id{uuid}", h.DeletePasskey)
 
 	// event
-	r.POST("/event", h.NewEvent, m.Token, m.DB, m.Session)
-	r.PATCH("/event/:id", h.EditEvent, m.Token, m.DB, m.Session)
-	r.DELETE("/event/:id", h.DeleteEvent, m.Token, m.DB, m.Session)
+	event := app.Group("/event")
+	event.POST("", h.NewEvent)
+	event.PATCH("/:id{uuid}", h.EditEvent)
+	event.DELETE("/:id{uuid}", h.DeleteEvent)
 
 	// ui
-	r.GET("/ui/check", h.CheckUser, m.DB)
-	r.GET("/ui/new-event-form", h.NewEventForm, m.DB)
-	r.GET("/ui/new-event-form-button", h.NewEventFormButton)
-	r.GET("/ui/edit-event-form/:id", h.EditEventForm, m.DB)
-	r.GET("/ui/event-card/:id", h.EventCard, m.DB)
+	ui := r.Group("/ui")
+	ui.GET("/check", h.CheckUser, m.DB)
+	ui.GET("/new-event-form", h.NewEventForm, m.DB)
+	ui.GET("/new-event-form-button", h.NewEventFormButton)
+	ui.GET("/edit-event-form/:id{uuid}", h.EditEventForm, m.DB)
+	ui.GET("/event-card/:id{uuid}", h.EventCard, m.DB)
 
 	// static files
 	r.SetStaticPath("/static", "./web/static")
 
-	port := ":8080"
+	settings, err := serverConfig()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
-	log := logger.Get()
+	eviction, err := evictTokens()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	srv := server.New(settings, r)
+	srv.Go(eviction)
+	srv.Go(mail.Drain)
+	srv.OnShutdown("tracing", shutdownTracing)
+	srv.OnShutdown("database", db.Manager.Close)
 
 	if config.Env.ENVIRONMENT == "production" {
-		fmt.Println("Server started on port " + port);
-		log.Info("Server started on port " + port)
+		fmt.Println("Server started on " + settings.Addr)
 	}
 
-	err := http.ListenAndServe(port, r)
-	if err != nil && config.Env.ENVIRONMENT == "production" {
-		log.Fatal(err.Error())
+	// serve until SIGINT or SIGTERM, then drain the requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = srv.Run(ctx)
+	if err != nil {
+		log.Error(err.Error())
 	}
 }
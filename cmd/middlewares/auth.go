@@ -3,12 +3,16 @@ package middlewares
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"net/url"
+	"pengoe/config"
+	"pengoe/internal/logger"
 	"pengoe/internal/router"
 	"pengoe/internal/services"
 	t "pengoe/internal/token"
 	"pengoe/internal/utils"
+	"time"
 )
 
 /*
@@ -51,6 +55,10 @@ If not logged in, redirects to /signin.
 Otherwise, set context value "token" to the token.
 */
 func Token(next router.HandlerFunc) router.HandlerFunc {
+	return traced("middleware.Token", checkToken)(next)
+}
+
+func checkToken(next router.HandlerFunc) router.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 		token, tokenErr := t.GetSessionFromCookie(r)
 		if tokenErr != nil {
@@ -69,11 +77,52 @@ func Token(next router.HandlerFunc) router.HandlerFunc {
 	}
 }
 
+/*
+endSession deletes the expired session, its token and its cookie,
+and sends the user to sign in again.
+*/
+func endSession(w http.ResponseWriter, r *http.Request, p map[string]string, sessionService services.SessionServiceInterface, sessionId string) error {
+	err := sessionService.Delete(sessionId)
+	if err != nil {
+		router.InternalError(w, r, p)
+		return err
+	}
+
+	err = t.Manager.Delete(sessionId)
+	if err != nil {
+		router.InternalError(w, r, p)
+		return err
+	}
+
+	http.SetCookie(w, t.SessionCookie("", time.Now().Add(-1*time.Hour).UTC(), secureCookies()))
+
+	if r.Method == http.MethodGet {
+		return router.RedirectToSignin(w, r, p)
+	}
+
+	// htmx follows the redirect header only
+	w.Header().Set("HX-Redirect", "/signin")
+	return router.Unauthorized(w, r, p)
+}
+
+func secureCookies() bool {
+	return config.Env.ENVIRONMENT == "production"
+}
+
+/*
+touchInterval is how often the last use of a session is written.
+*/
+const touchInterval = time.Minute
+
 /*
 Session injects the session into the request context.
 It needs WithToken and WithDB to be called before.
 */
 func Session(next router.HandlerFunc) router.HandlerFunc {
+	return traced("middleware.Session", injectSession)(next)
+}
+
+func injectSession(next router.HandlerFunc) router.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 		token, tokenFound := r.Context().Value("token").(*t.Token)
 		if !tokenFound {
@@ -85,14 +134,37 @@ func Session(next router.HandlerFunc) router.HandlerFunc {
 			router.InternalError(w, r, p)
 		}
 
-		sessionService := services.NewSessionService(db)
+		sessionService := services.NewSessionService(r.Context(), db)
 
 		session, sessionErr := sessionService.GetById(token.SessionID)
+		if errors.Is(sessionErr, sql.ErrNoRows) {
+			return endSession(w, r, p, sessionService, token.SessionID)
+		}
 		if sessionErr != nil {
 			router.InternalError(w, r, p)
 			return sessionErr
 		}
 
+		if session.ValidUntil.Before(time.Now()) {
+			return endSession(w, r, p, sessionService, session.Id)
+		}
+
+		// last seen and extended, at most once a minute
+		if time.Since(session.LastSeenAt) > touchInterval {
+			err := sessionService.Touch(session, utils.ClientIP(r))
+			if err != nil {
+				router.InternalError(w, r, p)
+				return err
+			}
+
+			// the cookie of a remembered session is extended too
+			if session.Remember {
+				http.SetCookie(w, t.SessionCookie(session.Id, session.ValidUntil, secureCookies()))
+			}
+		}
+
+		logger.SetUser(r.Context(), session.UserId, session.Id)
+
 		ctx := context.WithValue(r.Context(), "session", session)
 		r = r.WithContext(ctx)
 
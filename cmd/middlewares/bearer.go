@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"pengoe/internal/router"
+	"strings"
+)
+
+/*
+BearerToken lets only the requests with the token in the Authorization
+header through, for the endpoints of machines, like the metrics.
+Eg. r.GET("/metrics", h.Metrics, m.BearerToken(token))
+*/
+func BearerToken(token string) func(router.HandlerFunc) router.HandlerFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+			given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				return router.NewHTTPError(http.StatusUnauthorized, "", errors.New("Invalid bearer token"))
+			}
+
+			return next(w, r, p)
+		}
+	}
+}
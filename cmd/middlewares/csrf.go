@@ -0,0 +1,208 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"pengoe/internal/csrf"
+	"pengoe/internal/router"
+	t "pengoe/internal/token"
+	"pengoe/web/templates/components"
+
+	"github.com/a-h/templ"
+)
+
+/*
+CSRFMode is how the CSRF middleware checks the tokens.
+*/
+type CSRFMode string
+
+const (
+	// the token of the session from the token manager, renewed when it expires
+	CSRFPerSession CSRFMode = "session"
+
+	// like CSRFPerSession, but also renewed after every checked request
+	CSRFPerRequest CSRFMode = "request"
+
+	// a signed token in a cookie, the request sends it back, no server state
+	CSRFDoubleSubmit CSRFMode = "double-submit"
+)
+
+/*
+ParseCSRFMode parses a mode: session, request or double-submit.
+*/
+func ParseCSRFMode(s string) (CSRFMode, error) {
+	switch mode := CSRFMode(s); mode {
+	case CSRFPerSession, CSRFPerRequest, CSRFDoubleSubmit:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Unknown CSRF mode %q", s)
+}
+
+/*
+CSRFOptions configure the CSRF middleware.
+Secret signs the double-submit cookies, Secure sets the Secure flag on them.
+*/
+type CSRFOptions struct {
+	Mode   CSRFMode
+	Secret []byte
+	Secure bool
+}
+
+/*
+csrfCookie is the cookie of the double-submit mode.
+*/
+const csrfCookie = "csrf"
+
+/*
+CSRF returns a middleware that checks the CSRF token of the POST, PATCH and
+DELETE requests. The token comes from the X-CSRF-Token header (set by
+htmx-extensions.js) or the "csrf" field of the form or body.
+An expired token is renewed: the new one is swapped into the #csrf input,
+and the element sends the request again on "csrf-renewed".
+It needs the Token middleware before.
+*/
+func CSRF(options CSRFOptions) func(router.HandlerFunc) router.HandlerFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+			token, found := r.Context().Value("token").(*t.Token)
+			if !found {
+				return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use token middleware"))
+			}
+
+			if options.Mode == CSRFDoubleSubmit {
+				return doubleSubmit(options, token, next, w, r, p)
+			}
+
+			if safeMethod(r.Method) {
+				return next(w, r, p)
+			}
+
+			requestToken, err := csrf.FromRequest(r)
+			if err != nil {
+				return router.NewHTTPError(http.StatusForbidden, "Invalid CSRF token", err)
+			}
+
+			// check and rotate in one step, so concurrent requests can not reuse a token
+			if options.Mode == CSRFPerRequest {
+				newToken, err := t.Manager.VerifyAndRotate(token.SessionID, requestToken)
+				if err != nil {
+					return csrfError(err)
+				}
+
+				// htmx-extensions.js puts it into the #csrf input
+				w.Header().Set(csrf.HeaderName, newToken.Value)
+
+				ctx := context.WithValue(r.Context(), "token", newToken)
+				r = r.WithContext(ctx)
+
+				return next(w, r, p)
+			}
+
+			newToken, err := t.Manager.VerifyOrRenewCSRFToken(token.SessionID, requestToken)
+			if err != nil {
+				return csrfError(err)
+			}
+
+			// expired, the client sends the request again with the new one
+			if newToken != nil {
+				return renewCSRF(w, r, newToken)
+			}
+
+			return next(w, r, p)
+		}
+	}
+}
+
+/*
+renewCSRF renders the renewed token of the session into #csrf.
+*/
+func renewCSRF(w http.ResponseWriter, r *http.Request, newToken *t.Token) error {
+	// plain form posts can not retry, they should reload the page
+	if r.Header.Get("HX-Request") != "true" {
+		return router.NewHTTPError(http.StatusForbidden, "CSRF token expired, reload the page", errors.New("CSRF token expired"))
+	}
+
+	w.Header().Set("HX-Retarget", "#csrf")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	// after the swap, so the request is sent again with the new token
+	w.Header().Set("HX-Trigger-After-Swap", "csrf-renewed")
+
+	component := components.Csrf(components.CsrfProps{Token: newToken})
+	handler := templ.Handler(component)
+	handler.ServeHTTP(w, r)
+
+	return nil
+}
+
+/*
+doubleSubmit checks the token of the request against the signed cookie.
+Safe requests get the cookie if they have no valid one. The "token" context
+value carries the cookie token, so the pages render it into the forms.
+*/
+func doubleSubmit(options CSRFOptions, token *t.Token, next router.HandlerFunc, w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	cookieToken := ""
+	cookie, err := r.Cookie(csrfCookie)
+	if err == nil && csrf.VerifySigned(options.Secret, token.SessionID, cookie.Value) {
+		cookieToken = cookie.Value
+	}
+
+	if safeMethod(r.Method) {
+		if cookieToken == "" {
+			cookieToken, err = csrf.NewSigned(options.Secret, token.SessionID)
+			if err != nil {
+				return router.NewHTTPError(http.StatusInternalServerError, "", err)
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    cookieToken,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   options.Secure,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+	} else {
+		if cookieToken == "" {
+			return router.NewHTTPError(http.StatusForbidden, "Invalid CSRF token", errors.New("CSRF cookie is missing or invalid"))
+		}
+
+		requestToken, err := csrf.FromRequest(r)
+		if err != nil {
+			return router.NewHTTPError(http.StatusForbidden, "Invalid CSRF token", err)
+		}
+
+		if !csrf.Equal(cookieToken, requestToken) {
+			return router.NewHTTPError(http.StatusForbidden, "Invalid CSRF token", errors.New("CSRF tokens do not match"))
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), "token", &t.Token{
+		SessionID: token.SessionID,
+		Value:     cookieToken,
+		Valid:     token.Valid,
+	})
+	r = r.WithContext(ctx)
+
+	return next(w, r, p)
+}
+
+/*
+csrfError is a 403 for a wrong token, and a 500 for a failing store.
+*/
+func csrfError(err error) error {
+	if errors.Is(err, t.ErrInvalidToken) || errors.Is(err, t.ErrTokenNotFound) {
+		return router.NewHTTPError(http.StatusForbidden, "Invalid CSRF token", err)
+	}
+	return router.NewHTTPError(http.StatusInternalServerError, "", err)
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
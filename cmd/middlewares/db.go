@@ -11,6 +11,10 @@ import (
 DB injects the database connection into the request context.
 */
 func DB(next router.HandlerFunc) router.HandlerFunc {
+	return traced("middleware.DB", injectDB)(next)
+}
+
+func injectDB(next router.HandlerFunc) router.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
 		db, err := db.Manager.GetDB()
 		if err != nil {
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"pengoe/internal/router"
+	"pengoe/internal/tracing"
+)
+
+/*
+traced wraps a middleware in a span, from the start of the middleware until
+it calls the next handler, or returns without calling it. The next handlers
+get the context of the middleware (eg. the "db" value), but their spans are
+the children of the request span, not of the middleware.
+*/
+func traced(name string, middleware func(router.HandlerFunc) router.HandlerFunc) func(router.HandlerFunc) router.HandlerFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+			if !tracing.Enabled() {
+				return middleware(next)(w, r, p)
+			}
+
+			parent := tracing.SpanFromContext(r.Context())
+			ctx, span := tracing.Start(r.Context(), name)
+
+			called := false
+			handler := middleware(func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+				called = true
+				span.End()
+
+				r = r.WithContext(tracing.ContextWithSpan(r.Context(), parent))
+				return next(w, r, p)
+			})
+
+			err := handler(w, r.WithContext(ctx), p)
+
+			if !called {
+				if err != nil {
+					span.SetAttributes(tracing.String("error.message", err.Error()))
+				}
+				span.End()
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"pengoe/internal/router"
+	"pengoe/internal/services"
+)
+
+/*
+Verified lets only the users with a verified email through, for the actions
+that involve others, like creating a shared account.
+It needs the DB and Session middlewares before.
+*/
+func Verified(next router.HandlerFunc) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		db, found := r.Context().Value("db").(*sql.DB)
+		if !found {
+			return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use db middleware"))
+		}
+		session, found := r.Context().Value("session").(*services.Session)
+		if !found {
+			return router.NewHTTPError(http.StatusInternalServerError, "", errors.New("Should use session middleware"))
+		}
+
+		userService := services.NewUserService(r.Context(), db)
+
+		user, err := userService.GetById(session.UserId)
+		if err != nil {
+			return router.NewHTTPError(http.StatusInternalServerError, "", err)
+		}
+
+		if !user.Verified() {
+			return router.NewHTTPError(http.StatusForbidden, "Verify your email first", errors.New("Email not verified"))
+		}
+
+		return next(w, r, p)
+	}
+}
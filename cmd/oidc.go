@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"pengoe/config"
+	"pengoe/internal/oidc"
+	"strings"
+)
+
+/*
+setupOIDC sets the OpenID Connect provider of the signin, it is off without
+OIDC_ISSUER. OIDC_CLIENT_ID and OIDC_CLIENT_SECRET are the client registered
+at the provider, with the redirect URL OIDC_REDIRECT_URL (default
+APP_URL/signin/oidc/callback). OIDC_SCOPES are separated by spaces, OIDC_NAME
+(default SSO) is shown on the signin button.
+*/
+func setupOIDC() error {
+	issuer := config.Optional("OIDC_ISSUER", "")
+	if issuer == "" {
+		return nil
+	}
+
+	clientId := config.Optional("OIDC_CLIENT_ID", "")
+	clientSecret := config.Optional("OIDC_CLIENT_SECRET", "")
+	if clientId == "" || clientSecret == "" {
+		return errors.New("OIDC_CLIENT_ID and OIDC_CLIENT_SECRET are required with OIDC_ISSUER")
+	}
+
+	appURL := strings.TrimSuffix(config.Optional("APP_URL", "http://localhost:8080"), "/")
+
+	oidc.Default = oidc.NewProvider(oidc.Config{
+		Name:         config.Optional("OIDC_NAME", "SSO"),
+		Issuer:       issuer,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		RedirectURL:  config.Optional("OIDC_REDIRECT_URL", appURL+"/signin/oidc/callback"),
+		Scopes:       strings.Fields(config.Optional("OIDC_SCOPES", "openid email profile")),
+	})
+
+	return nil
+}
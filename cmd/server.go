@@ -0,0 +1,47 @@
+package main
+
+import (
+	"pengoe/config"
+	"pengoe/internal/server"
+	"strconv"
+	"time"
+)
+
+/*
+serverConfig reads the server configuration from the optional SERVER_* and TLS_* settings.
+*/
+func serverConfig() (server.Config, error) {
+	c := server.Config{
+		Addr:        config.Optional("SERVER_ADDR", ":8080"),
+		TLSCertFile: config.Optional("TLS_CERT_FILE", ""),
+		TLSKeyFile:  config.Optional("TLS_KEY_FILE", ""),
+	}
+
+	durations := []struct {
+		key      string
+		fallback string
+		value    *time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", "15s", &c.ReadTimeout},
+		{"SERVER_READ_HEADER_TIMEOUT", "5s", &c.ReadHeaderTimeout},
+		{"SERVER_WRITE_TIMEOUT", "30s", &c.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", "60s", &c.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", "20s", &c.ShutdownTimeout},
+	}
+
+	for _, duration := range durations {
+		value, err := time.ParseDuration(config.Optional(duration.key, duration.fallback))
+		if err != nil {
+			return c, err
+		}
+		*duration.value = value
+	}
+
+	maxHeaderBytes, err := strconv.Atoi(config.Optional("SERVER_MAX_HEADER_BYTES", "1048576"))
+	if err != nil {
+		return c, err
+	}
+	c.MaxHeaderBytes = maxHeaderBytes
+
+	return c, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"pengoe/config"
+	"pengoe/internal/services"
+	"time"
+)
+
+/*
+setupSessions sets the lifetime of the sessions from the optional settings:
+SESSION_IDLE_TIMEOUT (default 24h) and SESSION_REMEMBER (default 720h) are
+how long a session lasts after its last use, without and with "remember me",
+SESSION_MAX_AGE (default 2160h) is the longest a session lasts after signin.
+*/
+func setupSessions() error {
+	lifetime := services.SessionConfig{}
+
+	durations := []struct {
+		key      string
+		fallback string
+		value    *time.Duration
+	}{
+		{"SESSION_IDLE_TIMEOUT", "24h", &lifetime.Idle},
+		{"SESSION_REMEMBER", "720h", &lifetime.Remember},
+		{"SESSION_MAX_AGE", "2160h", &lifetime.Absolute},
+	}
+
+	for _, duration := range durations {
+		value, err := time.ParseDuration(config.Optional(duration.key, duration.fallback))
+		if err != nil {
+			return err
+		}
+		*duration.value = value
+	}
+
+	services.SessionLifetime = lifetime
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pengoe/config"
+	"pengoe/internal/logger"
+	"pengoe/internal/services"
+	"pengoe/internal/token"
+	"time"
+)
+
+/*
+setupTokens sets the store of the CSRF tokens from the optional TOKEN_STORE
+setting: "db" (default) keeps them in the database, so they survive restarts
+and are shared by the instances, "memory" keeps them in this process only.
+*/
+func setupTokens(db *sql.DB) error {
+	switch config.Optional("TOKEN_STORE", "db") {
+	case "db":
+		token.Manager = token.NewManager(token.NewDBStore(db))
+		return nil
+
+	case "memory":
+		token.Manager = token.NewManager(token.NewMemoryStore())
+
+		// new tokens for the sessions from before the restart
+		sessionService := services.NewSessionService(context.Background(), db)
+		sessions, err := sessionService.GetActives()
+		if err != nil {
+			return err
+		}
+
+		for _, session := range sessions {
+			_, err = token.Manager.Create(session.Id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Unknown token store %q", config.Optional("TOKEN_STORE", "db"))
+}
+
+/*
+evictTokens removes the tokens of the stale sessions every hour, until the
+server shuts down. The memory store drops the tokens not used for TOKEN_TTL
+(default the lifetime of a remembered session), the db store the ones of the
+expired sessions.
+*/
+func evictTokens() (func(ctx context.Context), error) {
+	ttl, err := time.ParseDuration(config.Optional("TOKEN_TTL", services.SessionLifetime.Remember.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				evicted, err := token.Manager.EvictStale(ttl)
+				if err != nil {
+					log := logger.Get()
+					log.Error(err.Error())
+					continue
+				}
+
+				if evicted > 0 {
+					log := logger.Get()
+					log.Info("Stale tokens evicted", "count", evicted)
+				}
+			}
+		}
+	}, nil
+}
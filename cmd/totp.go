@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"pengoe/config"
+	"pengoe/internal/crypt"
+	"pengoe/internal/services"
+)
+
+/*
+setupTOTP sets the key that encrypts the TOTP secrets. It is the optional
+TOTP_ENCRYPTION_KEY (32 bytes, base64), or derived from the JWT_SECRET.
+*/
+func setupTOTP() error {
+	encoded := config.Optional("TOTP_ENCRYPTION_KEY", "")
+	if encoded == "" {
+		services.TOTPKey = crypt.DeriveKey([]byte(config.Env.JWT_SECRET), "totp")
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY is not base64: %w", err)
+	}
+
+	if len(key) != crypt.KeySize {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY should be %d bytes, got %d", crypt.KeySize, len(key))
+	}
+
+	services.TOTPKey = key
+
+	return nil
+}
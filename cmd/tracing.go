@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"pengoe/config"
+	"pengoe/internal/tracing"
+	"strings"
+	"time"
+)
+
+/*
+setupTracing enables tracing with the exporters of the optional TRACE_EXPORTERS
+setting, a comma separated list of "otlp", "stdout" and "file". Tracing is
+disabled without exporters.
+*/
+func setupTracing() error {
+	exporters := []tracing.Exporter{}
+
+	for _, name := range strings.Split(config.Optional("TRACE_EXPORTERS", ""), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+
+		case "otlp":
+			endpoint := config.Optional("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+			endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+
+			headers, err := parseHeaders(config.Optional("OTEL_EXPORTER_OTLP_HEADERS", ""))
+			if err != nil {
+				return err
+			}
+
+			exporters = append(exporters, tracing.NewOTLPExporter(endpoint, headers))
+
+		case "stdout":
+			exporters = append(exporters, tracing.NewWriterExporter(os.Stdout))
+
+		case "file":
+			exporter, err := tracing.NewFileExporter(config.Optional("TRACE_FILE", "logs/traces.jsonl"))
+			if err != nil {
+				return err
+			}
+			exporters = append(exporters, exporter)
+
+		default:
+			return fmt.Errorf("Unknown trace exporter %q", name)
+		}
+	}
+
+	flushInterval, err := time.ParseDuration(config.Optional("TRACE_FLUSH_INTERVAL", "5s"))
+	if err != nil {
+		return err
+	}
+
+	return tracing.Setup(tracing.Options{
+		ServiceName:   config.Optional("OTEL_SERVICE_NAME", "pengoe"),
+		Exporters:     exporters,
+		FlushInterval: flushInterval,
+	})
+}
+
+/*
+shutdownTracing exports the last spans, at shutdown.
+*/
+func shutdownTracing() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return tracing.Shutdown(ctx)
+}
+
+/*
+parseHeaders parses the headers of the collector requests, eg. "x-api-key=secret,x-team=money".
+*/
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(item, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("Invalid header %q", item)
+		}
+
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return headers, nil
+}
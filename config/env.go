@@ -33,3 +33,15 @@ func newAppConfig() *appConfig {
 }
 
 var Env = newAppConfig()
+
+/*
+Optional returns an optional setting from the environment (or the .env file),
+or the fallback if it is not set.
+*/
+func Optional(key, fallback string) string {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return fallback
+	}
+	return value
+}
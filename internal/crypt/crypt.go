@@ -0,0 +1,90 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+/*
+KeySize is the size of the keys, for AES-256.
+*/
+const KeySize = 32
+
+/*
+ErrDecrypt is returned if the ciphertext was not encrypted with the key,
+or was changed.
+*/
+var ErrDecrypt = errors.New("Could not decrypt")
+
+/*
+DeriveKey returns a key for the purpose from a secret, so one setting can
+give different keys for different data. Eg. DeriveKey(secret, "totp")
+*/
+func DeriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("pengoe " + purpose))
+	return mac.Sum(nil)
+}
+
+/*
+Encrypt encrypts the plaintext with AES-GCM, the result is the random nonce
+and the ciphertext, base64 encoded for the database.
+*/
+func Encrypt(key, plaintext []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+/*
+Decrypt decrypts the result of Encrypt.
+*/
+func Decrypt(key []byte, ciphertext string) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("Invalid key size")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
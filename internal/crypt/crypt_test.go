@@ -0,0 +1,64 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := DeriveKey([]byte("secret"), "totp")
+
+	ciphertext, err := Encrypt(key, []byte("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if bytes.Contains([]byte(ciphertext), []byte("JBSWY3DPEHPK3PXP")) {
+		t.Errorf("Expected the plaintext to be hidden, got %q", ciphertext)
+	}
+
+	other, _ := Encrypt(key, []byte("JBSWY3DPEHPK3PXP"))
+	if ciphertext == other {
+		t.Errorf("Expected different ciphertexts with random nonces")
+	}
+
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if string(plaintext) != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected the plaintext, got %q", plaintext)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := DeriveKey([]byte("secret"), "totp")
+	otherKey := DeriveKey([]byte("secret"), "other")
+
+	if bytes.Equal(key, otherKey) {
+		t.Fatalf("Expected different keys for different purposes")
+	}
+
+	ciphertext, _ := Encrypt(key, []byte("plaintext"))
+
+	_, err := Decrypt(otherKey, ciphertext)
+	if !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt with another key, got %v", err)
+	}
+
+	// flip a bit of the ciphertext
+	tampered := []byte(ciphertext)
+	tampered[len(tampered)/2] ^= 1
+
+	_, err = Decrypt(key, string(tampered))
+	if err == nil {
+		t.Errorf("Expected error for a changed ciphertext")
+	}
+
+	_, err = Decrypt(key, "short")
+	if err == nil {
+		t.Errorf("Expected error for a short ciphertext")
+	}
+}
@@ -0,0 +1,161 @@
+package csrf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// HeaderName is the header htmx sends the token in, see htmx-extensions.js
+	HeaderName = "X-CSRF-Token"
+
+	// FieldName is the name of the hidden input of the forms
+	FieldName = "csrf"
+
+	// maxBodySize is the largest urlencoded or JSON body read for the token
+	maxBodySize = 1 << 20
+
+	// maxMultipartSize is the largest multipart body read for the token,
+	// the size of the backup uploads
+	maxMultipartSize = 32 << 20
+)
+
+/*
+ErrMissingToken is returned if the request has no token.
+*/
+var ErrMissingToken = errors.New("CSRF token is missing")
+
+/*
+FromRequest is a function that returns the token of the request: the header,
+or the "csrf" field of a form, multipart form or JSON body. The body of
+DELETE requests (not parsed by net/http) is read and put back, so the
+handler can read it again.
+*/
+func FromRequest(r *http.Request) (string, error) {
+	token := r.Header.Get(HeaderName)
+	if token != "" {
+		return token, nil
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", ErrMissingToken
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(nil, r.Body, maxMultipartSize)
+		err := r.ParseMultipartForm(maxBodySize)
+		if err != nil {
+			return "", err
+		}
+		token = r.FormValue(FieldName)
+
+	case "application/json":
+		body, err := peekBody(r)
+		if err != nil {
+			return "", err
+		}
+
+		var fields map[string]any
+		if json.Unmarshal(body, &fields) == nil {
+			token, _ = fields[FieldName].(string)
+		}
+
+	default:
+		body, err := peekBody(r)
+		if err != nil {
+			return "", err
+		}
+
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", err
+		}
+		token = values.Get(FieldName)
+	}
+
+	if token == "" {
+		return "", ErrMissingToken
+	}
+
+	return token, nil
+}
+
+/*
+peekBody is a function that reads the body, and puts it back for the handler.
+*/
+func peekBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBodySize {
+		return nil, errors.New("Request body is too large for the CSRF check")
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+/*
+Equal is a function that compares two tokens in constant time.
+*/
+func Equal(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+/*
+NewSigned is a function that returns a token for the double-submit cookie:
+a random value and its signature with the session id, "<random>.<signature>".
+The signature binds the cookie to the session, so a cookie set by a
+subdomain or another session is refused.
+*/
+func NewSigned(secret []byte, sessionId string) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	random := base64.RawURLEncoding.EncodeToString(b)
+	return random + "." + sign(secret, sessionId, random), nil
+}
+
+/*
+VerifySigned is a function that checks the signature of a double-submit token.
+*/
+func VerifySigned(secret []byte, sessionId, token string) bool {
+	random, signature, found := strings.Cut(token, ".")
+	if !found || random == "" {
+		return false
+	}
+
+	expected := sign(secret, sessionId, random)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func sign(secret []byte, sessionId, random string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sessionId))
+	mac.Write([]byte{0})
+	mac.Write([]byte(random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,121 @@
+package csrf
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	multipartBody := &bytes.Buffer{}
+	writer := multipart.NewWriter(multipartBody)
+	writer.WriteField("csrf", "from-multipart")
+	writer.Close()
+
+	tests := []struct {
+		method      string
+		contentType string
+		header      string
+		body        string
+		expected    string
+	}{
+		{"POST", "application/x-www-form-urlencoded", "from-header", "csrf=from-form", "from-header"},
+		{"POST", "application/x-www-form-urlencoded", "", "name=a&csrf=from-form", "from-form"},
+		{"PATCH", "application/x-www-form-urlencoded; charset=UTF-8", "", "csrf=from-patch", "from-patch"},
+		{"DELETE", "application/x-www-form-urlencoded", "", "csrf=from-delete&account_id=acc_1", "from-delete"},
+		{"DELETE", "", "", "csrf=no-content-type", "no-content-type"},
+		{"POST", "application/json", "", `{"csrf":"from-json","name":"a"}`, "from-json"},
+		{"POST", writer.FormDataContentType(), "", multipartBody.String(), "from-multipart"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		if test.header != "" {
+			r.Header.Set(HeaderName, test.header)
+		}
+
+		token, err := FromRequest(r)
+		if err != nil {
+			t.Errorf("Expected no error for %s %q, got %v", test.method, test.body, err)
+			continue
+		}
+
+		if token != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, token)
+		}
+	}
+}
+
+func TestFromRequestKeepsBody(t *testing.T) {
+	r := httptest.NewRequest("DELETE", "/", strings.NewReader("csrf=token&account_id=acc_1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err := FromRequest(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != "csrf=token&account_id=acc_1" {
+		t.Errorf("Expected the body to be readable again, got %q", body)
+	}
+}
+
+func TestFromRequestMissing(t *testing.T) {
+	requests := []*http.Request{
+		httptest.NewRequest("POST", "/", nil),
+		httptest.NewRequest("POST", "/", strings.NewReader("name=a")),
+		httptest.NewRequest("POST", "/", strings.NewReader(`{"csrf":1}`)),
+	}
+	requests[2].Header.Set("Content-Type", "application/json")
+
+	for _, r := range requests {
+		_, err := FromRequest(r)
+		if err == nil {
+			t.Errorf("Expected error")
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal("abc", "abc") {
+		t.Errorf("Expected equal tokens")
+	}
+	if Equal("abc", "abd") || Equal("", "") {
+		t.Errorf("Expected different tokens")
+	}
+}
+
+func TestSigned(t *testing.T) {
+	secret := []byte("secret")
+
+	token, err := NewSigned(secret, "ses_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !VerifySigned(secret, "ses_1", token) {
+		t.Errorf("Expected %q to be valid", token)
+	}
+
+	if VerifySigned(secret, "ses_2", token) {
+		t.Errorf("Expected %q to be invalid for another session", token)
+	}
+
+	if VerifySigned([]byte("other"), "ses_1", token) {
+		t.Errorf("Expected %q to be invalid with another secret", token)
+	}
+
+	for _, invalid := range []string{"", ".", "abc", token + "x", "x" + token} {
+		if VerifySigned(secret, "ses_1", invalid) {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"pengoe/internal/db/script"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Migrations are the changes to the schema of existing databases,
+applied in the order of their file names. New databases get the full
+schema.sqlite, which already contains them (so they should be idempotent,
+or be recorded as applied in schema.sqlite, like the ALTER TABLE ones).
+*/
+//go:embed migrations/*.sqlite
+var migrations embed.FS
+
+/*
+Migrate is a function that applies the migrations not applied yet,
+and records them in the schema_migrations table.
+*/
+func Migrate(db *sql.DB) ([]string, error) {
+	_, err := db.Exec(
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT NOT NULL PRIMARY KEY,
+			applied_at DATETIME NOT NULL
+		);`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := PendingMigrations(context.Background(), db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range pending {
+		err := applyMigration(db, version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pending, nil
+}
+
+/*
+PendingMigrations is a function that returns the versions of the migrations
+not applied to the database yet. The query stops when ctx is done.
+*/
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]string, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := []string{}
+	for _, version := range versions {
+		if !applied[version] {
+			pending = append(pending, version)
+		}
+	}
+
+	return pending, nil
+}
+
+/*
+migrationVersions is a function that returns the embedded migrations in order,
+named by their file name without the extension. Eg. 0001_event_indexes
+*/
+func migrationVersions() ([]string, error) {
+	files, err := fs.Glob(migrations, "migrations/*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		versions = append(versions, strings.TrimSuffix(name, ".sqlite"))
+	}
+
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func applyMigration(db *sql.DB, version string) error {
+	content, err := migrations.ReadFile("migrations/" + version + ".sqlite")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range script.Split(string(content)) {
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?);`,
+		version,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,139 @@
+package script
+
+import "strings"
+
+/*
+Split is a function that splits a script into its statements,
+as the driver executes one statement at a time. Semicolons in quotes,
+comments and trigger bodies do not end a statement, the comments are dropped.
+*/
+func Split(content string) []string {
+	statements := []string{}
+	statement := strings.Builder{}
+
+	// the words of the statement so far, to find the trigger bodies
+	words := []string{}
+	word := strings.Builder{}
+	depth := 0
+
+	endWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+
+		w := strings.ToUpper(word.String())
+		word.Reset()
+		words = append(words, w)
+
+		if !isTrigger(words) {
+			return
+		}
+
+		switch w {
+		case "BEGIN", "CASE":
+			depth++
+		case "END":
+			depth--
+		}
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		// line comment
+		case c == '-' && i+1 < len(content) && content[i+1] == '-':
+			endWord()
+			end := strings.IndexByte(content[i:], '\n')
+			if end == -1 {
+				i = len(content)
+			} else {
+				i += end
+			}
+			statement.WriteByte('\n')
+
+		// block comment
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			endWord()
+			end := strings.Index(content[i+2:], "*/")
+			if end == -1 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+			statement.WriteByte(' ')
+
+		// quoted string or identifier, with the quote doubled to escape it
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			endWord()
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+
+			start := i
+			for i++; i < len(content); i++ {
+				if content[i] != closing {
+					continue
+				}
+				if closing != ']' && i+1 < len(content) && content[i+1] == closing {
+					i++
+					continue
+				}
+				break
+			}
+			if i >= len(content) {
+				i = len(content) - 1
+			}
+			statement.WriteString(content[start : i+1])
+
+		case c == ';':
+			endWord()
+			statement.WriteByte(c)
+			if depth > 0 {
+				continue
+			}
+
+			trimmed := strings.TrimSpace(statement.String())
+			if trimmed != ";" {
+				statements = append(statements, trimmed)
+			}
+			statement.Reset()
+			words = words[:0]
+			depth = 0
+
+		default:
+			if isWordByte(c) {
+				word.WriteByte(c)
+			} else {
+				endWord()
+			}
+			statement.WriteByte(c)
+		}
+	}
+
+	endWord()
+	trimmed := strings.TrimSpace(statement.String())
+	if trimmed != "" {
+		statements = append(statements, trimmed+";")
+	}
+
+	return statements
+}
+
+/*
+isTrigger reports if the words start a CREATE [TEMP] TRIGGER statement.
+*/
+func isTrigger(words []string) bool {
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	if words[1] == "TEMP" || words[1] == "TEMPORARY" {
+		return len(words) > 2 && words[2] == "TRIGGER"
+	}
+	return words[1] == "TRIGGER"
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,50 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			"statements and comments",
+			"-- comment; with a semicolon\nCREATE TABLE a (id TEXT);\n/* block; comment */\nCREATE INDEX a_id ON a (id);\n",
+			[]string{"CREATE TABLE a (id TEXT);", "CREATE INDEX a_id ON a (id);"},
+		},
+		{
+			"semicolon in strings",
+			"INSERT INTO a (id) VALUES ('x;y'), ('it''s;');\nUPDATE a SET id = \"b;c\";",
+			[]string{"INSERT INTO a (id) VALUES ('x;y'), ('it''s;');", "UPDATE a SET id = \"b;c\";"},
+		},
+		{
+			"comment markers in strings",
+			"INSERT INTO a (id) VALUES ('--not a comment;');",
+			[]string{"INSERT INTO a (id) VALUES ('--not a comment;');"},
+		},
+		{
+			"trigger body",
+			"CREATE TRIGGER a_touch AFTER UPDATE ON a\nBEGIN\n  UPDATE a SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM b;\nEND;\nDROP TABLE c;",
+			[]string{
+				"CREATE TRIGGER a_touch AFTER UPDATE ON a\nBEGIN\n  UPDATE a SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM b;\nEND;",
+				"DROP TABLE c;",
+			},
+		},
+		{
+			"missing last semicolon",
+			"DROP TABLE a;\nDROP TABLE b\n",
+			[]string{"DROP TABLE a;", "DROP TABLE b;"},
+		},
+	}
+
+	for _, test := range tests {
+		result := Split(test.content)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: Expected %q, got %q", test.name, test.expected, result)
+		}
+	}
+}
@@ -61,3 +61,13 @@ func (manager *dbManager) connect() error {
 	manager.db = db
 	return nil
 }
+
+/*
+Close is a function that closes the database connection, if it was opened.
+*/
+func (manager *dbManager) Close() error {
+	if manager.db == nil {
+		return nil
+	}
+	return manager.db.Close()
+}
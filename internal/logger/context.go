@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+/*
+requestFields are the ids attached to every log line of a request.
+They are shared by pointer, so ids set deeper in the middleware chain
+(like the user after authentication) are seen by the whole request.
+*/
+type requestFields struct {
+	mutex     sync.RWMutex
+	requestId string
+	userId    string
+	sessionId string
+}
+
+/*
+WithRequestId returns a context that carries the request id for the logs.
+*/
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestFields{requestId: requestId})
+}
+
+/*
+SetUser adds the user and session ids to the logs of the request.
+*/
+func SetUser(ctx context.Context, userId, sessionId string) {
+	fields, found := ctx.Value(contextKey{}).(*requestFields)
+	if !found {
+		return
+	}
+
+	fields.mutex.Lock()
+	defer fields.mutex.Unlock()
+
+	fields.userId = userId
+	fields.sessionId = sessionId
+}
+
+/*
+RequestId returns the request id from the context, or an empty string.
+*/
+func RequestId(ctx context.Context) string {
+	fields, found := ctx.Value(contextKey{}).(*requestFields)
+	if !found {
+		return ""
+	}
+
+	fields.mutex.RLock()
+	defer fields.mutex.RUnlock()
+
+	return fields.requestId
+}
+
+/*
+UserId returns the user id from the context, or an empty string.
+*/
+func UserId(ctx context.Context) string {
+	fields, found := ctx.Value(contextKey{}).(*requestFields)
+	if !found {
+		return ""
+	}
+
+	fields.mutex.RLock()
+	defer fields.mutex.RUnlock()
+
+	return fields.userId
+}
+
+/*
+contextAttrs returns the ids of the request as log attributes.
+*/
+func contextAttrs(ctx context.Context) []any {
+	fields, found := ctx.Value(contextKey{}).(*requestFields)
+	if !found {
+		return nil
+	}
+
+	fields.mutex.RLock()
+	defer fields.mutex.RUnlock()
+
+	attrs := []any{"request_id", fields.requestId}
+	if fields.userId != "" {
+		attrs = append(attrs, "user_id", fields.userId)
+	}
+	if fields.sessionId != "" {
+		attrs = append(attrs, "session_id", fields.sessionId)
+	}
+
+	return attrs
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestContextAttrs(t *testing.T) {
+	ctx := WithRequestId(context.Background(), "req_1")
+
+	// set on the same request, deeper in the middleware chain
+	SetUser(ctx, "usr_1", "ses_1")
+
+	expected := fmt.Sprint([]any{"request_id", "req_1", "user_id", "usr_1", "session_id", "ses_1"})
+
+	result := fmt.Sprint(contextAttrs(ctx))
+	if result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+
+	if RequestId(ctx) != "req_1" {
+		t.Errorf("Expected req_1, got %s", RequestId(ctx))
+	}
+
+	if UserId(ctx) != "usr_1" {
+		t.Errorf("Expected usr_1, got %s", UserId(ctx))
+	}
+}
+
+func TestContextAttrsWithoutRequest(t *testing.T) {
+	ctx := context.Background()
+
+	SetUser(ctx, "usr_1", "ses_1")
+
+	if attrs := contextAttrs(ctx); attrs != nil {
+		t.Errorf("Expected no attributes, got %v", attrs)
+	}
+}
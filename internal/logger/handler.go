@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+/*
+packageHandler filters the log lines by the level of the package logging them.
+The package is found from the program counter of the record, and cached.
+*/
+type packageHandler struct {
+	handler  slog.Handler
+	level    slog.Level
+	packages map[string]slog.Level
+	minimum  slog.Level
+	cache    *sync.Map // pc -> slog.Level
+}
+
+func newPackageHandler(handler slog.Handler, level slog.Level, packages map[string]slog.Level) *packageHandler {
+	minimum := level
+	for _, packageLevel := range packages {
+		if packageLevel < minimum {
+			minimum = packageLevel
+		}
+	}
+
+	return &packageHandler{
+		handler:  handler,
+		level:    level,
+		packages: packages,
+		minimum:  minimum,
+		cache:    &sync.Map{},
+	}
+}
+
+/*
+Enabled lets through the lowest level of all packages,
+Handle filters by the package.
+*/
+func (h *packageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.minimum && h.handler.Enabled(ctx, level)
+}
+
+func (h *packageHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < h.levelOf(record.PC) {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithGroup(name)
+	return &clone
+}
+
+func (h *packageHandler) levelOf(pc uintptr) slog.Level {
+	if len(h.packages) == 0 || pc == 0 {
+		return h.level
+	}
+
+	if level, found := h.cache.Load(pc); found {
+		return level.(slog.Level)
+	}
+
+	frames := runtime.CallersFrames([]uintptr{pc})
+	frame, _ := frames.Next()
+	pkg := packageName(frame.Function)
+
+	level := h.level
+	if packageLevel, found := h.packages[pkg]; found {
+		level = packageLevel
+	} else if packageLevel, found := h.packages[pkg[strings.LastIndex(pkg, "/")+1:]]; found {
+		level = packageLevel
+	}
+
+	h.cache.Store(pc, level)
+	return level
+}
+
+/*
+packageName returns the import path of the package of a function.
+Eg. pengoe/internal/router.(*Router).ServeHTTP -> pengoe/internal/router
+*/
+func packageName(function string) string {
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	if dot < 0 {
+		return function
+	}
+	return function[:slash+1+dot]
+}
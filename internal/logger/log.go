@@ -6,8 +6,10 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/samber/slog-multi"
@@ -21,8 +23,6 @@ var levelNames = map[slog.Leveler]string{
 	levelFatal: "FATAL",
 }
 
-var LogLevelFlag string
-
 type myLogger struct {
 	Debug func(msg string, args ...any)
 	Info  func(msg string, args ...any)
@@ -31,50 +31,168 @@ type myLogger struct {
 	Fatal func(msg string, args ...any)
 }
 
+/*
+Options configure the process-wide logger.
+Level is the minimum level of the log lines, PackageLevels override it for the
+packages logging, by import path or by the last element of it (eg. "router").
+Sinks are the outputs, every sink can have its own format and minimum level.
+*/
+type Options struct {
+	Level         slog.Level
+	PackageLevels map[string]slog.Level
+	Sinks         []Sink
+}
+
+var (
+	// the process-wide logger, JSON to stderr until Setup is called
+	current atomic.Pointer[slog.Logger]
+
+	// the files of the sinks, closed by Close
+	closers      []io.Closer
+	closersMutex sync.Mutex
+)
+
+func init() {
+	current.Store(slog.New(newHandler(os.Stderr, FormatJSON, slog.LevelInfo)))
+}
+
+/*
+Setup replaces the process-wide logger with one built from the options.
+The files of the previous logger are closed.
+*/
+func Setup(options Options) error {
+	handlers := []slog.Handler{}
+	files := []io.Closer{}
+
+	for _, sink := range options.Sinks {
+		w, err := sink.writer()
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+			return err
+		}
+
+		if file, ok := w.(io.Closer); ok && sink.Output == OutputFile {
+			files = append(files, file)
+		}
+
+		handlers = append(handlers, newHandler(w, sink.Format, sink.Level))
+	}
+
+	handler := newPackageHandler(slogmulti.Fanout(handlers...), options.Level, options.PackageLevels)
+	current.Store(slog.New(handler))
+
+	closersMutex.Lock()
+	previous := closers
+	closers = files
+	closersMutex.Unlock()
+
+	for _, file := range previous {
+		file.Close()
+	}
+
+	return nil
+}
+
+/*
+Close closes the log files, call it at shutdown.
+*/
+func Close() error {
+	closersMutex.Lock()
+	defer closersMutex.Unlock()
+
+	var err error
+	for _, file := range closers {
+		if closeErr := file.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	closers = nil
+
+	return err
+}
+
+/*
+Get returns the process-wide logger.
+*/
 func Get() myLogger {
-	var logLevel slog.Level
+	return wrap(current.Load())
+}
 
-	switch strings.ToUpper(LogLevelFlag) {
+/*
+FromContext returns a logger that adds the request, user and session ids
+of the request context to every log line.
+*/
+func FromContext(ctx context.Context) myLogger {
+	return wrap(current.Load().With(contextAttrs(ctx)...))
+}
+
+/*
+ParseLevel parses a level name: DEBUG, INFO, WARNING (or WARN), ERROR or FATAL.
+*/
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(s) {
 	case "DEBUG":
-		logLevel = slog.LevelDebug
+		return slog.LevelDebug, nil
 	case "INFO":
-		logLevel = slog.LevelInfo
-	case "WARNING":
-		logLevel = slog.LevelWarn
+		return slog.LevelInfo, nil
+	case "WARNING", "WARN":
+		return slog.LevelWarn, nil
 	case "ERROR":
-		logLevel = slog.LevelError
+		return slog.LevelError, nil
 	case "FATAL":
-		logLevel = levelFatal
-	default:
-		logLevel = slog.LevelInfo
+		return levelFatal, nil
 	}
 
-	now := time.Now().UTC().Format("2006-01-02")
-
-	logDir := "logs"
-	logFile := fmt.Sprintf("server-%s.log", now)
-	logPath := filepath.Join(logDir, logFile)
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
 
-	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("Error opening log file:", err)
+/*
+ParsePackageLevels parses levels by package, eg. "router=DEBUG,services=WARNING".
+*/
+func ParsePackageLevels(s string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pkg, levelStr, found := strings.Cut(item, "=")
+		if !found || pkg == "" {
+			return nil, fmt.Errorf("Invalid package level %q", item)
+		}
+
+		level, err := ParseLevel(levelStr)
+		if err != nil {
+			return nil, err
+		}
+
+		levels[pkg] = level
 	}
 
-	// TODO: include request id
-	all := newJSONHandler(file, logLevel)
-	error := newJSONHandler(os.Stderr, slog.LevelError)
-
-	logger := *slog.New(slogmulti.Fanout(all, error))
-
-	var ctx = context.Background()
+	return levels, nil
+}
 
+func wrap(logger *slog.Logger) myLogger {
 	myLogger := myLogger{
 		Debug: logger.Debug,
 		Info:  logger.Info,
 		Warn:  logger.Warn,
 		Error: logger.Error,
 		Fatal: func(msg string, args ...any) {
-			logger.Log(ctx, levelFatal, msg, args...)
+			// the source is the caller, not this function
+			var pcs [1]uintptr
+			runtime.Callers(2, pcs[:])
+
+			record := slog.NewRecord(time.Now(), levelFatal, msg, pcs[0])
+			record.Add(args...)
+			logger.Handler().Handle(context.Background(), record)
+
 			os.Exit(1)
 		},
 	}
@@ -82,8 +200,8 @@ func Get() myLogger {
 	return myLogger
 }
 
-func newJSONHandler(w io.Writer, level slog.Level) slog.Handler {
-	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+func newHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{
 		Level:     level,
 		AddSource: true,
 		// search the custom log level name, like "FATAL"
@@ -100,5 +218,11 @@ func newJSONHandler(w io.Writer, level slog.Level) slog.Handler {
 
 			return a
 		},
-	})
+	}
+
+	if format == FormatText {
+		return slog.NewTextHandler(w, options)
+	}
+
+	return slog.NewJSONHandler(w, options)
 }
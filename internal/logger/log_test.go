@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"WARNING": slog.LevelWarn,
+		"warn":    slog.LevelWarn,
+		"ERROR":   slog.LevelError,
+		"FATAL":   levelFatal,
+	}
+
+	for s, expected := range tests {
+		result, err := ParseLevel(s)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", s, err)
+		}
+		if result != expected {
+			t.Errorf("Expected %v for %q, got %v", expected, s, result)
+		}
+	}
+
+	_, err := ParseLevel("LOUD")
+	if err == nil {
+		t.Errorf("Expected error for LOUD")
+	}
+}
+
+func TestParsePackageLevels(t *testing.T) {
+	result, err := ParsePackageLevels("router=DEBUG, pengoe/internal/services=ERROR")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result["router"] != slog.LevelDebug || result["pengoe/internal/services"] != slog.LevelError {
+		t.Errorf("Expected router DEBUG and services ERROR, got %v", result)
+	}
+
+	_, err = ParsePackageLevels("router")
+	if err == nil {
+		t.Errorf("Expected error without a level")
+	}
+}
+
+func TestParseSinks(t *testing.T) {
+	file := FileOptions{Dir: "logs"}
+
+	result, err := ParseSinks("file, stderr:text:ERROR", file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 sinks, got %d", len(result))
+	}
+
+	if result[0].Output != OutputFile || result[0].Format != FormatJSON || result[0].Level != slog.LevelDebug || result[0].File.Dir != "logs" {
+		t.Errorf("Expected json file sink, got %+v", result[0])
+	}
+
+	if result[1].Output != OutputStderr || result[1].Format != FormatText || result[1].Level != slog.LevelError {
+		t.Errorf("Expected text stderr error sink, got %+v", result[1])
+	}
+
+	for _, s := range []string{"socket", "stdout:xml", "stdout:json:LOUD", "stdout:json:INFO:more"} {
+		_, err := ParseSinks(s, file)
+		if err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestPackageHandler(t *testing.T) {
+	var out bytes.Buffer
+
+	handler := newPackageHandler(
+		newHandler(&out, FormatJSON, slog.LevelDebug),
+		slog.LevelWarn,
+		map[string]slog.Level{"logger": slog.LevelDebug},
+	)
+	log := slog.New(handler)
+
+	// this package logs from DEBUG
+	log.Debug("from logger")
+
+	if !strings.Contains(out.String(), "from logger") {
+		t.Errorf("Expected the debug line of the package, got %q", out.String())
+	}
+
+	// the default level applies to other packages
+	out.Reset()
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "from elsewhere", 0)
+	handler.Handle(context.Background(), record)
+
+	if out.Len() != 0 {
+		t.Errorf("Expected no line below WARN, got %q", out.String())
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tests := map[string]string{
+		"pengoe/internal/router.(*Router).ServeHTTP": "pengoe/internal/router",
+		"pengoe/internal/logger.Get":                 "pengoe/internal/logger",
+		"main.main":                                  "main",
+		"github.com/a-h/templ.Handler.func1":         "github.com/a-h/templ",
+	}
+
+	for function, expected := range tests {
+		result := packageName(function)
+		if result != expected {
+			t.Errorf("Expected %q, got %q", expected, result)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+FileOptions configure the log files.
+Files are named <Dir>/<Name>-YYYY-MM-DD.log, a new file is started every day
+(UTC), and when the file would grow over MaxSize bytes (0 means no limit).
+The full file is renamed to <Name>-YYYY-MM-DD.N.log. Files older than MaxAge
+are deleted (0 means they are kept).
+*/
+type FileOptions struct {
+	Dir     string
+	Name    string
+	MaxSize int64
+	MaxAge  time.Duration
+}
+
+type rotatingFile struct {
+	mutex   sync.Mutex
+	options FileOptions
+	now     func() time.Time
+	file    *os.File
+	date    string
+	size    int64
+}
+
+func newRotatingFile(options FileOptions) (*rotatingFile, error) {
+	if options.Dir == "" {
+		options.Dir = "logs"
+	}
+	if options.Name == "" {
+		options.Name = "server"
+	}
+
+	f := &rotatingFile{
+		options: options,
+		now:     time.Now,
+	}
+
+	err := f.open(f.now().UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	date := f.now().UTC().Format("2006-01-02")
+
+	switch {
+	case f.file == nil || date != f.date:
+		err := f.open(date)
+		if err != nil {
+			return 0, err
+		}
+	case f.options.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.options.MaxSize:
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rotatingFile) path(date string) string {
+	return filepath.Join(f.options.Dir, fmt.Sprintf("%s-%s.log", f.options.Name, date))
+}
+
+/*
+open closes the current file, and opens (or continues) the file of the date.
+*/
+func (f *rotatingFile) open(date string) error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+
+	err := os.MkdirAll(f.options.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path(date), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.date = date
+	f.size = info.Size()
+
+	f.cleanup()
+
+	return nil
+}
+
+/*
+rotate renames the full file of the day to the next free index, and starts a new one.
+*/
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	current := f.path(f.date)
+	for i := 1; ; i++ {
+		rotated := strings.TrimSuffix(current, ".log") + fmt.Sprintf(".%d.log", i)
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			err := os.Rename(current, rotated)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	return f.open(f.date)
+}
+
+/*
+cleanup deletes the log files older than MaxAge.
+*/
+func (f *rotatingFile) cleanup() {
+	if f.options.MaxAge <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(f.options.Dir)
+	if err != nil {
+		return
+	}
+
+	limit := f.now().Add(-f.options.MaxAge)
+	active := filepath.Base(f.path(f.date))
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == active || !strings.HasPrefix(name, f.options.Name+"-") || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(limit) {
+			continue
+		}
+
+		os.Remove(filepath.Join(f.options.Dir, name))
+	}
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func listLogs(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+
+	f := &rotatingFile{
+		options: FileOptions{Dir: dir, Name: "server", MaxSize: 10},
+		now:     func() time.Time { return time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC) },
+	}
+	defer f.Close()
+
+	for _, line := range []string{"12345\n", "12345\n", "12345\n"} {
+		_, err := f.Write([]byte(line))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	expected := []string{"server-2024-01-02.1.log", "server-2024-01-02.2.log", "server-2024-01-02.log"}
+
+	result := listLogs(t, dir)
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	}
+}
+
+func TestRotatingFileDaily(t *testing.T) {
+	dir := t.TempDir()
+
+	now := time.Date(2024, 1, 2, 23, 59, 0, 0, time.UTC)
+
+	f := &rotatingFile{
+		options: FileOptions{Dir: dir, Name: "server"},
+		now:     func() time.Time { return now },
+	}
+	defer f.Close()
+
+	f.Write([]byte("first\n"))
+
+	now = now.Add(2 * time.Minute)
+	f.Write([]byte("second\n"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "server-2024-01-03.log"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if string(data) != "second\n" {
+		t.Errorf("Expected %q, got %q", "second\n", string(data))
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	old := filepath.Join(dir, "server-2023-12-01.log")
+	recent := filepath.Join(dir, "server-2023-12-30.log")
+	other := filepath.Join(dir, "access.log")
+
+	for _, path := range []string{old, recent, other} {
+		os.WriteFile(path, []byte("line\n"), 0644)
+	}
+
+	now := time.Now()
+	os.Chtimes(old, now.AddDate(0, 0, -30), now.AddDate(0, 0, -30))
+	os.Chtimes(other, now.AddDate(0, 0, -30), now.AddDate(0, 0, -30))
+
+	f, err := newRotatingFile(FileOptions{Dir: dir, Name: "server", MaxAge: 14 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer f.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be deleted", old)
+	}
+
+	for _, path := range []string{recent, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept", path)
+		}
+	}
+}
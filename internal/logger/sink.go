@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+/*
+Sink is an output of the logger, with its format and minimum level.
+File is used by the file outputs only.
+*/
+type Sink struct {
+	Output string
+	Format string
+	Level  slog.Level
+	File   FileOptions
+}
+
+/*
+ParseSinks parses a comma separated list of sinks, each as output[:format[:level]].
+The default format is json, the default level lets everything through,
+eg. "file:json,stderr:text:ERROR". File sinks use the file options.
+*/
+func ParseSinks(s string, file FileOptions) ([]Sink, error) {
+	sinks := []Sink{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("Invalid log sink %q", item)
+		}
+
+		sink := Sink{
+			Output: parts[0],
+			Format: FormatJSON,
+			Level:  slog.LevelDebug,
+			File:   file,
+		}
+
+		switch sink.Output {
+		case OutputStdout, OutputStderr, OutputFile:
+		default:
+			return nil, fmt.Errorf("Unknown log output %q", sink.Output)
+		}
+
+		if len(parts) > 1 {
+			switch parts[1] {
+			case FormatJSON, FormatText:
+				sink.Format = parts[1]
+			default:
+				return nil, fmt.Errorf("Unknown log format %q", parts[1])
+			}
+		}
+
+		if len(parts) > 2 {
+			level, err := ParseLevel(parts[2])
+			if err != nil {
+				return nil, err
+			}
+			sink.Level = level
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func (s Sink) writer() (io.Writer, error) {
+	switch s.Output {
+	case OutputStdout:
+		return os.Stdout, nil
+	case OutputStderr:
+		return os.Stderr, nil
+	case OutputFile:
+		return newRotatingFile(s.File)
+	}
+	return nil, fmt.Errorf("Unknown log output %q", s.Output)
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"pengoe/internal/logger"
+	"sync"
+	"time"
+)
+
+/*
+sendTimeout limits the sending of an email in the background.
+*/
+const sendTimeout = 30 * time.Second
+
+/*
+background tracks the emails being sent in the background.
+*/
+var background sync.WaitGroup
+
+/*
+SendInBackground sends the message with the default mailer after the response,
+so the response time does not tell if an email was sent. Errors are logged.
+*/
+func SendInBackground(ctx context.Context, message Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		defer cancel()
+
+		err := Send(ctx, message)
+		if err != nil {
+			log := logger.FromContext(ctx)
+			log.Error(err.Error())
+		}
+	}()
+}
+
+/*
+Drain is background work for the server, that waits for the emails still
+being sent at shutdown, after the requests are drained.
+Eg. srv.Go(mail.Drain)
+*/
+func Drain(ctx context.Context) {
+	<-ctx.Done()
+	background.Wait()
+}
@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pengoe/internal/logger"
+	"sync"
+	"time"
+)
+
+/*
+localFrom is the sender of the emails that are not sent.
+*/
+const localFrom = "pengoe <noreply@localhost>"
+
+/*
+FileMailer appends the emails to a file instead of sending them,
+for reading the links locally.
+*/
+type FileMailer struct {
+	path  string
+	mutex sync.Mutex
+}
+
+/*
+NewFileMailer returns a mailer writing to the file, created with its directory.
+*/
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{path: path}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	content, err := format(localFrom, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(m.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(content, "\r\n\r\n"...))
+	return err
+}
+
+/*
+LogMailer logs the emails instead of sending them, links included, so it is
+for local runs only.
+*/
+type LogMailer struct{}
+
+/*
+NewLogMailer returns a mailer writing the emails to the log.
+*/
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	_, err := format(localFrom, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	log := logger.FromContext(ctx)
+	log.Info("Email not sent", "to", message.To, "subject", message.Subject, "body", message.Body)
+
+	return nil
+}
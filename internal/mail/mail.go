@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"pengoe/internal/utils"
+	"strings"
+	"time"
+)
+
+/*
+Message is a plain text email.
+*/
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+/*
+Mailer sends the emails: SMTPMailer in production,
+FileMailer or LogMailer for local runs.
+*/
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+/*
+Default is the mailer of the app, logging the emails until the server sets
+the configured one.
+*/
+var Default Mailer = NewLogMailer()
+
+/*
+Send sends the message with the default mailer.
+*/
+func Send(ctx context.Context, message Message) error {
+	return Default.Send(ctx, message)
+}
+
+/*
+format returns the message as an RFC 5322 email, with CRLF line endings.
+*/
+func format(from string, message Message, date time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(message.To); err != nil {
+		return nil, fmt.Errorf("Invalid recipient %q: %w", message.To, err)
+	}
+
+	// no header injection through the subject
+	if strings.ContainsAny(message.Subject, "\r\n") {
+		return nil, fmt.Errorf("Invalid subject %q", message.Subject)
+	}
+
+	id, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return nil, err
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at != -1 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+
+	var b bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", message.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", strings.TrimRight(id, "="), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", header[0], header[1])
+	}
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	_, err = qp.Write([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+	err = qp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
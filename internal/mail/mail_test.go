@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	message := Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Hello,\nopen https://example.com/reset-password?token=abc",
+	}
+
+	content, err := format("pengoe <noreply@example.com>", message, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	email := string(content)
+
+	expected := []string{
+		"From: pengoe <noreply@example.com>\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Date: Tue, 02 Jan 2024 10:00:00 +0000\r\n",
+		"@example.com>\r\n",
+		"\r\n\r\nHello,\r\nopen https://example.com/reset-password?token=3Dabc",
+	}
+
+	for _, part := range expected {
+		if !strings.Contains(email, part) {
+			t.Errorf("Expected %q in the email, got %q", part, email)
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	_, err := format("noreply@example.com", Message{To: "not an address", Subject: "Hi"}, time.Now())
+	if err == nil {
+		t.Errorf("Expected error for invalid recipient")
+	}
+
+	_, err = format("noreply@example.com", Message{To: "user@example.com", Subject: "Hi\r\nBcc: other@example.com"}, time.Now())
+	if err == nil {
+		t.Errorf("Expected error for header injection")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail", "outbox.eml")
+	mailer := NewFileMailer(path)
+
+	for _, subject := range []string{"First", "Second"} {
+		err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: subject, Body: "Hi"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.Contains(string(content), "Subject: First") || !strings.Contains(string(content), "Subject: Second") {
+		t.Errorf("Expected both emails in the file, got %q", content)
+	}
+}
+
+type slowMailer struct {
+	delay time.Duration
+	sent  chan Message
+}
+
+func (m *slowMailer) Send(ctx context.Context, message Message) error {
+	time.Sleep(m.delay)
+	m.sent <- message
+	return nil
+}
+
+func TestDrainWaitsForBackgroundEmails(t *testing.T) {
+	previous := Default
+	defer func() {
+		Default = previous
+	}()
+
+	mailer := &slowMailer{delay: 50 * time.Millisecond, sent: make(chan Message, 1)}
+	Default = mailer
+
+	// the request context is cancelled after the response
+	requestCtx, cancelRequest := context.WithCancel(context.Background())
+	SendInBackground(requestCtx, Message{To: "user@example.com"})
+	cancelRequest()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	Drain(ctx)
+
+	select {
+	case message := <-mailer.sent:
+		if message.To != "user@example.com" {
+			t.Errorf("Expected the email to user@example.com, got %q", message.To)
+		}
+	default:
+		t.Errorf("Expected Drain to wait for the email")
+	}
+}
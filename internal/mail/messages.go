@@ -0,0 +1,33 @@
+package mail
+
+import "fmt"
+
+/*
+PasswordReset returns the email with the password reset link.
+*/
+func PasswordReset(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your pengoe password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nopen the link below to set a new password. It works once, for an hour.\n\n%s\n\nIf you did not ask for it, ignore this email.\n",
+			name,
+			link,
+		),
+	}
+}
+
+/*
+EmailVerification returns the email with the verification link.
+*/
+func EmailVerification(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email for pengoe",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nopen the link below to verify your email address. It works for a day.\n\n%s\n\nIf you did not sign up to pengoe, ignore this email.\n",
+			name,
+			link,
+		),
+	}
+}
@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+/*
+SMTPMailer sends the emails through an SMTP server. The connection is
+upgraded with STARTTLS if the server offers it, the credentials are only
+sent over TLS (or to localhost).
+*/
+type SMTPMailer struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+/*
+NewSMTPMailer returns a mailer sending from the address through the server
+at host:port, with PLAIN auth if the username is set.
+*/
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, port),
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	content, err := format(m.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, _ := net.SplitHostPort(m.addr)
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+
+	to, _ := mail.ParseAddress(message.To)
+
+	// smtp.SendMail has no context, the send is abandoned when it is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, auth, from.Address, []string{to.Address}, content)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
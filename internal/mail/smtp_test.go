@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+/*
+stubSMTP is an SMTP server accepting one email, without TLS and auth.
+*/
+type stubSMTP struct {
+	listener net.Listener
+	from     string
+	to       []string
+	data     chan string
+}
+
+func newStubSMTP(t *testing.T) *stubSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &stubSMTP{listener: listener, data: make(chan string, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *stubSMTP) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = strings.TrimSpace(line[len("MAIL FROM:"):])
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = append(s.to, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newStubSMTP(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+
+	mailer := NewSMTPMailer(host, port, "", "", "pengoe <noreply@example.com>")
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "User <user@example.com>",
+		Subject: "Reset your password",
+		Body:    "Open the link",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data := <-server.data
+
+	if server.from != "<noreply@example.com>" {
+		t.Errorf("Expected the sender address, got %q", server.from)
+	}
+
+	if len(server.to) != 1 || server.to[0] != "<user@example.com>" {
+		t.Errorf("Expected the recipient address, got %q", server.to)
+	}
+
+	if !strings.Contains(data, "Subject: Reset your password\r\n") || !strings.Contains(data, "Open the link") {
+		t.Errorf("Expected the email, got %q", data)
+	}
+}
+
+func TestSMTPMailerVerification(t *testing.T) {
+	server := newStubSMTP(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+
+	mailer := NewSMTPMailer(host, port, "", "", "pengoe <noreply@example.com>")
+
+	link := "https://pengoe.example.com/verify-email?token=abc"
+
+	err := mailer.Send(context.Background(), EmailVerification("user@example.com", "Jane", link))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data := <-server.data
+
+	// quoted-printable body, = is encoded
+	if !strings.Contains(data, "Subject: Verify your email for pengoe\r\n") || !strings.Contains(data, "verify-email?token=3Dabc") {
+		t.Errorf("Expected the verification email, got %q", data)
+	}
+}
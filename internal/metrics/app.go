@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+/*
+Default is the registry of the metrics of the app, served on /metrics.
+*/
+var Default = NewRegistry()
+
+/*
+HTTPRequests counts the requests by route pattern, method and status.
+*/
+var HTTPRequests = Default.NewCounter(
+	"pengoe_http_requests_total",
+	"Number of HTTP requests by route pattern, method and status.",
+	"route", "method", "status",
+)
+
+/*
+HTTPDuration is the latency of the requests by route pattern, method and status.
+*/
+var HTTPDuration = Default.NewHistogram(
+	"pengoe_http_request_duration_seconds",
+	"Latency of HTTP requests by route pattern, method and status.",
+	DefaultBuckets,
+	"route", "method", "status",
+)
+
+/*
+DBQueryDuration is the duration of the database calls by service and method.
+*/
+var DBQueryDuration = Default.NewHistogram(
+	"pengoe_db_query_duration_seconds",
+	"Duration of database calls by service and method.",
+	DefaultBuckets,
+	"service", "method",
+)
+
+/*
+CSRFRenewals counts the renewed CSRF tokens.
+*/
+var CSRFRenewals = Default.NewCounter(
+	"pengoe_csrf_token_renewals_total",
+	"Number of renewed CSRF tokens.",
+)
+
+/*
+Logins counts the signin attempts by result: success or failure.
+*/
+var Logins = Default.NewCounter(
+	"pengoe_logins_total",
+	"Number of signin attempts by result.",
+	"result",
+)
+
+/*
+Results of a login, the values of the "result" label of Logins.
+*/
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+/*
+ObserveQuery is a function that starts timing a database call, and returns the
+function that records it. Eg. defer metrics.ObserveQuery("event", "Search")()
+*/
+func ObserveQuery(service, method string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.Observe(time.Since(start).Seconds(), service, method)
+	}
+}
+
+/*
+Handler is a function that returns the handler of the default registry.
+*/
+func Handler() http.Handler {
+	return Default.Handler()
+}
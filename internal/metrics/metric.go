@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+Counter is a metric that only goes up, eg. the number of requests.
+*/
+type Counter struct {
+	name   string
+	help   string
+	series series[*float64]
+}
+
+/*
+NewCounter is a function that registers a counter with the given label names.
+*/
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		series: newSeries(labels, func() *float64 { return new(float64) }),
+	}
+	r.register(name, labels, c)
+	return c
+}
+
+/*
+Inc is a function that adds one to the counter of the label values.
+*/
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+/*
+Add is a function that adds a non-negative value to the counter of the label values.
+*/
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("Counter %q can not decrease", c.name))
+	}
+	c.series.with(labelValues, func(value *float64) {
+		*value += v
+	})
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.series.each(func(labels string, value *float64) {
+		writeSample(w, c.name, labels, *value)
+	})
+}
+
+/*
+Gauge is a metric that can go up and down, eg. the number of open connections.
+*/
+type Gauge struct {
+	name   string
+	help   string
+	series series[*float64]
+}
+
+/*
+NewGauge is a function that registers a gauge with the given label names.
+*/
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{
+		name:   name,
+		help:   help,
+		series: newSeries(labels, func() *float64 { return new(float64) }),
+	}
+	r.register(name, labels, g)
+	return g
+}
+
+/*
+Set is a function that sets the gauge of the label values.
+*/
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.series.with(labelValues, func(value *float64) {
+		*value = v
+	})
+}
+
+/*
+Add is a function that adds a value, negative to decrease, to the gauge of the label values.
+*/
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.series.with(labelValues, func(value *float64) {
+		*value += v
+	})
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.series.each(func(labels string, value *float64) {
+		writeSample(w, g.name, labels, *value)
+	})
+}
+
+/*
+gaugeFunc is a gauge without labels, its value is read on every scrape.
+*/
+type gaugeFunc struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+/*
+NewGaugeFunc is a function that registers a gauge whose value is returned by
+the given function on every scrape, eg. the number of sessions in memory.
+*/
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(name, nil, &gaugeFunc{name: name, help: help, value: value})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	writeSample(w, g.name, "", g.value())
+}
+
+/*
+DefaultBuckets are the upper bounds of the histogram buckets in seconds,
+from 5 ms to 10 s, like the official client.
+*/
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+/*
+Histogram is a metric that counts observations, eg. latencies, in buckets.
+The buckets are cumulative, the last one (+Inf) counts all observations.
+*/
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	series  series[*histogramValue]
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+/*
+NewHistogram is a function that registers a histogram with the given bucket
+upper bounds (sorted, without +Inf) and label names.
+*/
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("Buckets of histogram %q should be sorted", name))
+	}
+
+	bounds := append([]float64{}, buckets...)
+	if len(bounds) > 0 && math.IsInf(bounds[len(bounds)-1], 1) {
+		bounds = bounds[:len(bounds)-1]
+	}
+
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: bounds,
+		series: newSeries(labels, func() *histogramValue {
+			return &histogramValue{counts: make([]uint64, len(bounds))}
+		}),
+	}
+	r.register(name, labels, h)
+	return h
+}
+
+/*
+Observe is a function that adds an observation to the histogram of the label values.
+*/
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.series.with(labelValues, func(value *histogramValue) {
+		// the first bucket the observation fits in, the rest are counted on write
+		i := sort.SearchFloat64s(h.buckets, v)
+		if i < len(value.counts) {
+			value.counts[i]++
+		}
+		value.count++
+		value.sum += v
+	})
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.series.each(func(labels string, value *histogramValue) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += value.counts[i]
+			le := `le="` + formatFloat(bound) + `"`
+			writeSample(w, h.name+"_bucket", joinLabels(labels, le), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(value.count))
+		writeSample(w, h.name+"_sum", labels, value.sum)
+		writeSample(w, h.name+"_count", labels, float64(value.count))
+	})
+}
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Registry is a set of metrics, written in the Prometheus text exposition
+format (version 0.0.4) in the order they were registered.
+*/
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+/*
+NewRegistry is a function that returns an empty registry.
+*/
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+/*
+register is a function that adds a metric to the registry.
+It panics if the name is invalid or already registered, like a duplicate route.
+*/
+func (r *Registry) register(name string, labels []string, m metric) {
+	if !validName(name) {
+		panic(fmt.Sprintf("Invalid metric name %q", name))
+	}
+	for _, label := range labels {
+		if !validName(label) || strings.HasPrefix(label, "__") || label == "le" {
+			panic(fmt.Sprintf("Invalid label name %q of metric %q", label, name))
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("Metric %q already registered", name))
+	}
+
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+/*
+WriteTo is a function that writes all metrics in the text exposition format.
+*/
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mutex.Unlock()
+
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+
+	for _, m := range metrics {
+		m.write(buffered)
+	}
+
+	err := buffered.Flush()
+	return counter.n, err
+}
+
+/*
+Handler is a function that returns the handler of the /metrics endpoint.
+*/
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteTo(w)
+	})
+}
+
+/*
+ContentType is the content type of the text exposition format.
+*/
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+/*
+series are the values of a metric, by the values of its labels.
+*/
+type series[T any] struct {
+	mutex  sync.Mutex
+	labels []string
+	values map[string]*seriesValue[T]
+	create func() T
+}
+
+type seriesValue[T any] struct {
+	labelValues []string
+	value       T
+}
+
+func newSeries[T any](labels []string, create func() T) series[T] {
+	return series[T]{
+		labels: labels,
+		values: map[string]*seriesValue[T]{},
+		create: create,
+	}
+}
+
+/*
+with is a function that calls update with the value of the label values,
+created on first use. It panics if the number of label values is wrong.
+*/
+func (s *series[T]) with(labelValues []string, update func(T)) {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("Expected %d label values, got %d", len(s.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.values[key]
+	if !found {
+		v = &seriesValue[T]{
+			labelValues: append([]string{}, labelValues...),
+			value:       s.create(),
+		}
+		s.values[key] = v
+	}
+
+	update(v.value)
+}
+
+/*
+each is a function that calls f for every value, sorted by the label values,
+so the output is stable between scrapes.
+*/
+func (s *series[T]) each(f func(labels string, value T)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := s.values[key]
+		f(formatLabels(s.labels, v.labelValues), v.value)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+/*
+formatLabels is a function that returns the labels of a sample without braces.
+Eg. route="/account/:id",status="200"
+*/
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "," + b
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+/*
+validName is a function that checks a metric or label name: [a-zA-Z_][a-zA-Z0-9_]*
+(colons are reserved for recording rules).
+*/
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		digit := c >= '0' && c <= '9'
+		if !letter && !(digit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.Header.Get("Content-Type") != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, res.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return string(body)
+}
+
+func TestCounter(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_requests_total", "Requests.\nBy route.", "route", "status")
+
+	counter.Inc("/b", "200")
+	counter.Inc("/a", "200")
+	counter.Add(2, "/a", "200")
+	counter.Inc("/a", "404")
+
+	expected := `# HELP test_requests_total Requests.\nBy route.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 3
+test_requests_total{route="/a",status="404"} 1
+test_requests_total{route="/b",status="200"} 1
+`
+
+	result := scrape(t, registry.Handler())
+	if result != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.NewGauge("test_open", "Open connections.")
+	registry.NewGaugeFunc("test_sessions", "Sessions.", func() float64 { return 7 })
+
+	gauge.Set(5)
+	gauge.Add(-1.5)
+
+	expected := `# HELP test_open Open connections.
+# TYPE test_open gauge
+test_open 3.5
+# HELP test_sessions Sessions.
+# TYPE test_sessions gauge
+test_sessions 7
+`
+
+	result := scrape(t, registry.Handler())
+	if result != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1}, "method")
+
+	histogram.Observe(0.05, "Get")
+	histogram.Observe(0.1, "Get")
+	histogram.Observe(0.5, "Get")
+	histogram.Observe(3, "Get")
+
+	expected := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{method="Get",le="0.1"} 2
+test_duration_seconds_bucket{method="Get",le="1"} 3
+test_duration_seconds_bucket{method="Get",le="+Inf"} 4
+test_duration_seconds_sum{method="Get"} 3.65
+test_duration_seconds_count{method="Get"} 4
+`
+
+	result := scrape(t, registry.Handler())
+	if result != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_total", "Test.", "path")
+
+	counter.Inc("a\"b\\c\nd")
+
+	expected := `test_total{path="a\"b\\c\nd"} 1`
+
+	result := scrape(t, registry.Handler())
+	if !strings.Contains(result, expected) {
+		t.Errorf("Expected %q in:\n%s", expected, result)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+	}{
+		{"", nil},
+		{"1_total", nil},
+		{"test-total", nil},
+		{"test_total", []string{"le"}},
+		{"test_total", []string{"__name"}},
+		{"test_duplicate", nil},
+	}
+
+	registry := NewRegistry()
+	registry.NewCounter("test_duplicate", "Test.")
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected panic for %q %v", test.name, test.labels)
+				}
+			}()
+			registry.NewCounter(test.name, "Test.", test.labels...)
+		}()
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_total", "Test.", "route")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for missing label values")
+		}
+	}()
+
+	counter.Inc()
+}
+
+func TestDefault(t *testing.T) {
+	Logins.Inc(LoginSuccess)
+	Logins.Inc(LoginFailure)
+	Logins.Inc(LoginFailure)
+	CSRFRenewals.Inc()
+	ObserveQuery("event", "Search")()
+
+	result := scrape(t, Handler())
+
+	expected := []string{
+		`pengoe_logins_total{result="failure"} 2`,
+		`pengoe_logins_total{result="success"} 1`,
+		`pengoe_csrf_token_renewals_total 1`,
+		`pengoe_db_query_duration_seconds_count{service="event",method="Search"} 1`,
+		`# TYPE pengoe_http_request_duration_seconds histogram`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(result, line) {
+			t.Errorf("Expected %q in:\n%s", line, result)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+/*
+Package oidc signs in users with an OpenID Connect provider, with the
+authorization code flow and PKCE. The provider is found by discovery from its
+issuer, and the ID tokens are verified with its keys (RS256 or ES256).
+*/
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Default is the configured provider, nil if OIDC signin is off.
+The server sets it from the configuration.
+*/
+var Default *Provider
+
+/*
+keyRefreshInterval is the shortest time between two downloads of the keys,
+an unknown key id downloads them again (the provider rotated its keys).
+*/
+const keyRefreshInterval = time.Minute
+
+/*
+maxResponseSize is the largest response read from the provider.
+*/
+const maxResponseSize = 1 << 20
+
+var (
+	// ErrDiscovery is returned when the metadata of the provider is invalid
+	ErrDiscovery = errors.New("Invalid provider metadata")
+
+	// ErrExchange is returned when the provider does not give tokens for the code
+	ErrExchange = errors.New("Code exchange failed")
+)
+
+/*
+Config configures a provider. Name is shown on the signin button.
+RedirectURL is the callback of the app, registered at the provider.
+Scopes default to openid, email and profile.
+*/
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+/*
+metadata is the part of the discovery document that is used.
+*/
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+/*
+Provider is an OpenID Connect provider. The metadata is discovered on
+first use, so the app starts when the provider is down.
+*/
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]any
+	keysAt   time.Time
+	now      func() time.Time
+}
+
+/*
+NewProvider returns the provider of the configuration.
+*/
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+/*
+Name returns the name of the provider for the signin page.
+*/
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+/*
+discover returns the metadata of the provider, downloaded once.
+*/
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	m := &metadata{}
+	err := p.getJSON(ctx, wellKnown, m)
+	if err != nil {
+		return nil, err
+	}
+
+	// the metadata must be of the configured issuer
+	if m.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q, expected %q", ErrDiscovery, m.Issuer, p.config.Issuer)
+	}
+
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	p.metadata = m
+
+	return m, nil
+}
+
+/*
+getJSON downloads a JSON document of the provider.
+*/
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+/*
+Flow is a started signin: State comes back in the callback, Nonce in the
+ID token, and Verifier is the PKCE code verifier sent with the code.
+*/
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+/*
+NewFlow returns the random values of a new signin.
+*/
+func NewFlow() (*Flow, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	return &Flow{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+/*
+codeChallenge is the S256 PKCE challenge of the verifier.
+*/
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+/*
+AuthURL returns the URL of the provider the user is sent to.
+*/
+func (p *Provider) AuthURL(ctx context.Context, flow *Flow) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(m.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientId)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", flow.State)
+	query.Set("nonce", flow.Nonce)
+	query.Set("code_challenge", codeChallenge(flow.Verifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+/*
+tokenResponse is the response of the token endpoint.
+*/
+type tokenResponse struct {
+	IdToken          string `json:"id_token"`
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+/*
+Exchange trades the code of the callback for the tokens, and returns the
+verified claims of the ID token.
+*/
+func (p *Provider) Exchange(ctx context.Context, code string, flow *Flow) (*Claims, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", flow.Verifier)
+
+	// client_secret_basic is the default, some providers only take the post
+	basic := len(m.TokenAuthMethods) == 0
+	for _, method := range m.TokenAuthMethods {
+		if method == "client_secret_basic" {
+			basic = true
+		}
+	}
+
+	if !basic {
+		form.Set("client_id", p.config.ClientId)
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	tokens := &tokenResponse{}
+	err = json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrExchange, res.Status)
+	}
+
+	if res.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchange, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IdToken == "" {
+		return nil, fmt.Errorf("%w: no ID token", ErrExchange)
+	}
+
+	return p.VerifyIDToken(ctx, tokens.IdToken, flow.Nonce)
+}
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"pengoe/internal/oidc/oidctest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost:8080/signin/oidc/callback"
+
+func newProvider(server *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Issuer:       server.URL,
+		ClientId:     server.ClientId,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+}
+
+/*
+authorize follows the auth URL to the mock provider, and returns the
+query of the callback.
+*/
+func authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect, got %s", res.Status)
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(location.String(), redirectURL) {
+		t.Fatalf("Expected a redirect to the callback, got %s", location)
+	}
+
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+
+	provider := newProvider(server)
+	ctx := context.Background()
+
+	flow, err := NewFlow()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	authURL, err := provider.AuthURL(ctx, flow)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	callback := authorize(t, authURL)
+	if callback.Get("state") != flow.State {
+		t.Errorf("Expected state %q, got %q", flow.State, callback.Get("state"))
+	}
+
+	claims, err := provider.Exchange(ctx, callback.Get("code"), flow)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if claims.Subject != server.User.Subject || claims.Email != server.User.Email || !bool(claims.EmailVerified) {
+		t.Errorf("Expected the claims of the user, got %+v", claims)
+	}
+
+	// a code works once
+	_, err = provider.Exchange(ctx, callback.Get("code"), flow)
+	if !errors.Is(err, ErrExchange) {
+		t.Errorf("Expected %v, got %v", ErrExchange, err)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+
+	provider := newProvider(server)
+	ctx := context.Background()
+
+	flow, _ := NewFlow()
+	authURL, _ := provider.AuthURL(ctx, flow)
+	callback := authorize(t, authURL)
+
+	// the code was stolen, the thief does not have the verifier
+	other, _ := NewFlow()
+	other.Nonce = flow.Nonce
+
+	_, err := provider.Exchange(ctx, callback.Get("code"), other)
+	if !errors.Is(err, ErrExchange) {
+		t.Errorf("Expected %v, got %v", ErrExchange, err)
+	}
+}
+
+func TestVerifyIDTokenInvalid(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+
+	provider := newProvider(server)
+	ctx := context.Background()
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   server.URL,
+			"sub":   "1",
+			"aud":   server.ClientId,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	_, err := provider.VerifyIDToken(ctx, server.IDToken(valid()), "nonce")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		claim string
+		value any
+	}{
+		{"other issuer", "iss", "https://evil.com"},
+		{"other audience", "aud", "other"},
+		{"more audiences", "aud", []string{server.ClientId, "other"}},
+		{"expired", "exp", time.Now().Add(-time.Hour).Unix()},
+		{"issued in the future", "iat", time.Now().Add(time.Hour).Unix()},
+		{"other nonce", "nonce", "other"},
+		{"no subject", "sub", ""},
+	}
+
+	for _, test := range tests {
+		claims := valid()
+		claims[test.claim] = test.value
+
+		_, err := provider.VerifyIDToken(ctx, server.IDToken(claims), "nonce")
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected %v, got %v", test.name, ErrInvalidToken, err)
+		}
+	}
+
+	token := server.IDToken(valid())
+	parts := strings.Split(token, ".")
+
+	// unsigned
+	none := "eyJhbGciOiJub25lIn0." + parts[1] + "."
+	_, err = provider.VerifyIDToken(ctx, none, "nonce")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected %v for alg none, got %v", ErrInvalidToken, err)
+	}
+
+	// claims changed after signing
+	claims := valid()
+	claims["sub"] = "2"
+	tampered := parts[0] + "." + strings.Split(server.IDToken(claims), ".")[1] + "." + parts[2]
+	_, err = provider.VerifyIDToken(ctx, tampered, "nonce")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected %v for a tampered token, got %v", ErrInvalidToken, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+
+	provider := newProvider(server)
+	ctx := context.Background()
+
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	claims := map[string]any{
+		"iss":   server.URL,
+		"sub":   "1",
+		"aud":   server.ClientId,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": "nonce",
+	}
+
+	_, err := provider.VerifyIDToken(ctx, server.IDToken(claims), "nonce")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	server.RotateKey()
+
+	// the keys were just downloaded
+	_, err = provider.VerifyIDToken(ctx, server.IDToken(claims), "nonce")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected %v, got %v", ErrInvalidToken, err)
+	}
+
+	now = now.Add(keyRefreshInterval)
+
+	_, err = provider.VerifyIDToken(ctx, server.IDToken(claims), "nonce")
+	if err != nil {
+		t.Errorf("Expected the new key to be downloaded, got %v", err)
+	}
+}
+
+func TestDiscoveryOtherIssuer(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+
+	provider := NewProvider(Config{
+		Issuer:   server.URL + "/other",
+		ClientId: server.ClientId,
+	})
+
+	flow, _ := NewFlow()
+
+	_, err := provider.AuthURL(context.Background(), flow)
+	if err == nil {
+		t.Errorf("Expected error for the metadata of another issuer")
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Package oidctest is a mock OpenID Connect provider for tests. It signs in
+its User without asking, like an SSO where the user is already signed in.
+*/
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/*
+User is the user signed in at the provider.
+*/
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	GivenName         string
+	FamilyName        string
+	PreferredUsername string
+}
+
+/*
+authorization is a code given to the app, waiting for the exchange.
+*/
+type authorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+/*
+Server is the mock provider. Its URL is the issuer.
+*/
+type Server struct {
+	URL          string
+	ClientId     string
+	ClientSecret string
+	User         User
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+/*
+NewServer starts a provider for the client "pengoe" with the secret "secret",
+and a verified user.
+*/
+func NewServer() *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		ClientId:     "pengoe",
+		ClientSecret: "secret",
+		User: User{
+			Subject:           "248289761001",
+			Email:             "jane@example.com",
+			EmailVerified:     true,
+			Name:              "Jane Doe",
+			GivenName:         "Jane",
+			FamilyName:        "Doe",
+			PreferredUsername: "jane",
+		},
+		key:   key,
+		kid:   "key-1",
+		codes: map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+
+	return s
+}
+
+/*
+Close stops the provider.
+*/
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+/*
+RotateKey replaces the signing key, with a new key id.
+*/
+func (s *Server) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+	s.kid = s.kid + "'"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encode := base64.RawURLEncoding.EncodeToString
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": s.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(s.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+/*
+authorize signs in the User, and sends the browser back with a code.
+*/
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.ClientId || query.Get("response_type") != "code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	s.mu.Lock()
+	s.codes[code] = authorization{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: redirectURI.String(),
+	}
+	s.mu.Unlock()
+
+	callback := redirectURI.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+/*
+token exchanges a code for the tokens, checking the client and PKCE.
+*/
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+	}
+
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError("invalid_request")
+		return
+	}
+
+	clientId, clientSecret, basic := r.BasicAuth()
+	if basic {
+		clientId, _ = url.QueryUnescape(clientId)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientId = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if clientId != s.ClientId || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	// a code works once
+	s.mu.Lock()
+	auth, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if !found || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError("invalid_grant")
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	now := time.Now()
+
+	idToken := s.IDToken(map[string]any{
+		"iss":                s.URL,
+		"sub":                s.User.Subject,
+		"aud":                s.ClientId,
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              auth.nonce,
+		"email":              s.User.Email,
+		"email_verified":     s.User.EmailVerified,
+		"name":               s.User.Name,
+		"given_name":         s.User.GivenName,
+		"family_name":        s.User.FamilyName,
+		"preferred_username": s.User.PreferredUsername,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+/*
+IDToken signs the claims with the key of the provider (RS256).
+*/
+func (s *Server) IDToken(claims map[string]any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encode := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.kid}) + "." + encode(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
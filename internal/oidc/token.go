@@ -0,0 +1,363 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+/*
+leeway is the clock difference allowed between the app and the provider.
+*/
+const leeway = time.Minute
+
+/*
+ErrInvalidToken is returned for ID tokens that do not pass the checks.
+*/
+var ErrInvalidToken = errors.New("Invalid ID token")
+
+/*
+Claims are the claims of an ID token that are used.
+*/
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     boolean  `json:"email_verified"`
+	Name              string   `json:"name"`
+	GivenName         string   `json:"given_name"`
+	FamilyName        string   `json:"family_name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+/*
+audience is a string or an array of strings.
+*/
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var many []string
+	err := json.Unmarshal(b, &many)
+	if err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+/*
+boolean is a bool, some providers send "true" as a string.
+*/
+type boolean bool
+
+func (v *boolean) UnmarshalJSON(b []byte) error {
+	switch strings.Trim(string(b), `"`) {
+	case "true":
+		*v = true
+	case "false", "null":
+		*v = false
+	default:
+		return fmt.Errorf("Invalid boolean %s", b)
+	}
+	return nil
+}
+
+/*
+header is the JOSE header of an ID token.
+*/
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+/*
+VerifyIDToken verifies the signature and the claims of an ID token:
+the issuer, the audience, the expiry and the nonce of the flow.
+*/
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	h := &header{}
+	err := decodeSegment(parts[0], h)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	key, err := p.key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	err = decodeSegment(parts[1], claims)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.checkClaims(claims, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+/*
+checkClaims checks the claims of a verified ID token.
+*/
+func (p *Provider) checkClaims(claims *Claims, nonce string) error {
+	if claims.Issuer != p.config.Issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	found := false
+	for _, aud := range claims.Audience {
+		if aud == p.config.ClientId {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: audience %v", ErrInvalidToken, []string(claims.Audience))
+	}
+
+	// with more audiences, the token must be issued to this client
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientId {
+		return fmt.Errorf("%w: authorized party %q", ErrInvalidToken, claims.AuthorizedParty)
+	}
+
+	now := p.now()
+
+	if claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(leeway)) {
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+
+	if now.Add(leeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		return fmt.Errorf("%w: nonce does not match", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return nil
+}
+
+/*
+verifySignature checks an RS256 or ES256 signature,
+the algorithm must match the key.
+*/
+func verifySignature(alg string, key any, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		if alg != "ES256" {
+			break
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+}
+
+/*
+jwk is a key of the key set of the provider.
+*/
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+/*
+key returns the signing key of the provider with the id. The keys are
+downloaded again for an unknown id, at most once in keyRefreshInterval.
+*/
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, found := p.findKey(kid)
+	if found {
+		return key, nil
+	}
+
+	if p.keys != nil && p.now().Sub(p.keysAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	err = p.getJSON(ctx, m.JWKSURI, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]any{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		public, err := k.publicKey()
+		if err != nil {
+			// other types of keys are skipped
+			continue
+		}
+		keys[k.Kid] = public
+	}
+
+	p.keys = keys
+	p.keysAt = p.now()
+
+	key, found = p.findKey(kid)
+	if !found {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	return key, nil
+}
+
+/*
+findKey finds the key by id, without an id the only key is used.
+*/
+func (p *Provider) findKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, found := p.keys[kid]
+	return key, found
+}
+
+func (k *jwk) publicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("Invalid RSA exponent")
+		}
+
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("Unsupported curve")
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("Invalid EC key")
+		}
+
+		return key, nil
+	}
+
+	return nil, errors.New("Unsupported key type")
+}
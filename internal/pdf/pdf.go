@@ -0,0 +1,259 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+A4 page size in points.
+*/
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type Font string
+
+const (
+	Regular Font = "F1"
+	Bold    Font = "F2"
+)
+
+/*
+Document is a minimal PDF document using the standard Helvetica fonts,
+so no font files have to be embedded.
+*/
+type Document struct {
+	pages []*Page
+}
+
+/*
+Page collects the drawing operators of a single page.
+Coordinates are in points, measured from the top left corner.
+*/
+type Page struct {
+	content bytes.Buffer
+}
+
+/*
+New is a function that creates an empty document.
+*/
+func New() *Document {
+	return &Document{
+		pages: []*Page{},
+	}
+}
+
+/*
+AddPage appends a new A4 page to the document.
+*/
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+/*
+Text draws text with its baseline at y.
+*/
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	fmt.Fprintf(
+		&p.content,
+		"BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font,
+		num(size),
+		num(x),
+		num(PageHeight-y),
+		escape(text),
+	)
+}
+
+/*
+TextRight draws text so that it ends at x.
+*/
+func (p *Page) TextRight(x, y float64, font Font, size float64, text string) {
+	p.Text(x-TextWidth(font, size, text), y, font, size, text)
+}
+
+/*
+Line draws a line with the given width.
+*/
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(
+		&p.content,
+		"%s w %s %s m %s %s l S\n",
+		num(width),
+		num(x1),
+		num(PageHeight-y1),
+		num(x2),
+		num(PageHeight-y2),
+	)
+}
+
+/*
+Bytes renders the document.
+*/
+func (d *Document) Bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_, err := d.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+WriteTo renders the document to w.
+Object layout: 1 catalog, 2 page tree, 3-4 fonts, then a page
+and a content stream object for every page.
+*/
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = []*Page{{}}
+	}
+
+	objects := []string{}
+
+	kids := []string{}
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+i*2))
+	}
+
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, page := range pages {
+		stream, err := deflate(page.content.Bytes())
+		if err != nil {
+			return 0, err
+		}
+
+		objects = append(objects,
+			fmt.Sprintf(
+				"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				num(PageWidth),
+				num(PageHeight),
+				6+i*2,
+			),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := []int{}
+	for i, object := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func deflate(content []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := zlib.NewWriter(buf)
+
+	_, err := writer.Write(content)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+/*
+escape converts text to WinAnsi bytes and escapes it for a PDF string.
+Letters outside of WinAnsi are replaced with the closest WinAnsi letter
+(eg. "ő" with "ö"), other characters with "?".
+*/
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		c, ok := winAnsi(r)
+		if !ok {
+			c, ok = winAnsi(winAnsiSubstitutes[r])
+		}
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func winAnsi(r rune) (byte, bool) {
+	switch {
+	case r >= 0x20 && r < 0x7f:
+		return byte(r), true
+	case r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	}
+	c, ok := winAnsiExtra[r]
+	return c, ok
+}
+
+/*
+winAnsiExtra are the WinAnsi characters in 0x80-0x9f,
+where the encoding differs from Latin-1.
+*/
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+/*
+winAnsiSubstitutes are the closest WinAnsi letters of the Central European
+letters that WinAnsi does not have.
+*/
+var winAnsiSubstitutes = map[rune]rune{
+	'Ő': 'Ö', 'ő': 'ö', 'Ű': 'Ü', 'ű': 'ü',
+	'Ă': 'A', 'ă': 'a', 'Ą': 'A', 'ą': 'a',
+	'Ć': 'C', 'ć': 'c', 'Č': 'C', 'č': 'c',
+	'Ď': 'D', 'ď': 'd', 'Đ': 'D', 'đ': 'd',
+	'Ę': 'E', 'ę': 'e', 'Ě': 'E', 'ě': 'e',
+	'Ĺ': 'L', 'ĺ': 'l', 'Ľ': 'L', 'ľ': 'l', 'Ł': 'L', 'ł': 'l',
+	'Ń': 'N', 'ń': 'n', 'Ň': 'N', 'ň': 'n',
+	'Ŕ': 'R', 'ŕ': 'r', 'Ř': 'R', 'ř': 'r',
+	'Ś': 'S', 'ś': 's', 'Ş': 'S', 'ş': 's', 'Ș': 'S', 'ș': 's',
+	'Ţ': 'T', 'ţ': 't', 'Ť': 'T', 'ť': 't', 'Ț': 'T', 'ț': 't',
+	'Ů': 'U', 'ů': 'u',
+	'Ź': 'Z', 'ź': 'z', 'Ż': 'Z', 'ż': 'z',
+}
+
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
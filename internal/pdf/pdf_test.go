@@ -0,0 +1,90 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestDocumentStructure(t *testing.T) {
+	doc := New()
+	doc.AddPage().Text(50, 50, Bold, 12, "Statement (Q1)")
+	doc.AddPage().Line(50, 60, 545, 60, 0.5)
+
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) {
+		t.Errorf("Expected PDF header, got %q", out[:8])
+	}
+
+	if !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Errorf("Expected EOF marker")
+	}
+
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Errorf("Expected 2 pages")
+	}
+
+	// every xref entry should point to the start of its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if startxref == nil {
+		t.Fatalf("Expected startxref")
+	}
+
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref")) {
+		t.Fatalf("Expected xref at offset %d", xref)
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[xref:], -1)
+	if len(entries) != 8 {
+		t.Errorf("Expected 8 objects, got %d", len(entries))
+	}
+
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		expected := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(out[offset:], []byte(expected)) {
+			t.Errorf("Expected %q at offset %d", expected, offset)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	expected := "a\\(b\\)\\\\ \x80 ?"
+
+	result := escape("a(b)\\ € ✓")
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestEscapeSubstitutes(t *testing.T) {
+	tests := map[string]string{
+		"Kőszegi Űrlap": "K\xf6szegi \xdcrlap",
+		"Łódź":          "L\xf3dz",
+		"fő – „díj”":    "f\xf6 \x96 \x84d\xedj\x94",
+		"日本":            "??",
+	}
+
+	for text, expected := range tests {
+		result := escape(text)
+		if result != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, text, result)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	expected := 5.56 * 2
+
+	result := TextWidth(Regular, 10, "00")
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,146 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"pengoe/internal/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+AccessLog returns a middleware that logs every request through the logger:
+method, route pattern, status, response size, latency, and the request and
+user ids. If combined is not nil, the requests are also written to it in the
+Apache combined log format.
+Eg. r.Use(router.AccessLog(nil))
+*/
+func AccessLog(combined io.Writer) middlewareFunc {
+	var mutex sync.Mutex
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+			start := time.Now()
+			rw := newResponseWriter(w)
+
+			err := next(rw, r, p)
+
+			latency := time.Since(start)
+
+			// nothing written means an empty 200
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			log := logger.FromContext(r.Context())
+			log.Info(
+				"Request",
+				"method", r.Method,
+				"route", Pattern(r),
+				"status", status,
+				"bytes", rw.bytes,
+				"latency_ms", float64(latency.Microseconds())/1000,
+			)
+
+			if combined != nil {
+				line := combinedLogLine(r, status, rw.bytes, start)
+
+				mutex.Lock()
+				io.WriteString(combined, line)
+				mutex.Unlock()
+			}
+
+			return err
+		}
+	}
+}
+
+/*
+combinedLogLine returns the request in the Apache combined log format:
+host ident user [time] "request line" status bytes "referer" "user agent"
+*/
+func combinedLogLine(r *http.Request, status, bytes int, start time.Time) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	user := logger.UserId(r.Context())
+	if user == "" {
+		user = "-"
+	}
+
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprint(bytes)
+	}
+
+	referer := r.Referer()
+	if referer == "" {
+		referer = "-"
+	}
+
+	userAgent := r.UserAgent()
+	if userAgent == "" {
+		userAgent = "-"
+	}
+
+	return fmt.Sprintf(
+		"%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"\n",
+		host,
+		user,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		escapeQuotes(redactedURI(r.URL)),
+		r.Proto,
+		status,
+		size,
+		escapeQuotes(referer),
+		escapeQuotes(userAgent),
+	)
+}
+
+/*
+sensitiveParams are the query parameters that are secrets, like the single-use
+tokens of the emailed links and the code of the OpenID Connect callback.
+*/
+var sensitiveParams = []string{"token", "code", "state"}
+
+/*
+redactedURI returns the request URI, with the values of the sensitive query
+parameters replaced, so the log file does not hold them.
+*/
+func redactedURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		// not parsed, not written
+		return u.EscapedPath() + "?REDACTED"
+	}
+
+	redacted := false
+	for _, param := range sensitiveParams {
+		if _, found := query[param]; found {
+			query[param] = []string{"REDACTED"}
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return u.RequestURI()
+	}
+
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
+func escapeQuotes(s string) string {
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
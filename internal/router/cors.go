@@ -0,0 +1,113 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+CORS is the cross-origin resource sharing policy of a route group.
+AllowedOrigins can contain "*" to allow every origin, then the response
+allows any origin without credentials, so "*" and AllowCredentials together
+are rejected.
+*/
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+/*
+validate checks that credentials are not allowed for every origin,
+that would let any website read the responses of signed in users.
+*/
+func (c *CORS) validate() error {
+	if c.AllowCredentials && c.allowsAny() {
+		return errors.New("CORS: credentials can not be allowed for every origin (\"*\")")
+	}
+	return nil
+}
+
+func (c *CORS) allowsAny() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+setOrigin sets the headers shared by preflight and actual requests,
+and reports if the origin is allowed.
+*/
+func (c *CORS) setOrigin(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowsOrigin(origin) {
+		return false
+	}
+
+	// any origin is allowed literally, never with credentials
+	if c.allowsAny() {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	return true
+}
+
+/*
+preflight answers a preflight request for the methods of the policy.
+*/
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, methods []string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !c.setOrigin(w, r) {
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(c.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	}
+
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+}
+
+/*
+middleware sets the CORS headers of actual (not preflight) requests.
+*/
+func (c *CORS) middleware(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		if c.setOrigin(w, r) && len(c.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+
+		return next(w, r, p)
+	}
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"pengoe/web/templates/pages"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+/*
+HTTPError is an error that handlers can return, instead of writing the error
+response themselves. The router responds with the status and the public
+message, and logs the internal cause.
+Eg. return router.NewHTTPError(http.StatusBadRequest, "Invalid date", err)
+*/
+type HTTPError struct {
+	Status  int
+	Message string
+	Cause   error
+}
+
+/*
+NewHTTPError returns a new HTTPError. An empty message defaults to the status text.
+*/
+func NewHTTPError(status int, message string, cause error) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	return &HTTPError{
+		Status:  status,
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%d %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Message, e.Cause.Error())
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+/*
+respondError writes the response of typed errors, unless the handler already
+responded. The error is passed on, to be logged.
+*/
+func respondError(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		err := next(w, r, p)
+
+		httpErr, ok := asHTTPError(err)
+		if ok && !written(w) {
+			writeError(w, r, httpErr)
+		}
+
+		return err
+	}
+}
+
+/*
+writeError writes the error response: JSON for API and htmx callers,
+the error page otherwise. Statuses without a page only get the status code.
+*/
+func writeError(w http.ResponseWriter, r *http.Request, httpErr *HTTPError) {
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpErr.Status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": httpErr.Status,
+			"error":  httpErr.Message,
+		})
+		return
+	}
+
+	var component templ.Component
+	switch {
+	case httpErr.Status == http.StatusNotFound:
+		component = pages.NotFound()
+	case httpErr.Status == http.StatusMethodNotAllowed:
+		component = pages.NotAllowed()
+	case httpErr.Status >= http.StatusInternalServerError:
+		component = pages.InternalError()
+	}
+
+	w.WriteHeader(httpErr.Status)
+
+	if component != nil {
+		handler := templ.Handler(component)
+		handler.ServeHTTP(w, r)
+	}
+}
+
+/*
+wantsJSON reports if the caller expects a JSON response:
+htmx requests, and requests accepting JSON but not HTML.
+*/
+func wantsJSON(r *http.Request) bool {
+	if r.Header.Get("HX-Request") == "true" {
+		return true
+	}
+
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+/*
+asHTTPError returns the HTTPError in the chain of err, if there is one.
+*/
+func asHTTPError(err error) (*HTTPError, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	return nil, false
+}
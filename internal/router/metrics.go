@@ -0,0 +1,39 @@
+package router
+
+import (
+	"net/http"
+	"pengoe/internal/metrics"
+	"strconv"
+	"time"
+)
+
+/*
+Metrics is a middleware that counts the requests and records their latency
+by route pattern, method and status. Requests without a matching route are
+labelled "unmatched", so random paths do not create new series.
+*/
+func Metrics(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		start := time.Now()
+		rw := newResponseWriter(w)
+
+		err := next(rw, r, p)
+
+		// nothing written means an empty 200
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := Pattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		statusStr := strconv.Itoa(status)
+		metrics.HTTPRequests.Inc(route, r.Method, statusStr)
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, r.Method, statusStr)
+
+		return err
+	}
+}
@@ -0,0 +1,154 @@
+package radix
+
+import (
+	"pengoe/internal/utils"
+	"strings"
+	"testing"
+)
+
+/*
+The routes of the app, and the requests the benchmarks look up.
+*/
+var benchRoutes = []string{
+	"/",
+	"/signup",
+	"/signin",
+	"/signout",
+	"/dashboard",
+	"/account/new",
+	"/account",
+	"/account/restore",
+	"/account/:id",
+	"/account/:id/events",
+	"/account/:id/backup",
+	"/account/:id/statement",
+	"/account/:id/report",
+	"/event",
+	"/event/:id",
+	"/ui/check",
+	"/ui/new-event-form",
+	"/ui/new-event-form-button",
+	"/ui/edit-event-form/:id",
+	"/ui/event-card/:id",
+}
+
+var benchPaths = []string{
+	"/",
+	"/dashboard",
+	"/account/" + testId,
+	"/account/" + testId + "/events",
+	"/account/" + testId + "/report",
+	"/event/evt_1234567890abcdef1234567890abcdef",
+	"/ui/new-event-form-button",
+	"/ui/event-card/evt_1234567890abcdef1234567890abcdef",
+	"/not/found",
+}
+
+/*
+linearRoute and linearMatch are the matcher the router used before the radix tree:
+a scan over all routes of the same length, segment by segment.
+*/
+type linearRoute struct {
+	pattern []string
+}
+
+func linearMatch(routes []*linearRoute, pathStr string) (*linearRoute, map[string]string) {
+	path := utils.GetPatternFromStr(pathStr)
+
+	result := []*linearRoute{}
+	for _, route := range routes {
+		if len(route.pattern) == len(path) {
+			result = append(result, route)
+		}
+	}
+
+	for i, pathSegment := range path {
+		newPossible := []*linearRoute{}
+		for _, route := range result {
+			if pathSegment == route.pattern[i] {
+				newPossible = append(newPossible, route)
+			}
+		}
+		if len(newPossible) == 0 {
+			for _, route := range result {
+				if strings.HasPrefix(route.pattern[i], ":") {
+					newPossible = append(newPossible, route)
+				}
+			}
+		}
+		result = newPossible
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], utils.GetPathVariables(result[0].pattern, path)
+}
+
+func BenchmarkLinear(b *testing.B) {
+	routes := []*linearRoute{}
+	for _, pattern := range benchRoutes {
+		routes = append(routes, &linearRoute{utils.GetPatternFromStr(pattern)})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			linearMatch(routes, path)
+		}
+	}
+}
+
+func BenchmarkRadix(b *testing.B) {
+	tree := New[string]()
+	for _, pattern := range benchRoutes {
+		tree.Insert(pattern, pattern)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			tree.Lookup(path)
+		}
+	}
+}
+
+/*
+TestBenchRoutes checks that the two matchers agree on the benchmarked requests.
+*/
+func TestBenchRoutes(t *testing.T) {
+	routes := []*linearRoute{}
+	tree := New[string]()
+	for _, pattern := range benchRoutes {
+		routes = append(routes, &linearRoute{utils.GetPatternFromStr(pattern)})
+		tree.Insert(pattern, pattern)
+	}
+
+	for _, path := range benchPaths {
+		linear, linearParams := linearMatch(routes, path)
+		result, params, found := tree.Lookup(path)
+
+		if (linear != nil) != found {
+			t.Errorf("Expected matchers to agree on %q", path)
+			continue
+		}
+
+		if !found {
+			continue
+		}
+
+		expected := "/" + strings.Join(linear.pattern, "/")
+		if result != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, path, result)
+		}
+
+		if len(params) != len(linearParams) || (params != nil && !utils.MapEqual(params, linearParams)) {
+			t.Errorf("Expected params %v for %q, got %v", linearParams, path, params)
+		}
+	}
+}
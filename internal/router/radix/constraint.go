@@ -0,0 +1,70 @@
+package radix
+
+import "sync"
+
+var (
+	constraints = map[string]func(string) bool{
+		"int":  isInt,
+		"uuid": isUUID,
+	}
+	constraintsMutex sync.Mutex
+)
+
+/*
+RegisterConstraint is a function that adds a parameter constraint,
+usable in patterns as ":name{constraint}".
+Register constraints before inserting the patterns that use them.
+*/
+func RegisterConstraint(name string, match func(string) bool) {
+	constraintsMutex.Lock()
+	defer constraintsMutex.Unlock()
+
+	constraints[name] = match
+}
+
+/*
+isInt is a function that matches decimal digits.
+*/
+func isInt(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+/*
+isUUID is a function that matches the ids of utils.NewUUID:
+an optional lowercase prefix with an underscore, and 32 hex digits.
+Eg. acc_1234567890abcdef1234567890abcdef
+*/
+func isUUID(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' {
+			if i == 0 {
+				return false
+			}
+			for j := 0; j < i; j++ {
+				if s[j] < 'a' || s[j] > 'z' {
+					return false
+				}
+			}
+			s = s[i+1:]
+			break
+		}
+	}
+
+	if len(s) != 32 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,340 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+Tree is a radix tree of url patterns, with a value for every pattern.
+Static parts share their common prefixes. A pattern segment can be a
+parameter (":id"), a parameter with a constraint (":id{uuid}") or, as the
+last segment, a catch-all ("*path") that matches the rest of the path.
+On lookup static segments win over parameters, constrained parameters win
+over unconstrained ones, and catch-alls are tried last.
+*/
+type Tree[T any] struct {
+	root *node[T]
+}
+
+type node[T any] struct {
+	// prefix is the static part matched by the node, for static nodes
+	prefix string
+
+	// children are static nodes, with different first bytes
+	children []*node[T]
+
+	// params are parameter nodes, the constrained ones first
+	params   []*node[T]
+	catchAll *node[T]
+
+	// name and constraint of parameter and catch-all nodes
+	name       string
+	constraint string
+	match      func(string) bool
+
+	value    T
+	hasValue bool
+}
+
+/*
+New is a function that returns an empty tree.
+*/
+func New[T any]() *Tree[T] {
+	return &Tree[T]{root: &node[T]{}}
+}
+
+/*
+Insert is a function that adds a pattern to the tree. It returns the value
+stored at the pattern: the given one, or the one inserted before.
+*/
+func (t *Tree[T]) Insert(pattern string, value T) (T, error) {
+	var zero T
+
+	tokens, err := parse(pattern)
+	if err != nil {
+		return zero, err
+	}
+
+	n := t.root
+	for _, token := range tokens {
+		switch token.kind {
+		case staticToken:
+			n = n.insertStatic(token.text)
+		case paramToken:
+			n, err = n.insertParam(token.text, token.constraint)
+		case catchAllToken:
+			n, err = n.insertCatchAll(token.text)
+		}
+
+		if err != nil {
+			return zero, fmt.Errorf("Pattern %q: %s", pattern, err.Error())
+		}
+	}
+
+	if !n.hasValue {
+		n.value = value
+		n.hasValue = true
+	}
+
+	return n.value, nil
+}
+
+/*
+Lookup is a function that returns the value of the pattern matching the path,
+and the values of the parameters by name.
+*/
+func (t *Tree[T]) Lookup(path string) (T, map[string]string, bool) {
+	var params map[string]string
+
+	n := t.root.lookup(path, &params)
+	if n == nil {
+		var zero T
+		return zero, nil, false
+	}
+
+	return n.value, params, true
+}
+
+func (n *node[T]) insertStatic(s string) *node[T] {
+	if s == "" {
+		return n
+	}
+
+	for _, child := range n.children {
+		if child.prefix[0] != s[0] {
+			continue
+		}
+
+		common := commonPrefixLength(child.prefix, s)
+
+		// split the child at the end of the common prefix
+		if common < len(child.prefix) {
+			rest := &node[T]{}
+			*rest = *child
+			rest.prefix = child.prefix[common:]
+
+			*child = node[T]{
+				prefix:   child.prefix[:common],
+				children: []*node[T]{rest},
+			}
+		}
+
+		return child.insertStatic(s[common:])
+	}
+
+	child := &node[T]{prefix: s}
+	n.children = append(n.children, child)
+	return child
+}
+
+func (n *node[T]) insertParam(name, constraint string) (*node[T], error) {
+	for _, param := range n.params {
+		if param.constraint != constraint {
+			continue
+		}
+		if param.name != name {
+			return nil, fmt.Errorf("Parameter :%s conflicts with :%s", name, param.name)
+		}
+		return param, nil
+	}
+
+	param := &node[T]{name: name, constraint: constraint}
+
+	if constraint != "" {
+		constraintsMutex.Lock()
+		match, found := constraints[constraint]
+		constraintsMutex.Unlock()
+
+		if !found {
+			return nil, fmt.Errorf("Unknown constraint %q", constraint)
+		}
+		param.match = match
+
+		// constrained parameters are tried before the unconstrained one
+		n.params = append([]*node[T]{param}, n.params...)
+		return param, nil
+	}
+
+	n.params = append(n.params, param)
+	return param, nil
+}
+
+func (n *node[T]) insertCatchAll(name string) (*node[T], error) {
+	if n.catchAll != nil {
+		if n.catchAll.name != name {
+			return nil, fmt.Errorf("Catch-all *%s conflicts with *%s", name, n.catchAll.name)
+		}
+		return n.catchAll, nil
+	}
+
+	n.catchAll = &node[T]{name: name}
+	return n.catchAll, nil
+}
+
+/*
+lookup is a function that returns the node matching the rest of the path.
+Parameters are only set on the way back from a match, so a dead end
+does not leave values behind.
+*/
+func (n *node[T]) lookup(path string, params *map[string]string) *node[T] {
+	if path == "" {
+		if n.hasValue {
+			return n
+		}
+		return nil
+	}
+
+	for _, child := range n.children {
+		if child.prefix[0] != path[0] {
+			continue
+		}
+		if strings.HasPrefix(path, child.prefix) {
+			found := child.lookup(path[len(child.prefix):], params)
+			if found != nil {
+				return found
+			}
+		}
+		break
+	}
+
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+
+	if end > 0 {
+		segment := path[:end]
+
+		for _, param := range n.params {
+			if param.match != nil && !param.match(segment) {
+				continue
+			}
+
+			found := param.lookup(path[end:], params)
+			if found != nil {
+				setParam(params, param.name, segment)
+				return found
+			}
+		}
+	}
+
+	if n.catchAll != nil && n.catchAll.hasValue {
+		setParam(params, n.catchAll.name, path)
+		return n.catchAll
+	}
+
+	return nil
+}
+
+func setParam(params *map[string]string, name, value string) {
+	if *params == nil {
+		*params = map[string]string{}
+	}
+	(*params)[name] = value
+}
+
+func commonPrefixLength(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+type tokenKind int
+
+const (
+	staticToken tokenKind = iota
+	paramToken
+	catchAllToken
+)
+
+type token struct {
+	kind       tokenKind
+	text       string
+	constraint string
+}
+
+/*
+parse is a function that splits a pattern into static parts, parameters and catch-alls.
+Eg. /account/:id{uuid}/events -> "/account/", id{uuid}, "/events"
+*/
+func parse(pattern string) ([]token, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("Pattern %q should start with /", pattern)
+	}
+
+	segments := strings.Split(pattern[1:], "/")
+	tokens := []token{}
+	static := "/"
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name, constraint, err := parseParam(segment[1:])
+			if err != nil {
+				return nil, fmt.Errorf("Pattern %q: %s", pattern, err.Error())
+			}
+
+			tokens = append(tokens, token{kind: staticToken, text: static})
+			tokens = append(tokens, token{kind: paramToken, text: name, constraint: constraint})
+			static = ""
+
+		case strings.HasPrefix(segment, "*"):
+			if !last {
+				return nil, fmt.Errorf("Pattern %q: catch-all should be the last segment", pattern)
+			}
+			if len(segment) == 1 {
+				return nil, fmt.Errorf("Pattern %q: catch-all without a name", pattern)
+			}
+
+			tokens = append(tokens, token{kind: staticToken, text: static})
+			tokens = append(tokens, token{kind: catchAllToken, text: segment[1:]})
+			static = ""
+
+		default:
+			static += segment
+		}
+
+		if !last {
+			static += "/"
+		}
+	}
+
+	if static != "" {
+		tokens = append(tokens, token{kind: staticToken, text: static})
+	}
+
+	return tokens, nil
+}
+
+/*
+parseParam is a function that splits a parameter into its name and constraint.
+Eg. id{uuid} -> id, uuid
+*/
+func parseParam(s string) (string, string, error) {
+	name := s
+	constraint := ""
+
+	open := strings.IndexByte(s, '{')
+	if open >= 0 {
+		if !strings.HasSuffix(s, "}") {
+			return "", "", errors.New("Unclosed constraint")
+		}
+		name = s[:open]
+		constraint = s[open+1 : len(s)-1]
+		if constraint == "" {
+			return "", "", errors.New("Empty constraint")
+		}
+	}
+
+	if name == "" {
+		return "", "", errors.New("Parameter without a name")
+	}
+
+	return name, constraint, nil
+}
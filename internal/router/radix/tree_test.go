@@ -0,0 +1,172 @@
+package radix
+
+import (
+	"pengoe/internal/utils"
+	"testing"
+)
+
+const testId = "acc_1234567890abcdef1234567890abcdef"
+
+func newTestTree(t *testing.T, patterns ...string) *Tree[string] {
+	tree := New[string]()
+	for _, pattern := range patterns {
+		_, err := tree.Insert(pattern, pattern)
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got %v", pattern, err)
+		}
+	}
+	return tree
+}
+
+func TestLookup(t *testing.T) {
+	tree := newTestTree(
+		t,
+		"/",
+		"/account",
+		"/account/new",
+		"/account/:id{uuid}",
+		"/account/:id{uuid}/events",
+		"/account/:name",
+		"/accounts",
+		"/a/:x/b",
+		"/a/c/d",
+		"/page/:n{int}",
+		"/static/*path",
+	)
+
+	tests := []struct {
+		path     string
+		expected string
+		params   map[string]string
+	}{
+		{"/", "/", nil},
+		{"/account", "/account", nil},
+		{"/accounts", "/accounts", nil},
+		{"/account/new", "/account/new", nil},
+		{"/account/" + testId, "/account/:id{uuid}", map[string]string{"id": testId}},
+		{"/account/" + testId + "/events", "/account/:id{uuid}/events", map[string]string{"id": testId}},
+		{"/account/savings", "/account/:name", map[string]string{"name": "savings"}},
+		{"/a/c/d", "/a/c/d", nil},
+		// static segment "c" is a dead end, backtracks to the parameter
+		{"/a/c/b", "/a/:x/b", map[string]string{"x": "c"}},
+		{"/page/12", "/page/:n{int}", map[string]string{"n": "12"}},
+		{"/static/css/main.css", "/static/*path", map[string]string{"path": "css/main.css"}},
+	}
+
+	for _, test := range tests {
+		result, params, found := tree.Lookup(test.path)
+		if !found {
+			t.Errorf("Expected %q to match %q", test.path, test.expected)
+			continue
+		}
+
+		if result != test.expected {
+			t.Errorf("Expected %q to match %q, got %q", test.path, test.expected, result)
+		}
+
+		if len(params) != len(test.params) || (test.params != nil && !utils.MapEqual(params, test.params)) {
+			t.Errorf("Expected params %v for %q, got %v", test.params, test.path, params)
+		}
+	}
+}
+
+func TestLookupNotFound(t *testing.T) {
+	tree := newTestTree(t, "/account/:id{uuid}/events", "/page/:n{int}", "/static/*path")
+
+	paths := []string{
+		"",
+		"/",
+		"/account",
+		"/account/" + testId,
+		"/account/savings/events",
+		"/account//events",
+		"/page/one",
+		"/static",
+	}
+
+	for _, path := range paths {
+		result, _, found := tree.Lookup(path)
+		if found {
+			t.Errorf("Expected %q not to match, got %q", path, result)
+		}
+	}
+}
+
+func TestInsertExisting(t *testing.T) {
+	tree := New[string]()
+
+	tree.Insert("/account/:id", "first")
+
+	result, err := tree.Insert("/account/:id", "second")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result != "first" {
+		t.Errorf("Expected %q, got %q", "first", result)
+	}
+}
+
+func TestInsertInvalid(t *testing.T) {
+	patterns := []string{
+		"account",
+		"/account/:",
+		"/account/:id{uuid",
+		"/account/:id{}",
+		"/account/:id{unknown}",
+		"/static/*",
+		"/static/*path/more",
+		"/account/:other",
+		"/files/*other",
+	}
+
+	tree := newTestTree(t, "/account/:id", "/files/*path")
+
+	for _, pattern := range patterns {
+		_, err := tree.Insert(pattern, pattern)
+		if err == nil {
+			t.Errorf("Expected error for %q", pattern)
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		match    func(string) bool
+		s        string
+		expected bool
+	}{
+		{isInt, "0123", true},
+		{isInt, "", false},
+		{isInt, "-1", false},
+		{isUUID, testId, true},
+		{isUUID, "1234567890abcdef1234567890abcdef", true},
+		{isUUID, "_1234567890abcdef1234567890abcdef", false},
+		{isUUID, "Acc_1234567890abcdef1234567890abcdef", false},
+		{isUUID, "acc_1234567890ABCDEF1234567890abcdef", false},
+		{isUUID, "acc_1234", false},
+	}
+
+	for _, test := range tests {
+		result := test.match(test.s)
+		if result != test.expected {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.s, result)
+		}
+	}
+}
+
+func TestRegisterConstraint(t *testing.T) {
+	RegisterConstraint("yes", func(s string) bool { return s == "yes" })
+
+	tree := newTestTree(t, "/answer/:a{yes}")
+
+	_, _, found := tree.Lookup("/answer/yes")
+	if !found {
+		t.Errorf("Expected /answer/yes to match")
+	}
+
+	_, _, found = tree.Lookup("/answer/no")
+	if found {
+		t.Errorf("Expected /answer/no not to match")
+	}
+}
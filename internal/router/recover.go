@@ -0,0 +1,47 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"pengoe/internal/logger"
+	"runtime/debug"
+)
+
+/*
+Recover is a middleware that recovers from panics in the handlers.
+It logs the panic with the stack, and responds with a 500 error,
+if the handler has not responded yet.
+Eg. r.Use(router.Recover)
+*/
+func Recover(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p map[string]string) (err error) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// the server aborts the response silently
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			log := logger.FromContext(r.Context())
+			log.Error(
+				"Panic recovered",
+				"panic", fmt.Sprint(recovered),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"stack", string(debug.Stack()),
+			)
+
+			if !written(w) {
+				writeError(w, r, NewHTTPError(http.StatusInternalServerError, "", nil))
+			}
+
+			err = nil
+		}()
+
+		return next(w, r, p)
+	}
+}
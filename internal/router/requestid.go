@@ -0,0 +1,40 @@
+package router
+
+import "pengoe/internal/utils"
+
+/*
+maxRequestIdLength limits the incoming request ids, they end up in every log line.
+*/
+const maxRequestIdLength = 128
+
+/*
+requestId returns the incoming X-Request-ID if it is safe to log,
+otherwise a new id.
+*/
+func requestId(incoming string) string {
+	if validRequestId(incoming) {
+		return incoming
+	}
+	return utils.NewUUID("req")
+}
+
+/*
+validRequestId allows letters, digits and "-_.:" only.
+*/
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
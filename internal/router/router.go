@@ -1,24 +1,39 @@
 package router
 
 import (
-	"errors"
+	"context"
 	"net/http"
 	"pengoe/internal/logger"
+	"pengoe/internal/router/radix"
 	"pengoe/internal/utils"
+	"sort"
 	"strings"
 )
 
 type Router struct {
-	routes       []*route
+	tree         *radix.Tree[*endpoint]
+	middlewares  []middlewareFunc
 	staticPrefix string
 	staticPath   string
 }
 
+/*
+endpoint holds the routes of a pattern by method,
+and the value of the Allow header for the pattern.
+*/
+type endpoint struct {
+	pattern string
+	routes  map[string]*route
+	allow   string
+}
+
+/*
+route is a handler with its middlewares already applied,
+and the CORS policy of its group.
+*/
 type route struct {
-	pattern     []string
-	method      string
-	handler     HandlerFunc
-	middlewares []middlewareFunc
+	handler HandlerFunc
+	cors    *CORS
 }
 
 type HandlerFunc func(http.ResponseWriter, *http.Request, map[string]string) error
@@ -29,7 +44,8 @@ Utility function for creating a new router.
 */
 func NewRouter() *Router {
 	return &Router{
-		routes:       []*route{},
+		tree:         radix.New[*endpoint](),
+		middlewares:  []middlewareFunc{},
 		staticPrefix: "",
 		staticPath:   "",
 	}
@@ -45,55 +61,95 @@ func (r *Router) SetStaticPath(prefix, path string) {
 }
 
 /*
-Utility function for adding a new route to the router.
+Use adds global middlewares, that run on every request before the route's own
+middlewares, including the requests without a matching route.
 */
-func (r *Router) addRoute(method string, pattern []string, handler HandlerFunc, middlewares ...middlewareFunc) {
-	for _, route := range r.routes {
-		if utils.SliceEqual(route.pattern, pattern) && route.method == method {
-			return
-		}
+func (r *Router) Use(middlewares ...middlewareFunc) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+/*
+Group returns a route group, where every route is prefixed with the prefix
+and uses the middlewares.
+Eg. r.Group("/account", m.Token, m.DB, m.Session)
+*/
+func (r *Router) Group(prefix string, middlewares ...middlewareFunc) *Group {
+	return &Group{
+		router:      r,
+		prefix:      prefix,
+		middlewares: middlewares,
+	}
+}
+
+/*
+Handle adds a new route to the router.
+The pattern segments can be parameters (:id), parameters with a
+constraint (:id{uuid}, :page{int}) or a catch-all at the end (*path).
+*/
+func (r *Router) Handle(method, pattern string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	r.handle(method, pattern, handler, nil, middlewares)
+}
+
+func (r *Router) handle(method, pattern string, handler HandlerFunc, cors *CORS, middlewares []middlewareFunc) {
+	pattern = utils.RemoveTrailingSlash(pattern)
+
+	e, err := r.tree.Insert(pattern, &endpoint{
+		pattern: pattern,
+		routes:  map[string]*route{},
+	})
+	if err != nil {
+		log := logger.Get()
+		log.Fatal(err.Error())
+	}
+
+	// the first route wins
+	if _, found := e.routes[method]; found {
+		return
 	}
 
-	newRoute := &route{
-		pattern,
-		method,
-		handler,
-		middlewares,
+	// apply middlewares backwards
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
 	}
 
-	r.routes = append(r.routes, newRoute)
+	// CORS headers are set even if a middleware stops the request
+	if cors != nil {
+		handler = cors.middleware(handler)
+	}
+
+	e.routes[method] = &route{
+		handler: handler,
+		cors:    cors,
+	}
+	e.allow = allowHeader(e.routes)
 }
 
 /*
 Adds a new GET route to the router.
 */
 func (r *Router) GET(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
-	pattern := utils.GetPatternFromStr(s)
-	r.addRoute("GET", pattern, handler, middlewares...)
+	r.Handle("GET", s, handler, middlewares...)
 }
 
 /*
 Adds a new POST route to the router.
 */
 func (r *Router) POST(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
-	pattern := utils.GetPatternFromStr(s)
-	r.addRoute("POST", pattern, handler, middlewares...)
+	r.Handle("POST", s, handler, middlewares...)
 }
 
 /*
 Adds a new PATCH route to the router.
 */
 func (r *Router) PATCH(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
-	pattern := utils.GetPatternFromStr(s)
-	r.addRoute("PATCH", pattern, handler, middlewares...)
+	r.Handle("PATCH", s, handler, middlewares...)
 }
 
 /*
 Adds a new DELETE route to the router.
 */
 func (r *Router) DELETE(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
-	pattern := utils.GetPatternFromStr(s)
-	r.addRoute("DELETE", pattern, handler, middlewares...)
+	r.Handle("DELETE", s, handler, middlewares...)
 }
 
 /*
@@ -102,124 +158,256 @@ It searches for a matching route and calls the handler function.
 */
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	pathStr := utils.RemoveTrailingSlash(req.URL.Path)
-	method := req.Method
 
-	// handle static files
-	if r.staticPrefix != "" && strings.HasPrefix(pathStr, r.staticPrefix) {
-		// w.Header().Set("Content-Encoding", "gzip")
-		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		fs := http.FileServer(http.Dir(r.staticPath))
-		staticHandler := http.StripPrefix(r.staticPrefix, fs)
-		staticHandler.ServeHTTP(w, req)
+	// request id from the proxy or a new one, for the logs and the response
+	id := requestId(req.Header.Get("X-Request-ID"))
+	w.Header().Set("X-Request-ID", id)
+	req = req.WithContext(logger.WithRequestId(req.Context(), id))
 
-		return
+	// /account/1 -> /account/:id, {id: 1}, static files under a fixed pattern
+	var handler HandlerFunc
+	var pattern string
+	var variables map[string]string
+	if r.staticPrefix != "" && strings.HasPrefix(pathStr, r.staticPrefix) {
+		handler, pattern = r.static, r.staticPrefix+"/*"
+	} else {
+		handler, pattern, variables = r.match(req.Method, pathStr)
 	}
+	req = req.WithContext(context.WithValue(req.Context(), patternKey{}, pattern))
 
-	// /account/1 -> [account :id]
-	path := utils.GetPatternFromStr(pathStr)
+	// the span of the request, joining the trace of the caller if any
+	ctx, span := r.startSpan(req, pattern, id)
+	req = req.WithContext(ctx)
+	defer span.End()
 
-	// same length routes
-	sameLengthRoutes := GetSameLengthRoutes(r.routes, path)
+	// typed errors are written inside the global middlewares, so they see the response
+	handler = respondError(handler)
 
-	// [account :id] -> [account 1]
-	matchingRoutes, matchErr := MatchRoutes(sameLengthRoutes, path)
-	if matchErr != nil {
-		NotFound(w, req, nil)
-		return
+	// apply global middlewares backwards
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
 	}
 
-	// GET -> GET
-	route, methodErr := MatchMethod(matchingRoutes, method)
-	if methodErr != nil {
-		if len(matchingRoutes) < 1 && method == "GET" {
-			MethodNotAllowed(w, req, nil)
-		} else {
-			NotFound(w, req, nil)
-		}
-		return
+	rw := newResponseWriter(w)
+
+	// call handler
+	handlerErr := handler(rw, req, variables)
+	if handlerErr != nil {
+		log := logger.FromContext(req.Context())
+		log.Error(handlerErr.Error())
 	}
 
-	// [account 1], [account :id] -> {id: 1}
-	variables := utils.GetPathVariables(route.pattern, path)
+	endSpan(span, rw, handlerErr)
+}
 
-	handler := route.handler
+/*
+static serves the static files, through the global middlewares like the routes.
+*/
+func (r *Router) static(w http.ResponseWriter, req *http.Request, p map[string]string) error {
+	// w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	fs := http.FileServer(http.Dir(r.staticPath))
+	staticHandler := http.StripPrefix(r.staticPrefix, fs)
+	staticHandler.ServeHTTP(w, req)
+
+	return nil
+}
 
-	// apply middlewares backwards
-	for i := len(route.middlewares) - 1; i >= 0; i-- {
-		handler = route.middlewares[i](handler)
+/*
+match returns the handler of the route matching the method and the path,
+with the route pattern and the path variables. HEAD requests are served by the GET handler (the
+server drops the body), OPTIONS requests are answered from the registered
+methods, other methods get a 405 with the Allow header.
+*/
+func (r *Router) match(method, path string) (HandlerFunc, string, map[string]string) {
+	e, variables, found := r.tree.Lookup(path)
+	if !found {
+		return NotFound, "", nil
 	}
 
-	log := logger.Get()
+	route := e.route(method)
+	if route != nil {
+		return route.handler, e.pattern, variables
+	}
 
-	// call handler
-	handlerErr := handler(w, req, variables)
-	if handlerErr != nil {
-		log.Error(handlerErr.Error())
+	if method == http.MethodOptions {
+		return e.options, e.pattern, variables
 	}
+
+	return e.methodNotAllowed, e.pattern, variables
 }
 
+type patternKey struct{}
+
 /*
-GetSameLengthRoutes returns routes with the same length as path.
+Pattern returns the pattern of the route matching the request,
+eg. /account/:id{uuid}, or an empty string if no route matched.
 */
-func GetSameLengthRoutes(routes []*route, path []string) []*route {
-	possible := []*route{}
+func Pattern(r *http.Request) string {
+	pattern, _ := r.Context().Value(patternKey{}).(string)
+	return pattern
+}
 
-	for _, route := range routes {
-		if len(route.pattern) != len(path) {
-			continue
+/*
+route returns the route of the method, the GET route for HEAD.
+*/
+func (e *endpoint) route(method string) *route {
+	route, found := e.routes[method]
+	if !found && method == http.MethodHead {
+		route = e.routes[http.MethodGet]
+	}
+	return route
+}
+
+/*
+options answers OPTIONS requests with the Allow header,
+and CORS preflight requests with the policy of the requested route.
+*/
+func (e *endpoint) options(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	w.Header().Set("Allow", e.allow)
+
+	origin := r.Header.Get("Origin")
+	requested := r.Header.Get("Access-Control-Request-Method")
+
+	if origin != "" && requested != "" {
+		route := e.route(requested)
+		if route != nil && route.cors != nil {
+			route.cors.preflight(w, r, e.corsMethods(route.cors))
 		}
-		possible = append(possible, route)
 	}
 
-	return possible
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
 }
 
 /*
-MatchRoutes returns the route that matches the path.
-Works only for same length routes.
-You must filter routes by getSameLengthRoutes first.
+corsMethods returns the methods of the endpoint that share the CORS policy.
 */
-func MatchRoutes(routes []*route, path []string) ([]*route, error) {
-	result := routes
-
-	// get possible routes (should be only one)
-	for i, pathSegment := range path {
-		newPossible := []*route{}
-		// check for exact match
-		for _, route := range result {
-			patternSegment := route.pattern[i]
-			if pathSegment == patternSegment {
-				newPossible = append(newPossible, route)
-			}
+func (e *endpoint) corsMethods(cors *CORS) []string {
+	methods := []string{}
+	for method, route := range e.routes {
+		if route.cors == cors {
+			methods = append(methods, method)
 		}
-		// if no exact match, check for variable match
-		if len(newPossible) == 0 {
-			for _, route := range result {
-				patternSegment := route.pattern[i]
-				if strings.HasPrefix(patternSegment, ":") {
-					newPossible = append(newPossible, route)
-				}
-			}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (e *endpoint) methodNotAllowed(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	w.Header().Set("Allow", e.allow)
+	return MethodNotAllowed(w, r, p)
+}
+
+/*
+allowHeader returns the value of the Allow header for the routes:
+their methods, HEAD if there is GET, and OPTIONS.
+*/
+func allowHeader(routes map[string]*route) string {
+	methods := []string{http.MethodOptions}
+	for method := range routes {
+		if method != http.MethodOptions {
+			methods = append(methods, method)
 		}
-		result = newPossible
 	}
 
-	if len(result) == 0 {
-		return nil, errors.New("No matching route found")
+	_, hasGet := routes[http.MethodGet]
+	_, hasHead := routes[http.MethodHead]
+	if hasGet && !hasHead {
+		methods = append(methods, http.MethodHead)
 	}
 
-	return result, nil
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
 }
 
 /*
-MatchMethod returns the route that matches the method.
+Group is a set of routes with a common prefix and common middlewares.
 */
-func MatchMethod(routes []*route, method string) (*route, error) {
-	for _, route := range routes {
-		if route.method == method {
-			return route, nil
-		}
+type Group struct {
+	router      *Router
+	prefix      string
+	middlewares []middlewareFunc
+	cors        *CORS
+}
+
+/*
+Use adds middlewares to the group, for the routes added after it.
+*/
+func (g *Group) Use(middlewares ...middlewareFunc) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+/*
+CORS sets the cross-origin policy of the group, for the routes added after it.
+Nested groups inherit the policy of their parent.
+Returns an error if the policy allows credentials for every origin.
+*/
+func (g *Group) CORS(cors *CORS) error {
+	err := cors.validate()
+	if err != nil {
+		return err
+	}
+
+	g.cors = cors
+
+	return nil
+}
+
+/*
+Group returns a nested route group, with the prefix and the middlewares
+added to the ones of the parent group.
+*/
+func (g *Group) Group(prefix string, middlewares ...middlewareFunc) *Group {
+	return &Group{
+		router:      g.router,
+		prefix:      g.prefix + prefix,
+		middlewares: g.chain(middlewares),
+		cors:        g.cors,
 	}
+}
+
+/*
+Handle adds a new route to the group.
+*/
+func (g *Group) Handle(method, s string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	g.router.handle(method, g.prefix+s, handler, g.cors, g.chain(middlewares))
+}
 
-	return nil, errors.New("No matching route found")
+/*
+Adds a new GET route to the group.
+*/
+func (g *Group) GET(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	g.Handle("GET", s, handler, middlewares...)
+}
+
+/*
+Adds a new POST route to the group.
+*/
+func (g *Group) POST(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	g.Handle("POST", s, handler, middlewares...)
+}
+
+/*
+Adds a new PATCH route to the group.
+*/
+func (g *Group) PATCH(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	g.Handle("PATCH", s, handler, middlewares...)
+}
+
+/*
+Adds a new DELETE route to the group.
+*/
+func (g *Group) DELETE(s string, handler HandlerFunc, middlewares ...middlewareFunc) {
+	g.Handle("DELETE", s, handler, middlewares...)
+}
+
+/*
+chain returns the group's middlewares followed by the given ones, in a new slice.
+*/
+func (g *Group) chain(middlewares []middlewareFunc) []middlewareFunc {
+	chain := make([]middlewareFunc, 0, len(g.middlewares)+len(middlewares))
+	chain = append(chain, g.middlewares...)
+	return append(chain, middlewares...)
 }
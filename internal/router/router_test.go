@@ -0,0 +1,390 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ok(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+	w.Write([]byte("ok"))
+	return nil
+}
+
+/*
+record returns a middleware that appends its name to the calls.
+*/
+func record(calls *[]string, name string) middlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+			*calls = append(*calls, name)
+			return next(w, r, p)
+		}
+	}
+}
+
+func serve(r *Router, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := NewRouter()
+	r.GET("/account", ok)
+	r.POST("/account", ok)
+
+	rec := serve(r, httptest.NewRequest(http.MethodDelete, "/account", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+
+	allow := rec.Header().Get("Allow")
+	if allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("Expected Allow \"GET, HEAD, OPTIONS, POST\", got %q", allow)
+	}
+
+	rec = serve(r, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown path, got %d", rec.Code)
+	}
+}
+
+func TestHead(t *testing.T) {
+	called := false
+
+	r := NewRouter()
+	r.GET("/page", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		called = true
+		return ok(w, r, p)
+	})
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	res, err := http.Head(server.URL + "/page")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", res.StatusCode)
+	}
+	if len(body) != 0 {
+		t.Errorf("Expected no body, got %q", body)
+	}
+	if !called {
+		t.Errorf("Expected HEAD to call the GET handler")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	r := NewRouter()
+	r.GET("/account", ok)
+	r.DELETE("/account", ok)
+
+	rec := serve(r, httptest.NewRequest(http.MethodOptions, "/account", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+
+	allow := rec.Header().Get("Allow")
+	if allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow \"DELETE, GET, HEAD, OPTIONS\", got %q", allow)
+	}
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers without a policy")
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	r := NewRouter()
+	r.GET("/page", ok)
+
+	api := r.Group("/api")
+	err := api.CORS(&CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	api.GET("/items", ok)
+	api.POST("/items", ok)
+
+	public := r.Group("/public")
+	err = public.CORS(&CORS{AllowedOrigins: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	public.GET("/items", ok)
+
+	tests := []struct {
+		name        string
+		path        string
+		origin      string
+		allowed     string
+		credentials string
+		methods     string
+	}{
+		{"allowed", "/api/items", "https://app.example.com", "https://app.example.com", "true", "GET, POST"},
+		{"denied", "/api/items", "https://evil.example.com", "", "", ""},
+		{"no policy", "/page", "https://app.example.com", "", "", ""},
+		{"any origin", "/public/items", "https://evil.example.com", "*", "", "GET"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodOptions, test.path, nil)
+		req.Header.Set("Origin", test.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+		rec := serve(r, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("%s: Expected status 204, got %d", test.name, rec.Code)
+		}
+
+		header := rec.Header()
+		if header.Get("Access-Control-Allow-Origin") != test.allowed {
+			t.Errorf("%s: Expected allowed origin %q, got %q", test.name, test.allowed, header.Get("Access-Control-Allow-Origin"))
+		}
+		if header.Get("Access-Control-Allow-Credentials") != test.credentials {
+			t.Errorf("%s: Expected credentials %q, got %q", test.name, test.credentials, header.Get("Access-Control-Allow-Credentials"))
+		}
+		if header.Get("Access-Control-Allow-Methods") != test.methods {
+			t.Errorf("%s: Expected methods %q, got %q", test.name, test.methods, header.Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	r := NewRouter()
+	api := r.Group("/api")
+	api.CORS(&CORS{
+		AllowedOrigins: []string{"https://app.example.com"},
+		ExposedHeaders: []string{"X-Request-ID"},
+	})
+	api.GET("/items", ok)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := serve(r, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected the origin to be allowed, got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Errorf("Expected exposed headers, got %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	r := NewRouter()
+	api := r.Group("/api")
+
+	err := api.CORS(&CORS{
+		AllowedOrigins:   []string{"https://app.example.com", "*"},
+		AllowCredentials: true,
+	})
+	if err == nil {
+		t.Errorf("Expected error for credentials with any origin")
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	calls := []string{}
+
+	r := NewRouter()
+	r.Use(record(&calls, "global"))
+
+	group := r.Group("/account", record(&calls, "group"))
+	group.Use(record(&calls, "use"))
+	nested := group.Group("/settings", record(&calls, "nested"))
+	nested.GET("/sessions", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		calls = append(calls, "handler")
+		return nil
+	}, record(&calls, "route"))
+
+	// added after the route, it is not applied to it
+	group.Use(record(&calls, "late"))
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/account/settings/sessions", nil))
+
+	expected := "global group use nested route handler"
+	result := strings.Join(calls, " ")
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	// global middlewares run for unmatched requests too
+	calls = []string{}
+	serve(r, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+
+	if strings.Join(calls, " ") != "global" {
+		t.Errorf("Expected only the global middleware, got %v", calls)
+	}
+}
+
+func TestStaticUsesGlobalMiddlewares(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0644)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	combined := &bytes.Buffer{}
+	patterns := []string{}
+
+	r := NewRouter()
+	r.Use(AccessLog(combined), func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request, p map[string]string) error {
+			patterns = append(patterns, Pattern(req))
+			return next(w, req, p)
+		}
+	})
+	r.SetStaticPath("/static", dir)
+
+	rec := serve(r, httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "app" {
+		t.Errorf("Expected the file, got %d %q", rec.Code, rec.Body.String())
+	}
+	if strings.Join(patterns, " ") != "/static/*" {
+		t.Errorf("Expected the /static/* pattern, got %v", patterns)
+	}
+	if !strings.Contains(combined.String(), `"GET /static/app.js HTTP/1.1" 200 3`) {
+		t.Errorf("Expected the access log line, got %q", combined.String())
+	}
+}
+
+func TestRecover(t *testing.T) {
+	r := NewRouter()
+	r.Use(Recover)
+	r.GET("/panic", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		panic("boom")
+	})
+	r.GET("/panic-after-write", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	})
+
+	rec := serve(r, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+
+	rec = serve(r, httptest.NewRequest(http.MethodGet, "/panic-after-write", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected the written status 202 to be kept, got %d", rec.Code)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	r := NewRouter()
+	r.GET("/bad", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		return NewHTTPError(http.StatusBadRequest, "Invalid date", errors.New("parse error"))
+	})
+	r.GET("/written", func(w http.ResponseWriter, r *http.Request, p map[string]string) error {
+		w.WriteHeader(http.StatusConflict)
+		return NewHTTPError(http.StatusBadRequest, "", nil)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/bad", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := serve(r, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+
+	body := map[string]any{}
+	err := json.NewDecoder(rec.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("Expected a JSON body, got %v", err)
+	}
+	if body["error"] != "Invalid date" {
+		t.Errorf("Expected the public message only, got %v", body["error"])
+	}
+
+	rec = serve(r, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected the written status 409 to be kept, got %d", rec.Code)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	combined := &bytes.Buffer{}
+
+	r := NewRouter()
+	r.Use(AccessLog(combined))
+	r.GET("/account/:id", ok)
+
+	req := httptest.NewRequest(http.MethodGet, "/account/1?tab=events", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	serve(r, req)
+
+	line := combined.String()
+	expected := []string{`"GET /account/1?tab=events HTTP/1.1" 200 2`, `"test-agent"`}
+	for _, part := range expected {
+		if !strings.Contains(line, part) {
+			t.Errorf("Expected %q in %q", part, line)
+		}
+	}
+}
+
+func TestAccessLogRedactsSecrets(t *testing.T) {
+	combined := &bytes.Buffer{}
+
+	r := NewRouter()
+	r.Use(AccessLog(combined))
+	r.GET("/reset-password", ok)
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/reset-password?token=secret1&code=secret2&state=secret3&lang=en", nil))
+	serve(r, httptest.NewRequest(http.MethodGet, "/reset-password?token=secret1;bad=%zz", nil))
+
+	log := combined.String()
+	if strings.Contains(log, "secret") {
+		t.Errorf("Expected the secrets to be redacted, got %q", log)
+	}
+
+	expected := "/reset-password?code=REDACTED&lang=en&state=REDACTED&token=REDACTED"
+	if !strings.Contains(log, expected) {
+		t.Errorf("Expected %q in %q", expected, log)
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	r := NewRouter()
+	r.GET("/", ok)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "proxy-123")
+	rec := serve(r, req)
+
+	if rec.Header().Get("X-Request-ID") != "proxy-123" {
+		t.Errorf("Expected the incoming request id, got %q", rec.Header().Get("X-Request-ID"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	rec = serve(r, req)
+
+	id := rec.Header().Get("X-Request-ID")
+	if id == "" || id == "bad id\n" {
+		t.Errorf("Expected a new request id, got %q", id)
+	}
+}
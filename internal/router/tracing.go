@@ -0,0 +1,60 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"pengoe/internal/tracing"
+)
+
+/*
+startSpan is a function that starts the server span of a request, named by
+the method and the route pattern (the path for unmatched requests would
+create a new name for every random url).
+*/
+func (r *Router) startSpan(req *http.Request, pattern, requestId string) (context.Context, *tracing.Span) {
+	name := req.Method + " " + pattern
+	if pattern == "" {
+		name = req.Method
+	}
+
+	ctx := tracing.Extract(req.Context(), req.Header)
+	ctx, span := tracing.Start(
+		ctx,
+		name,
+		tracing.String("http.method", req.Method),
+		tracing.String("http.route", pattern),
+		tracing.String("http.target", req.URL.Path),
+		tracing.String("http.request_id", requestId),
+	)
+	span.SetKind(tracing.KindServer)
+
+	return ctx, span
+}
+
+/*
+endSpan is a function that records the status of the response on the span.
+Only server errors mark the span as failed, the errors of the other responses
+(eg. a redirect to signin) are kept as an attribute.
+*/
+func endSpan(span *tracing.Span, rw *responseWriter, err error) {
+	// nothing written means an empty 200
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	span.SetAttributes(tracing.Int("http.status_code", status))
+
+	if status < http.StatusInternalServerError {
+		if err != nil {
+			span.SetAttributes(tracing.String("error.message", err.Error()))
+		}
+		return
+	}
+
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetStatus(tracing.StatusError, http.StatusText(status))
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"pengoe/internal/logger"
+	"sync"
+	"time"
+)
+
+/*
+Config is the configuration of the HTTP server.
+TLS is used when both TLSCertFile and TLSKeyFile are set.
+ShutdownTimeout limits the draining of in-flight requests and background work.
+*/
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	MaxHeaderBytes    int
+	TLSCertFile       string
+	TLSKeyFile        string
+}
+
+/*
+Server is an HTTP server with background work and cleanup,
+that shuts down gracefully when its context is cancelled.
+*/
+type Server struct {
+	config     Config
+	http       *http.Server
+	background []func(ctx context.Context)
+	closers    []closer
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+/*
+New returns a server for the handler.
+*/
+func New(config Config, handler http.Handler) *Server {
+	return &Server{
+		config: config,
+		http: &http.Server{
+			Addr:              config.Addr,
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+			MaxHeaderBytes:    config.MaxHeaderBytes,
+		},
+	}
+}
+
+/*
+Go adds background work, started with the server. Its context is cancelled on
+shutdown, after the in-flight requests are drained, and the server waits for it
+to return.
+*/
+func (s *Server) Go(work func(ctx context.Context)) {
+	s.background = append(s.background, work)
+}
+
+/*
+OnShutdown adds a cleanup function, like closing the database.
+They run after the requests and the background work are done, in reverse order.
+*/
+func (s *Server) OnShutdown(name string, close func() error) {
+	s.closers = append(s.closers, closer{name: name, close: close})
+}
+
+/*
+Run listens on the configured address, and serves until the context is cancelled.
+Eg. ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+*/
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		s.close()
+		return err
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+/*
+Serve serves on the listener until the context is cancelled, then shuts down:
+drains the in-flight requests, stops the background work and runs the cleanups.
+*/
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	log := logger.Get()
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	var wg sync.WaitGroup
+	for _, work := range s.background {
+		wg.Add(1)
+		go func(work func(ctx context.Context)) {
+			defer wg.Done()
+			work(backgroundCtx)
+		}(work)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.config.TLSCertFile != "" && s.config.TLSKeyFile != "" {
+			serveErr <- s.http.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			serveErr <- s.http.Serve(listener)
+		}
+	}()
+
+	log.Info("Server started", "addr", listener.Addr().String(), "tls", s.config.TLSCertFile != "")
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// the server stopped by itself, eg. bad certificate
+	case <-ctx.Done():
+		log.Info("Shutting down, draining requests")
+	}
+
+	shutdownCtx, cancel := s.shutdownContext()
+	defer cancel()
+
+	shutdownErr := s.http.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		log.Error("Could not drain the requests", "error", shutdownErr.Error())
+		if err == nil {
+			err = shutdownErr
+		}
+	}
+
+	stopBackground()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Error("Background work did not stop in time")
+	}
+
+	s.close()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+/*
+shutdownContext limits the shutdown to ShutdownTimeout, zero means no limit.
+*/
+func (s *Server) shutdownContext() (context.Context, context.CancelFunc) {
+	if s.config.ShutdownTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+}
+
+/*
+close runs the cleanups in reverse order.
+*/
+func (s *Server) close() {
+	log := logger.Get()
+
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		err := c.close()
+		if err != nil {
+			log.Error("Could not close "+c.name, "error", err.Error())
+		}
+	}
+
+	s.closers = nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	srv := New(Config{ShutdownTimeout: 5 * time.Second}, handler)
+
+	stopped := make(chan struct{})
+	srv.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	closed := []string{}
+	srv.OnShutdown("first", func() error {
+		closed = append(closed, "first")
+		return nil
+	})
+	srv.OnShutdown("second", func() error {
+		closed = append(closed, "second")
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ctx, listener)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		body <- string(data)
+	}()
+
+	// shut down while the request is in flight
+	<-started
+	cancel()
+
+	select {
+	case <-served:
+		t.Fatalf("Expected the server to wait for the request")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	if result := <-body; result != "done" {
+		t.Errorf("Expected %q, got %q", "done", result)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("Expected the background work to be stopped")
+	}
+
+	if len(closed) != 2 || closed[0] != "second" || closed[1] != "first" {
+		t.Errorf("Expected cleanups in reverse order, got %v", closed)
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	closed := false
+
+	srv := New(Config{Addr: "invalid:address:0"}, http.NotFoundHandler())
+	srv.OnShutdown("database", func() error {
+		closed = true
+		return nil
+	})
+
+	err := srv.Run(context.Background())
+	if err == nil {
+		t.Errorf("Expected error")
+	}
+
+	if !closed {
+		t.Errorf("Expected the cleanups to run")
+	}
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"pengoe/internal/utils"
 	"time"
 )
 
@@ -24,20 +26,24 @@ type Access struct {
 type AccessService interface {
 	New(id string, role Role, userId string, accountId string) error
 	Check(userId, accountId string) bool
+	GetByAccountId(accountId string) ([]*Access, error)
 }
 
 type accessService struct {
-	db *sql.DB
+	ctx context.Context
+	db  *sql.DB
 }
 
-func NewAccessService(db *sql.DB) AccessService {
-	return &accessService{db: db}
+func NewAccessService(ctx context.Context, db *sql.DB) AccessService {
+	return &accessService{ctx: ctx, db: db}
 }
 
 /*
 New is a function that adds an access to the database.
 */
 func (s *accessService) New(id string, role Role, userId, accountId string) error {
+	defer observe(s.ctx, "access", "New")()
+
 	now := time.Now().UTC()
 
 	_, err := s.db.Exec(
@@ -68,6 +74,8 @@ func (s *accessService) New(id string, role Role, userId, accountId string) erro
 Check is a function that checks if a user has access to an account.
 */
 func (s *accessService) Check(userId string, accountId string) bool {
+	defer observe(s.ctx, "access", "Check")()
+
 	row := s.db.QueryRow(
 		`SELECT COUNT(*) FROM access WHERE user_id = ? AND account_id = ?`,
 		userId,
@@ -87,3 +95,64 @@ func (s *accessService) Check(userId string, accountId string) bool {
 
 	return true
 }
+
+/*
+GetByAccountId is a function that returns all accesses for an account.
+*/
+func (s *accessService) GetByAccountId(accountId string) ([]*Access, error) {
+	defer observe(s.ctx, "access", "GetByAccountId")()
+
+	rows, err := s.db.Query(
+		`SELECT
+			id,
+			role,
+			created_at,
+			updated_at,
+			user_id,
+			account_id
+		FROM access
+		WHERE account_id = ?`,
+		accountId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses := []*Access{}
+
+	for rows.Next() {
+		access := &Access{}
+
+		var createdAtStr string
+		var updatedAtStr string
+
+		err := rows.Scan(
+			&access.Id,
+			&access.Role,
+			&createdAtStr,
+			&updatedAtStr,
+			&access.UserId,
+			&access.AccountId,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt, err := utils.ConvertToTime(createdAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		updatedAt, err := utils.ConvertToTime(updatedAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		access.CreatedAt = createdAt
+		access.UpdatedAt = updatedAt
+
+		accesses = append(accesses, access)
+	}
+
+	return accesses, nil
+}
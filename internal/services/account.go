@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"pengoe/internal/utils"
 	"time"
@@ -23,11 +24,12 @@ type AccountServiceInterface interface {
 }
 
 type accountService struct {
-	db *sql.DB
+	ctx context.Context
+	db  *sql.DB
 }
 
-func NewAccountService(db *sql.DB) AccountServiceInterface {
-	return &accountService{db: db}
+func NewAccountService(ctx context.Context, db *sql.DB) AccountServiceInterface {
+	return &accountService{ctx: ctx, db: db}
 }
 
 /*
@@ -35,6 +37,8 @@ New is a function that adds an account to the database.
 Gives back the id of the new account.
 */
 func (s *accountService) New(id, name, description, currency string) error {
+	defer observe(s.ctx, "account", "New")()
+
 	now := time.Now().UTC()
 
 	_, err := s.db.Exec(
@@ -64,6 +68,8 @@ func (s *accountService) New(id, name, description, currency string) error {
 GetByUserId is a function that returns all accounts for a given user.
 */
 func (s *accountService) GetByUserId(userId string) ([]*Account, error) {
+	defer observe(s.ctx, "account", "GetByUserId")()
+
 	rows, err := s.db.Query(
 		`SELECT 
 			account.id,
@@ -124,6 +130,8 @@ func (s *accountService) GetByUserId(userId string) ([]*Account, error) {
 GetById is a function that returns an account for a given id.
 */
 func (s *accountService) GetById(id string) (*Account, error) {
+	defer observe(s.ctx, "account", "GetById")()
+
 	row := s.db.QueryRow(
 		`SELECT
 			id,
@@ -174,6 +182,8 @@ func (s *accountService) GetById(id string) (*Account, error) {
 Delete is a function that deletes an account from the database.
 */
 func (s *accountService) Delete(id string) error {
+	defer observe(s.ctx, "account", "Delete")()
+
 	_, err := s.db.Exec(
 		`DELETE FROM account WHERE id = ?`,
 		id,
@@ -0,0 +1,586 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"pengoe/internal/utils"
+	"strings"
+	"time"
+)
+
+/*
+BackupVersion is the version of the archive format written by Export.
+*/
+const BackupVersion = 1
+
+const (
+	backupManifestFile  = "manifest.json"
+	backupChecksumFile  = "manifest.json.sha256"
+	backupAccountFile   = "account.json"
+	backupAccessFile    = "access.json"
+	backupRecipientFile = "recipient.json"
+	backupEventFile     = "event.json"
+	backupPaymentFile   = "payment.json"
+)
+
+/*
+maxBackupFileSize limits the uncompressed size of every file of an archive,
+a small upload can unpack to gigabytes.
+*/
+const maxBackupFileSize = 64 << 20
+
+/*
+backupFiles are the files of an archive, the other entries are skipped.
+*/
+var backupFiles = map[string]bool{
+	backupManifestFile:  true,
+	backupChecksumFile:  true,
+	backupAccountFile:   true,
+	backupAccessFile:    true,
+	backupRecipientFile: true,
+	backupEventFile:     true,
+	backupPaymentFile:   true,
+}
+
+type BackupManifest struct {
+	Version   int                  `json:"version"`
+	AccountId string               `json:"account_id"`
+	CreatedAt time.Time            `json:"created_at"`
+	Files     []BackupManifestFile `json:"files"`
+}
+
+type BackupManifestFile struct {
+	Name   string `json:"name"`
+	Rows   int    `json:"rows"`
+	Sha256 string `json:"sha256"`
+}
+
+type BackupAccount struct {
+	Id          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Currency    string    `json:"currency"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type BackupAccess struct {
+	Id        string    `json:"id"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserId    string    `json:"user_id"`
+	AccountId string    `json:"account_id"`
+}
+
+type BackupRecipient struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	AccessId  string    `json:"access_id"`
+}
+
+type BackupEvent struct {
+	Id          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Income      int       `json:"income"`
+	Reserved    int       `json:"reserved"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	AccountId   string    `json:"account_id"`
+}
+
+type BackupPayment struct {
+	Id          string     `json:"id"`
+	Factor      int        `json:"factor"`
+	Extra       int        `json:"extra"`
+	Paid        bool       `json:"paid"`
+	PaidAt      *time.Time `json:"paid_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	EventId     string     `json:"event_id"`
+	RecipientId string     `json:"recipient_id"`
+}
+
+/*
+Backup is the decoded content of a backup archive.
+*/
+type Backup struct {
+	Manifest   BackupManifest
+	Accounts   []BackupAccount
+	Accesses   []BackupAccess
+	Recipients []BackupRecipient
+	Events     []BackupEvent
+	Payments   []BackupPayment
+}
+
+type BackupService interface {
+	Export(accountId string) ([]byte, error)
+	Restore(userId string, archive []byte) (string, error)
+}
+
+type backupService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewBackupService(ctx context.Context, db *sql.DB) BackupService {
+	return &backupService{ctx: ctx, db: db}
+}
+
+/*
+Export is a function that packs an account with its accesses, recipients,
+events and payments into a zip archive.
+*/
+func (s *backupService) Export(accountId string) ([]byte, error) {
+	defer observe(s.ctx, "backup", "Export")()
+
+	account, err := NewAccountService(s.ctx, s.db).GetById(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := NewAccessService(s.ctx, s.db).GetByAccountId(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := NewRecipientService(s.ctx, s.db).GetByAccountId(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := NewEventService(s.ctx, s.db).GetByAccountId(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := NewPaymentService(s.ctx, s.db).GetByAccountId(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{
+		Manifest: BackupManifest{
+			Version:   BackupVersion,
+			AccountId: account.Id,
+			CreatedAt: time.Now().UTC(),
+		},
+		Accounts: []BackupAccount{{
+			Id:          account.Id,
+			Name:        account.Name,
+			Description: account.Description,
+			Currency:    account.Currency,
+			CreatedAt:   account.CreatedAt,
+			UpdatedAt:   account.UpdatedAt,
+		}},
+		Accesses:   []BackupAccess{},
+		Recipients: []BackupRecipient{},
+		Events:     []BackupEvent{},
+		Payments:   []BackupPayment{},
+	}
+
+	for _, access := range accesses {
+		backup.Accesses = append(backup.Accesses, BackupAccess(*access))
+	}
+
+	for _, recipient := range recipients {
+		backup.Recipients = append(backup.Recipients, BackupRecipient(*recipient))
+	}
+
+	for _, event := range events {
+		backup.Events = append(backup.Events, BackupEvent(*event))
+	}
+
+	for _, payment := range payments {
+		backup.Payments = append(backup.Payments, BackupPayment(*payment))
+	}
+
+	return packBackup(backup)
+}
+
+/*
+Restore is a function that re-creates the account from a backup archive
+with fresh ids, and gives admin access to the restoring user.
+Accesses of other users are not restored, their recipients are moved
+under the restoring user's access.
+Gives back the id of the new account.
+*/
+func (s *backupService) Restore(userId string, archive []byte) (string, error) {
+	defer observe(s.ctx, "backup", "Restore")()
+
+	backup, err := unpackBackup(archive)
+	if err != nil {
+		return "", err
+	}
+
+	if len(backup.Accounts) != 1 {
+		return "", errors.New("Backup should contain exactly one account")
+	}
+
+	account := backup.Accounts[0]
+	now := time.Now().UTC()
+
+	accountId := utils.NewUUID("acc")
+	accessId := utils.NewUUID("acs")
+
+	eventIds := map[string]string{}
+	recipientIds := map[string]string{}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO account (
+			id,
+			name,
+			description,
+			currency,
+			created_at,
+			updated_at
+		) VALUES (?, ?, ?, ?, ?, ?)`,
+		accountId,
+		account.Name,
+		account.Description,
+		account.Currency,
+		account.CreatedAt,
+		now,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO access (
+			id,
+			role,
+			created_at,
+			updated_at,
+			user_id,
+			account_id
+		) VALUES (?, ?, ?, ?, ?, ?)`,
+		accessId,
+		Admin,
+		now,
+		now,
+		userId,
+		accountId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	for _, recipient := range backup.Recipients {
+		id := utils.NewUUID("rcp")
+		recipientIds[recipient.Id] = id
+
+		_, err = tx.Exec(
+			`INSERT INTO recipient (
+				id,
+				name,
+				created_at,
+				updated_at,
+				access_id
+			) VALUES (?, ?, ?, ?, ?)`,
+			id,
+			recipient.Name,
+			recipient.CreatedAt,
+			now,
+			accessId,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, event := range backup.Events {
+		id := utils.NewUUID("evt")
+		eventIds[event.Id] = id
+
+		_, err = tx.Exec(
+			`INSERT INTO event (
+				id,
+				name,
+				description,
+				income,
+				reserved,
+				delivered_at,
+				created_at,
+				updated_at,
+				account_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id,
+			event.Name,
+			event.Description,
+			event.Income,
+			event.Reserved,
+			event.DeliveredAt,
+			event.CreatedAt,
+			now,
+			accountId,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, payment := range backup.Payments {
+		eventId, found := eventIds[payment.EventId]
+		if !found {
+			return "", fmt.Errorf("Payment %s references unknown event %s", payment.Id, payment.EventId)
+		}
+
+		recipientId, found := recipientIds[payment.RecipientId]
+		if !found {
+			return "", fmt.Errorf("Payment %s references unknown recipient %s", payment.Id, payment.RecipientId)
+		}
+
+		paid := 0
+		if payment.Paid {
+			paid = 1
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO payment (
+				id,
+				factor,
+				extra,
+				paid,
+				paid_at,
+				created_at,
+				updated_at,
+				event_id,
+				recipient_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			utils.NewUUID("pay"),
+			payment.Factor,
+			payment.Extra,
+			paid,
+			payment.PaidAt,
+			payment.CreatedAt,
+			now,
+			eventId,
+			recipientId,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return accountId, nil
+}
+
+/*
+packBackup writes the tables, the manifest and the manifest checksum
+into a zip archive. The manifest files are filled in here.
+*/
+func packBackup(backup *Backup) ([]byte, error) {
+	tables := []struct {
+		name string
+		rows int
+		data any
+	}{
+		{backupAccountFile, len(backup.Accounts), backup.Accounts},
+		{backupAccessFile, len(backup.Accesses), backup.Accesses},
+		{backupRecipientFile, len(backup.Recipients), backup.Recipients},
+		{backupEventFile, len(backup.Events), backup.Events},
+		{backupPaymentFile, len(backup.Payments), backup.Payments},
+	}
+
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+
+	backup.Manifest.Files = []BackupManifestFile{}
+
+	for _, table := range tables {
+		content, err := json.MarshalIndent(table.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeZipFile(archive, table.name, content)
+		if err != nil {
+			return nil, err
+		}
+
+		backup.Manifest.Files = append(backup.Manifest.Files, BackupManifestFile{
+			Name:   table.name,
+			Rows:   table.rows,
+			Sha256: checksum(content),
+		})
+	}
+
+	manifest, err := json.MarshalIndent(backup.Manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeZipFile(archive, backupManifestFile, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeZipFile(archive, backupChecksumFile, []byte(checksum(manifest)+"\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+/*
+unpackBackup reads a zip archive written by packBackup,
+and verifies the manifest and table checksums.
+*/
+func unpackBackup(data []byte) (*Backup, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+
+	for _, file := range archive.File {
+		if !backupFiles[file.Name] {
+			continue
+		}
+
+		if _, found := files[file.Name]; found {
+			return nil, fmt.Errorf("Backup file %s is duplicated", file.Name)
+		}
+
+		content, err := readZipFile(file, maxBackupFileSize)
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name] = content
+	}
+
+	manifest, found := files[backupManifestFile]
+	if !found {
+		return nil, errors.New("Backup manifest is missing")
+	}
+
+	manifestChecksum, found := files[backupChecksumFile]
+	if !found {
+		return nil, errors.New("Backup manifest checksum is missing")
+	}
+
+	if strings.TrimSpace(string(manifestChecksum)) != checksum(manifest) {
+		return nil, errors.New("Backup manifest checksum does not match")
+	}
+
+	backup := &Backup{}
+
+	err = json.Unmarshal(manifest, &backup.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if backup.Manifest.Version != BackupVersion {
+		return nil, fmt.Errorf("Unsupported backup version %d", backup.Manifest.Version)
+	}
+
+	targets := map[string]any{
+		backupAccountFile:   &backup.Accounts,
+		backupAccessFile:    &backup.Accesses,
+		backupRecipientFile: &backup.Recipients,
+		backupEventFile:     &backup.Events,
+		backupPaymentFile:   &backup.Payments,
+	}
+
+	for _, file := range backup.Manifest.Files {
+		target, known := targets[file.Name]
+		if !known {
+			return nil, fmt.Errorf("Unknown backup file %s", file.Name)
+		}
+
+		content, found := files[file.Name]
+		if !found {
+			return nil, fmt.Errorf("Backup file %s is missing", file.Name)
+		}
+
+		if checksum(content) != file.Sha256 {
+			return nil, fmt.Errorf("Backup file %s checksum does not match", file.Name)
+		}
+
+		err = json.Unmarshal(content, target)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(targets, file.Name)
+	}
+
+	if len(targets) != 0 {
+		return nil, errors.New("Backup manifest does not list every table")
+	}
+
+	return backup, nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(content)
+	return err
+}
+
+/*
+readZipFile reads a file of the archive, up to limit bytes. The size in the
+header is checked first, and the read is limited too, as the header can lie.
+*/
+func readZipFile(file *zip.File, limit int64) ([]byte, error) {
+	if file.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("Backup file %s is too large", file.Name)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("Backup file %s is too large", file.Name)
+	}
+
+	return content, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
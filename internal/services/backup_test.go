@@ -0,0 +1,171 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func testBackup() *Backup {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	return &Backup{
+		Manifest: BackupManifest{
+			Version:   BackupVersion,
+			AccountId: "acc_1",
+			CreatedAt: now,
+		},
+		Accounts:   []BackupAccount{{Id: "acc_1", Name: "Band", Currency: "EUR", CreatedAt: now, UpdatedAt: now}},
+		Accesses:   []BackupAccess{{Id: "acs_1", Role: Admin, UserId: "usr_1", AccountId: "acc_1", CreatedAt: now, UpdatedAt: now}},
+		Recipients: []BackupRecipient{{Id: "rcp_1", Name: "Drummer", AccessId: "acs_1", CreatedAt: now, UpdatedAt: now}},
+		Events:     []BackupEvent{{Id: "evt_1", Name: "Gig", Income: 100, Reserved: 20, DeliveredAt: now, AccountId: "acc_1", CreatedAt: now, UpdatedAt: now}},
+		Payments:   []BackupPayment{{Id: "pay_1", Factor: 1, Paid: true, PaidAt: &now, EventId: "evt_1", RecipientId: "rcp_1", CreatedAt: now, UpdatedAt: now}},
+	}
+}
+
+func TestPackUnpackBackup(t *testing.T) {
+	archive, err := packBackup(testBackup())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	backup, err := unpackBackup(archive)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(backup.Manifest.Files) != 5 {
+		t.Errorf("Expected 5 manifest files, got %d", len(backup.Manifest.Files))
+	}
+
+	if len(backup.Events) != 1 || backup.Events[0].Income != 100 {
+		t.Errorf("Expected event with income 100, got %v", backup.Events)
+	}
+
+	if len(backup.Payments) != 1 || backup.Payments[0].PaidAt == nil {
+		t.Errorf("Expected paid payment, got %v", backup.Payments)
+	}
+}
+
+func TestUnpackBackupChecksumMismatch(t *testing.T) {
+	archive, err := packBackup(testBackup())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// rewrite the archive with a tampered event table
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+
+	for _, file := range reader.File {
+		content, err := readZipFile(file, maxBackupFileSize)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if file.Name == backupEventFile {
+			content = bytes.Replace(content, []byte("100"), []byte("900"), 1)
+		}
+
+		err = writeZipFile(writer, file.Name, content)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = unpackBackup(buf.Bytes())
+	if err == nil {
+		t.Errorf("Expected checksum error, got nil")
+	}
+}
+
+func TestUnpackBackupSkipsUnknownFiles(t *testing.T) {
+	archive, err := packBackup(testBackup())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// add an entry larger than the limit, that is never read
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+
+	for _, file := range reader.File {
+		err = writer.Copy(file)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	err = writeZipFile(writer, "bomb.bin", make([]byte, maxBackupFileSize+1))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = unpackBackup(buf.Bytes())
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestReadZipFileLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+
+	err := writeZipFile(writer, backupEventFile, bytes.Repeat([]byte("0"), 100))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	file := reader.File[0]
+
+	_, err = readZipFile(file, 99)
+	if err == nil {
+		t.Errorf("Expected error for a file over the limit")
+	}
+
+	// a header understating the size is caught by the limited read
+	file.UncompressedSize64 = 10
+
+	_, err = readZipFile(file, 99)
+	if err == nil {
+		t.Errorf("Expected error for a file with a lying header")
+	}
+
+	file.UncompressedSize64 = 100
+
+	content, err := readZipFile(file, 100)
+	if err != nil || len(content) != 100 {
+		t.Errorf("Expected 100 bytes, got %d, %v", len(content), err)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pengoe/internal/utils"
+	"time"
+)
+
+/*
+challengeLifetime is how long the second step of the signin can take.
+*/
+const challengeLifetime = 5 * time.Minute
+
+/*
+maxChallengeAttempts is how many codes can be tried for one signin,
+then the password is asked again.
+*/
+const maxChallengeAttempts = 5
+
+/*
+ErrInvalidChallenge is returned for unknown, expired or overused challenges.
+*/
+var ErrInvalidChallenge = errors.New("Invalid or expired signin")
+
+/*
+SigninChallenge is a signin with the right password,
+waiting for the code of the second step.
+*/
+type SigninChallenge struct {
+	UserId   string
+	Remember bool
+}
+
+type SigninChallengeServiceInterface interface {
+	New(userId string, remember bool) (string, error)
+	Attempt(token string) (*SigninChallenge, error)
+	Delete(token string) error
+}
+
+type signinChallengeService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewSigninChallengeService(ctx context.Context, db *sql.DB) SigninChallengeServiceInterface {
+	return &signinChallengeService{ctx: ctx, db: db}
+}
+
+/*
+New creates a challenge for the user, and returns its token for the cookie.
+*/
+func (s *signinChallengeService) New(userId string, remember bool) (string, error) {
+	defer observe(s.ctx, "signin_challenge", "New")()
+
+	token, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	// the driver does not take bools
+	rememberInt := 0
+	if remember {
+		rememberInt = 1
+	}
+
+	now := time.Now().UTC()
+
+	_, err = s.db.Exec(
+		`INSERT INTO signin_challenge (
+			id,
+			remember,
+			attempts,
+			expires_at,
+			created_at,
+			user_id
+		) VALUES (?, ?, 0, ?, ?, ?)`,
+		hashToken(token),
+		rememberInt,
+		now.Add(challengeLifetime),
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+/*
+Attempt counts an attempt of the challenge, and returns it if it can still
+be tried. The attempt is counted before the code is checked.
+*/
+func (s *signinChallengeService) Attempt(token string) (*SigninChallenge, error) {
+	defer observe(s.ctx, "signin_challenge", "Attempt")()
+
+	id := hashToken(token)
+
+	result, err := s.db.Exec(
+		`UPDATE signin_challenge
+		SET attempts = attempts + 1
+		WHERE id = ? AND expires_at > ? AND attempts < ?`,
+		id,
+		time.Now().UTC(),
+		maxChallengeAttempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrInvalidChallenge
+	}
+
+	challenge := &SigninChallenge{}
+	var remember int
+
+	err = s.db.QueryRow(
+		`SELECT user_id, remember FROM signin_challenge
+		WHERE id = ?`,
+		id,
+	).Scan(&challenge.UserId, &remember)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	challenge.Remember = remember == 1
+
+	return challenge, nil
+}
+
+/*
+Delete deletes the challenge, after the signin or when giving up.
+The expired ones of everyone are deleted too.
+*/
+func (s *signinChallengeService) Delete(token string) error {
+	defer observe(s.ctx, "signin_challenge", "Delete")()
+
+	_, err := s.db.Exec(
+		`DELETE FROM signin_challenge
+		WHERE id = ? OR expires_at <= ?`,
+		hashToken(token),
+		time.Now().UTC(),
+	)
+
+	return err
+}
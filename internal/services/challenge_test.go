@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSigninChallengeAttempts(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	challenges := NewSigninChallengeService(context.Background(), db)
+
+	token, err := challenges.New("usr_1", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i := 0; i < maxChallengeAttempts; i++ {
+		challenge, err := challenges.Attempt(token)
+		if err != nil {
+			t.Fatalf("Expected attempt %d to be allowed, got %v", i+1, err)
+		}
+		if challenge.UserId != "usr_1" || !challenge.Remember {
+			t.Errorf("Expected usr_1 remembered, got %+v", challenge)
+		}
+	}
+
+	_, err = challenges.Attempt(token)
+	if !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected ErrInvalidChallenge after %d attempts, got %v", maxChallengeAttempts, err)
+	}
+
+	_, err = challenges.Attempt("unknown")
+	if !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected ErrInvalidChallenge for an unknown token, got %v", err)
+	}
+}
+
+func TestSigninChallengeExpiredAndDeleted(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	challenges := NewSigninChallengeService(context.Background(), db)
+
+	expired, err := challenges.New("usr_1", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = db.Exec(
+		`UPDATE signin_challenge SET expires_at = ?`,
+		time.Now().UTC().Add(-time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = challenges.Attempt(expired)
+	if !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected ErrInvalidChallenge for an expired challenge, got %v", err)
+	}
+
+	token, err := challenges.New("usr_1", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = challenges.Delete(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = challenges.Attempt(token)
+	if !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected ErrInvalidChallenge for a deleted challenge, got %v", err)
+	}
+
+	// the expired ones are deleted too
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM signin_challenge`).Scan(&count)
+	if err != nil || count != 0 {
+		t.Errorf("Expected no challenges left, got %d, %v", count, err)
+	}
+}
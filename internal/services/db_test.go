@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+/*
+newTestDB returns a new SQLite database with the schema of the app, in a
+temporary directory. Times are stored in the format of the libsql driver.
+*/
+func newTestDB(t *testing.T) *sql.DB {
+	schema, err := os.ReadFile("../db/schema.sqlite")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_time_format=sqlite&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return db
+}
+
+/*
+newTestUser signs up a user, with a verified email if verified is true.
+*/
+func newTestUser(t *testing.T, db *sql.DB, id, email string, verified bool) {
+	users := NewUserService(context.Background(), db)
+
+	err := users.Signup(id, id, email, "Jane", "Doe", "password")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !verified {
+		return
+	}
+
+	_, err = db.Exec(
+		`UPDATE user SET email_verified_at = ? WHERE id = ?`,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"pengoe/internal/utils"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,26 +25,89 @@ type Event struct {
 	AccountId   string
 }
 
+type EventSort string
+
+const (
+	EventSortDeliveredAt EventSort = "delivered_at"
+	EventSortName        EventSort = "name"
+	EventSortIncome      EventSort = "income"
+	EventSortReserved    EventSort = "reserved"
+)
+
+/*
+ParseEventSort is a function that validates a sort key from user input.
+*/
+func ParseEventSort(s string) (EventSort, error) {
+	switch sort := EventSort(s); sort {
+	case EventSortDeliveredAt, EventSortName, EventSortIncome, EventSortReserved:
+		return sort, nil
+	}
+	return "", fmt.Errorf("Unknown sort key %q", s)
+}
+
+/*
+ErrInvalidCursor is returned for cursors that were not made by Search
+for the same sort order.
+*/
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+/*
+DefaultEventLimit is the page size of the event list, when no limit is given.
+*/
+const DefaultEventLimit = 20
+
+/*
+EventFilter selects, orders and pages the events of an account.
+From is inclusive, To is exclusive, zero values are unbounded.
+MinIncome and MaxIncome are inclusive, nil values are unbounded.
+Search matches the name or the description, case insensitive.
+Cursor is the NextCursor of the previous page, empty for the first page.
+*/
+type EventFilter struct {
+	AccountId  string
+	From       time.Time
+	To         time.Time
+	MinIncome  *int
+	MaxIncome  *int
+	Search     string
+	Sort       EventSort
+	Descending bool
+	Cursor     string
+	Limit      int
+}
+
+/*
+EventPage is one page of events, NextCursor is empty on the last page.
+*/
+type EventPage struct {
+	Events     []*Event
+	NextCursor string
+}
+
 type EventService interface {
 	New(id, name, description string, income, reserved int, deliveredAt time.Time, accountId string) error
 	GetById(id string) (*Event, error)
 	GetByAccountId(accountId string) ([]*Event, error)
+	Search(filter EventFilter) (*EventPage, error)
 	Update(id, name, description string, income, reserved int, deliveredAt time.Time) error
 	Delete(id string) error
 }
 
 type eventService struct {
-	db *sql.DB
+	ctx context.Context
+	db  *sql.DB
 }
 
-func NewEventService(db *sql.DB) EventService {
-	return &eventService{db: db}
+func NewEventService(ctx context.Context, db *sql.DB) EventService {
+	return &eventService{ctx: ctx, db: db}
 }
 
 /*
 New is a function that adds an event to the database.
 */
 func (s *eventService) New(id, name, description string, income, reserved int, deliveredAt time.Time, accountId string) error {
+	defer observe(s.ctx, "event", "New")()
+
 	now := time.Now().UTC()
 
 	_, err := s.db.Exec(
@@ -75,6 +144,8 @@ func (s *eventService) New(id, name, description string, income, reserved int, d
 GetById is a function that returns an event by id.
 */
 func (s *eventService) GetById(id string) (*Event, error) {
+	defer observe(s.ctx, "event", "GetById")()
+
 	row := s.db.QueryRow(
 		`SELECT
 			id,
@@ -139,6 +210,8 @@ func (s *eventService) GetById(id string) (*Event, error) {
 GetByAccountId is a function that returns all events for an account.
 */
 func (s *eventService) GetByAccountId(accountId string) ([]*Event, error) {
+	defer observe(s.ctx, "event", "GetByAccountId")()
+
 	rows, err := s.db.Query(
 		`SELECT
 			id,
@@ -159,60 +232,145 @@ func (s *eventService) GetByAccountId(accountId string) ([]*Event, error) {
 		return nil, err
 	}
 
-	events := []*Event{}
+	return scanEvents(rows)
+}
 
-	for rows.Next() {
-		event := &Event{}
+/*
+Search is a function that returns a page of the events of an account,
+filtered and sorted by the filter. Pages are keyset paginated on the sort
+key and the id, so they stay stable while events are added or deleted.
+*/
+func (s *eventService) Search(filter EventFilter) (*EventPage, error) {
+	defer observe(s.ctx, "event", "Search")()
 
-		var deliveredAtStr string
-		var createdAtStr string
-		var updatedAtStr string
+	if filter.Sort == "" {
+		filter.Sort = EventSortDeliveredAt
+	}
 
-		err := rows.Scan(
-			&event.Id,
-			&event.Name,
-			&event.Description,
-			&event.Income,
-			&event.Reserved,
-			&deliveredAtStr,
-			&createdAtStr,
-			&updatedAtStr,
-			&event.AccountId,
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultEventLimit
+	}
+
+	conditions := []string{"account_id = ?"}
+	args := []any{filter.AccountId}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "delivered_at >= ?")
+		args = append(args, filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "delivered_at < ?")
+		args = append(args, filter.To)
+	}
+
+	if filter.MinIncome != nil {
+		conditions = append(conditions, "income >= ?")
+		args = append(args, *filter.MinIncome)
+	}
+
+	if filter.MaxIncome != nil {
+		conditions = append(conditions, "income <= ?")
+		args = append(args, *filter.MaxIncome)
+	}
+
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		conditions = append(
+			conditions,
+			`(name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`,
 		)
+		args = append(args, pattern, pattern)
+	}
 
+	operator := ">"
+	direction := "ASC"
+	if filter.Descending {
+		operator = "<"
+		direction = "DESC"
+	}
+
+	column := string(filter.Sort)
+
+	if filter.Cursor != "" {
+		cursor, err := decodeEventCursor(filter.Cursor)
 		if err != nil {
 			return nil, err
 		}
 
-		deliveredAt, err := utils.ConvertToTime(deliveredAtStr)
-		if err != nil {
-			return nil, err
+		if cursor.Sort != filter.Sort || cursor.Descending != filter.Descending {
+			return nil, fmt.Errorf("%w: does not match the sort order", ErrInvalidCursor)
 		}
 
-		createdAt, err := utils.ConvertToTime(createdAtStr)
+		value, err := cursor.value()
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err.Error())
 		}
 
-		updatedAt, err := utils.ConvertToTime(updatedAtStr)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s %s ? OR (%s = ? AND id %s ?))",
+			column, operator, column, operator,
+		))
+		args = append(args, value, value, cursor.Id)
+	}
+
+	// one more than the limit, to know if there is a next page
+	args = append(args, filter.Limit+1)
+
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			`SELECT
+				id,
+				name,
+				description,
+				income,
+				reserved,
+				delivered_at,
+				created_at,
+				updated_at,
+				account_id
+			FROM event
+			%s
+			ORDER BY %s %s, id %s
+			LIMIT ?;`,
+			where(conditions),
+			column,
+			direction,
+			direction,
+		),
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &EventPage{Events: events}
+
+	if len(events) > filter.Limit {
+		page.Events = events[:filter.Limit]
+
+		last := page.Events[filter.Limit-1]
+		page.NextCursor, err = encodeEventCursor(newEventCursor(filter, last))
 		if err != nil {
 			return nil, err
 		}
-
-		event.DeliveredAt = deliveredAt
-		event.CreatedAt = createdAt
-		event.UpdatedAt = updatedAt
-
-		events = append(events, event)
 	}
 
-	return events, nil
+	return page, nil
 }
 
 /*
 Update is a function that updates an event in the database.
 */
 func (s *eventService) Update(id, name, description string, income, reserved int, deliveredAt time.Time) error {
+	defer observe(s.ctx, "event", "Update")()
+
 	mutation, err := s.db.Exec(
 		`UPDATE event
 		SET
@@ -252,6 +410,8 @@ func (s *eventService) Update(id, name, description string, income, reserved int
 Delete is a function that deletes an event from the database.
 */
 func (s *eventService) Delete(id string) error {
+	defer observe(s.ctx, "event", "Delete")()
+
 	mutation, err := s.db.Exec(
 		`DELETE FROM event
 		WHERE id = ?;`,
@@ -273,3 +433,148 @@ func (s *eventService) Delete(id string) error {
 
 	return nil
 }
+
+/*
+scanEvents is a function that reads all events from the rows and closes them.
+*/
+func scanEvents(rows *sql.Rows) ([]*Event, error) {
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		event := &Event{}
+
+		var deliveredAtStr string
+		var createdAtStr string
+		var updatedAtStr string
+
+		err := rows.Scan(
+			&event.Id,
+			&event.Name,
+			&event.Description,
+			&event.Income,
+			&event.Reserved,
+			&deliveredAtStr,
+			&createdAtStr,
+			&updatedAtStr,
+			&event.AccountId,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveredAt, err := utils.ConvertToTime(deliveredAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt, err := utils.ConvertToTime(createdAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		updatedAt, err := utils.ConvertToTime(updatedAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		event.DeliveredAt = deliveredAt
+		event.CreatedAt = createdAt
+		event.UpdatedAt = updatedAt
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+/*
+eventCursor is the position after the last event of a page:
+its sort value and its id, with the order it was sorted by.
+*/
+type eventCursor struct {
+	Sort       EventSort `json:"s"`
+	Descending bool      `json:"d"`
+	Value      string    `json:"v"`
+	Id         string    `json:"id"`
+}
+
+func newEventCursor(filter EventFilter, event *Event) eventCursor {
+	cursor := eventCursor{
+		Sort:       filter.Sort,
+		Descending: filter.Descending,
+		Id:         event.Id,
+	}
+
+	switch filter.Sort {
+	case EventSortDeliveredAt:
+		cursor.Value = event.DeliveredAt.UTC().Format(time.RFC3339Nano)
+	case EventSortName:
+		cursor.Value = event.Name
+	case EventSortIncome:
+		cursor.Value = strconv.Itoa(event.Income)
+	case EventSortReserved:
+		cursor.Value = strconv.Itoa(event.Reserved)
+	}
+
+	return cursor
+}
+
+/*
+value is a function that returns the sort value of the cursor as a query argument.
+*/
+func (c eventCursor) value() (any, error) {
+	switch c.Sort {
+	case EventSortDeliveredAt:
+		return time.Parse(time.RFC3339Nano, c.Value)
+	case EventSortName:
+		return c.Value, nil
+	case EventSortIncome, EventSortReserved:
+		return strconv.Atoi(c.Value)
+	}
+	return nil, fmt.Errorf("Unknown sort key %q", c.Sort)
+}
+
+/*
+encodeEventCursor is a function that encodes the cursor into an url safe string.
+*/
+func encodeEventCursor(cursor eventCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+/*
+decodeEventCursor is a function that decodes a cursor from user input.
+*/
+func decodeEventCursor(s string) (eventCursor, error) {
+	cursor := eventCursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.Id == "" {
+		return cursor, ErrInvalidCursor
+	}
+
+	if _, err := ParseEventSort(string(cursor.Sort)); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+/*
+escapeLike is a function that escapes the wildcards of a LIKE pattern.
+*/
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(s)
+}
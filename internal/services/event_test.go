@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEventCursor(t *testing.T) {
+	event := &Event{
+		Id:          "evt_1",
+		Name:        "Gig",
+		Income:      100,
+		DeliveredAt: time.Date(2024, 1, 12, 10, 30, 0, 500, time.UTC),
+	}
+
+	tests := []struct {
+		sort     EventSort
+		expected any
+	}{
+		{EventSortDeliveredAt, event.DeliveredAt},
+		{EventSortName, "Gig"},
+		{EventSortIncome, 100},
+		{EventSortReserved, 0},
+	}
+
+	for _, test := range tests {
+		filter := EventFilter{Sort: test.sort, Descending: true}
+
+		encoded, err := encodeEventCursor(newEventCursor(filter, event))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		cursor, err := decodeEventCursor(encoded)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if cursor.Id != event.Id || cursor.Sort != test.sort || !cursor.Descending {
+			t.Errorf("Expected cursor of %s, got %+v", test.sort, cursor)
+		}
+
+		value, err := cursor.value()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if value != test.expected {
+			t.Errorf("Expected %v, got %v", test.expected, value)
+		}
+	}
+}
+
+func TestDecodeEventCursorInvalid(t *testing.T) {
+	invalid := []string{
+		"not base64!",
+		"bm90IGpzb24", // not json
+		"eyJzIjoiZm9vIiwidiI6IjEiLCJpZCI6ImV2dF8xIn0", // unknown sort key
+		"eyJzIjoibmFtZSIsInYiOiJHaWcifQ",              // no id
+	}
+
+	for _, s := range invalid {
+		_, err := decodeEventCursor(s)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestSearchInvalidCursor(t *testing.T) {
+	events := NewEventService(context.Background(), newTestDB(t))
+
+	cursor, err := encodeEventCursor(eventCursor{Sort: EventSortName, Value: "Gig", Id: "evt_1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	badValue, err := encodeEventCursor(eventCursor{Sort: EventSortIncome, Value: "many", Id: "evt_1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	filters := []EventFilter{
+		{AccountId: "acc_1", Cursor: "not base64!"},
+		{AccountId: "acc_1", Sort: EventSortIncome, Cursor: cursor},
+		{AccountId: "acc_1", Sort: EventSortIncome, Cursor: badValue},
+	}
+
+	for _, filter := range filters {
+		_, err := events.Search(filter)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %+v, got %v", filter, err)
+		}
+	}
+
+	_, err = events.Search(EventFilter{AccountId: "acc_1", Sort: EventSortName, Cursor: cursor})
+	if err != nil {
+		t.Errorf("Expected no error for a matching cursor, got %v", err)
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	db := newTestDB(t)
+
+	accounts := NewAccountService(context.Background(), db)
+	events := NewEventService(context.Background(), db)
+
+	for _, id := range []string{"acc_1", "acc_2"} {
+		err := accounts.New(id, "Account", "", "EUR")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// three events on each day and with each income, ties on both sort keys
+	day := time.Date(2024, 1, 12, 10, 30, 0, 500, time.UTC)
+	count := 12
+
+	for i := 0; i < count; i++ {
+		err := events.New(
+			fmt.Sprintf("evt_%02d", i),
+			"Gig",
+			"",
+			(i%3)*100,
+			0,
+			day.AddDate(0, 0, i%4),
+			"acc_1",
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	err := events.New("evt_other", "Gig", "", 100, 0, day, "acc_2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []EventFilter{
+		{Sort: EventSortDeliveredAt},
+		{Sort: EventSortDeliveredAt, Descending: true},
+		{Sort: EventSortIncome},
+		{Sort: EventSortIncome, Descending: true},
+	}
+
+	for _, filter := range tests {
+		filter.AccountId = "acc_1"
+		filter.Limit = 4
+
+		seen := map[string]int{}
+		var previous *Event
+		pages := 0
+
+		for {
+			page, err := events.Search(filter)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			pages++
+
+			for _, event := range page.Events {
+				seen[event.Id]++
+
+				if previous != nil && !inEventOrder(filter, previous, event) {
+					t.Errorf("Expected %s after %s for %+v", event.Id, previous.Id, filter)
+				}
+				previous = event
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+
+			if pages > count {
+				t.Fatalf("Expected the pages to end for %+v", filter)
+			}
+
+			filter.Cursor = page.NextCursor
+		}
+
+		// the last page is full, the empty cursor still ends the list
+		if pages != count/filter.Limit {
+			t.Errorf("Expected %d pages for %+v, got %d", count/filter.Limit, filter, pages)
+		}
+
+		if len(seen) != count {
+			t.Errorf("Expected %d events for %+v, got %d", count, filter, len(seen))
+		}
+
+		for id, n := range seen {
+			if n != 1 {
+				t.Errorf("Expected %s once for %+v, got %d times", id, filter, n)
+			}
+		}
+	}
+}
+
+/*
+inEventOrder tells if b can follow a in the order of the filter,
+on the sort key and then on the id.
+*/
+func inEventOrder(filter EventFilter, a, b *Event) bool {
+	var cmp int
+
+	switch filter.Sort {
+	case EventSortDeliveredAt:
+		cmp = a.DeliveredAt.Compare(b.DeliveredAt)
+	case EventSortIncome:
+		cmp = a.Income - b.Income
+	}
+
+	if cmp == 0 {
+		if a.Id < b.Id {
+			cmp = -1
+		} else {
+			cmp = 1
+		}
+	}
+
+	if filter.Descending {
+		return cmp > 0
+	}
+
+	return cmp < 0
+}
+
+func TestEscapeLike(t *testing.T) {
+	expected := `50\% off\_now \\o/`
+
+	result := escapeLike(`50% off_now \o/`)
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
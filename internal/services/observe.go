@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"pengoe/internal/metrics"
+	"pengoe/internal/tracing"
+)
+
+/*
+observe is a function that starts a span and a timer for the database calls
+of a service method, and returns the function that ends them.
+Eg. defer observe(s.ctx, "event", "Search")()
+*/
+func observe(ctx context.Context, service, method string) func() {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, span := tracing.Start(
+		ctx,
+		"db "+service+"."+method,
+		tracing.String("db.system", "sqlite"),
+		tracing.String("code.namespace", "services."+service),
+		tracing.String("code.function", method),
+	)
+	done := metrics.ObserveQuery(service, method)
+
+	return func() {
+		done()
+		span.End()
+	}
+}
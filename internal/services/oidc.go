@@ -0,0 +1,432 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html"
+	"pengoe/internal/oidc"
+	"pengoe/internal/utils"
+	"strings"
+	"time"
+)
+
+/*
+oidcStateLifetime is how long the signin at the provider can take.
+*/
+const oidcStateLifetime = 10 * time.Minute
+
+/*
+maxUsernameLength is the longest username made from the claims.
+*/
+const maxUsernameLength = 32
+
+var (
+	// ErrInvalidOIDCState is returned for unknown, expired or used states
+	ErrInvalidOIDCState = errors.New("Invalid or expired signin")
+
+	// ErrEmailNotVerified is returned when the provider did not verify the email
+	ErrEmailNotVerified = errors.New("The email is not verified by the provider")
+
+	// ErrAccountNotLinked is returned when a user has the email, but did not
+	// verify it, so it may not be theirs
+	ErrAccountNotLinked = errors.New("The account with the email is not verified")
+)
+
+/*
+OIDCState is a signin waiting for the callback of the provider.
+*/
+type OIDCState struct {
+	Flow     *oidc.Flow
+	Redirect string
+	Remember bool
+}
+
+type OIDCServiceInterface interface {
+	NewState(redirect string, remember bool) (*oidc.Flow, error)
+	UseState(state string) (*OIDCState, error)
+	SignIn(claims *oidc.Claims) (string, error)
+}
+
+type oidcService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewOIDCService(ctx context.Context, db *sql.DB) OIDCServiceInterface {
+	return &oidcService{ctx: ctx, db: db}
+}
+
+/*
+NewState starts a signin, and returns the values of the flow.
+Only the hash of the state is stored, the expired ones of everyone
+are deleted.
+*/
+func (s *oidcService) NewState(redirect string, remember bool) (*oidc.Flow, error) {
+	defer observe(s.ctx, "oidc", "NewState")()
+
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		return nil, err
+	}
+
+	// the driver does not take bools
+	rememberInt := 0
+	if remember {
+		rememberInt = 1
+	}
+
+	now := time.Now().UTC()
+
+	_, err = s.db.Exec(
+		`DELETE FROM oidc_state
+		WHERE expires_at <= ?`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO oidc_state (
+			id,
+			nonce,
+			code_verifier,
+			redirect,
+			remember,
+			expires_at,
+			created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hashToken(flow.State),
+		flow.Nonce,
+		flow.Verifier,
+		redirect,
+		rememberInt,
+		now.Add(oidcStateLifetime),
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return flow, nil
+}
+
+/*
+UseState uses up the state of the callback, and returns the signin.
+*/
+func (s *oidcService) UseState(state string) (*OIDCState, error) {
+	defer observe(s.ctx, "oidc", "UseState")()
+
+	id := hashToken(state)
+
+	result := &OIDCState{Flow: &oidc.Flow{State: state}}
+	var remember int
+	var expiresAtStr string
+
+	err := s.db.QueryRow(
+		`SELECT nonce, code_verifier, redirect, remember, expires_at
+		FROM oidc_state
+		WHERE id = ?`,
+		id,
+	).Scan(&result.Flow.Nonce, &result.Flow.Verifier, &result.Redirect, &remember, &expiresAtStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// only one request can use it
+	deleted, err := s.db.Exec(
+		`DELETE FROM oidc_state
+		WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := deleted.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	expiresAt, err := utils.ConvertToTime(expiresAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	result.Remember = remember == 1
+
+	return result, nil
+}
+
+/*
+SignIn returns the user of the provider account. An account signed in
+before has its user. Otherwise it is linked to the user with the same email
+(ignoring the case),
+or a new user is created, only if the provider verified the email.
+*/
+func (s *oidcService) SignIn(claims *oidc.Claims) (string, error) {
+	defer observe(s.ctx, "oidc", "SignIn")()
+
+	now := time.Now().UTC()
+
+	var userId string
+
+	err := s.db.QueryRow(
+		`SELECT user_id FROM oidc_identity
+		WHERE issuer = ? AND subject = ?`,
+		claims.Issuer,
+		claims.Subject,
+	).Scan(&userId)
+	if err == nil {
+		_, err = s.db.Exec(
+			`UPDATE oidc_identity
+			SET
+				email = ?,
+				last_used_at = ?
+			WHERE issuer = ? AND subject = ?`,
+			claims.Email,
+			now,
+			claims.Issuer,
+			claims.Subject,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		return userId, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		return "", ErrEmailNotVerified
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	user, err := userToLink(tx, claims.Email)
+
+	switch {
+	case err == nil:
+		// someone else may have signed up with the email
+		if !user.Verified() {
+			return "", ErrAccountNotLinked
+		}
+		userId = user.Id
+
+	case errors.Is(err, sql.ErrNoRows):
+		userId, err = createOIDCUser(tx, claims, now)
+		if err != nil {
+			return "", err
+		}
+
+	default:
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO oidc_identity (
+			issuer,
+			subject,
+			email,
+			last_used_at,
+			created_at,
+			user_id
+		) VALUES (?, ?, ?, ?, ?, ?)`,
+		claims.Issuer,
+		claims.Subject,
+		claims.Email,
+		now,
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return userId, tx.Commit()
+}
+
+/*
+userToLink returns the user with the email, compared escaped (like the signup
+form stores it) and ignoring the case. If more users have the email in
+different cases, none of them is linked, it is not known whose it is.
+*/
+func userToLink(tx *sql.Tx, email string) (*User, error) {
+	rows, err := tx.Query(
+		`SELECT `+userColumns+` FROM user
+		WHERE email = ? COLLATE NOCASE
+		LIMIT 2`,
+		html.EscapeString(email),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(users) {
+	case 0:
+		return nil, sql.ErrNoRows
+	case 1:
+		return users[0], nil
+	}
+
+	return nil, ErrAccountNotLinked
+}
+
+/*
+createOIDCUser creates a user from the claims, with a verified email.
+The password is random, the user can set one with a password reset.
+*/
+func createOIDCUser(tx *sql.Tx, claims *oidc.Claims, now time.Time) (string, error) {
+	random, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	password, err := utils.HashPassword(random)
+	if err != nil {
+		return "", err
+	}
+
+	username, err := uniqueUsername(tx, oidcUsername(claims))
+	if err != nil {
+		return "", err
+	}
+
+	firstname, lastname := oidcNames(claims)
+
+	id := utils.NewUUID("usr")
+
+	// escaped like the values of the signup form
+	_, err = tx.Exec(
+		`INSERT INTO user (
+			id,
+			username,
+			email,
+			firstname,
+			lastname,
+			password,
+			created_at,
+			updated_at,
+			email_verified_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id,
+		username,
+		html.EscapeString(claims.Email),
+		html.EscapeString(firstname),
+		html.EscapeString(lastname),
+		password,
+		now,
+		now,
+		now,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+/*
+uniqueUsername returns the username, or with a random suffix if it is taken.
+*/
+func uniqueUsername(tx *sql.Tx, username string) (string, error) {
+	candidate := username
+
+	for i := 0; i < 5; i++ {
+		var count int
+		err := tx.QueryRow(
+			`SELECT COUNT(*) FROM user WHERE username = ?`,
+			candidate,
+		).Scan(&count)
+		if err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix, err := utils.GenerateCSRFToken()
+		if err != nil {
+			return "", err
+		}
+		candidate = username + "-" + strings.ToLower(suffix[:6])
+	}
+
+	return "", errors.New("Could not find a free username")
+}
+
+/*
+oidcUsername makes a username from the preferred username, or the start of
+the email: lowercase letters, digits, dots, dashes and underscores.
+*/
+func oidcUsername(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	username := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return -1
+	}, strings.ToLower(name))
+
+	if len(username) > maxUsernameLength {
+		username = username[:maxUsernameLength]
+	}
+
+	if username == "" {
+		username = "user"
+	}
+
+	return username
+}
+
+/*
+oidcNames returns the first and last name from the claims,
+splitting the full name if they are not given.
+*/
+func oidcNames(claims *oidc.Claims) (string, string) {
+	if claims.GivenName != "" || claims.FamilyName != "" {
+		return claims.GivenName, claims.FamilyName
+	}
+
+	firstname, lastname, _ := strings.Cut(strings.TrimSpace(claims.Name), " ")
+
+	return firstname, strings.TrimSpace(lastname)
+}
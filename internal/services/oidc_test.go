@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"html"
+	"pengoe/internal/oidc"
+	"testing"
+)
+
+func TestOIDCUsername(t *testing.T) {
+	tests := []struct {
+		claims   oidc.Claims
+		expected string
+	}{
+		{oidc.Claims{PreferredUsername: "Jane.Doe", Email: "jd@example.com"}, "jane.doe"},
+		{oidc.Claims{Email: "jane+money@example.com"}, "janemoney"},
+		{oidc.Claims{PreferredUsername: "Jáne Dőe"}, "jnede"},
+		{oidc.Claims{PreferredUsername: "!!!"}, "user"},
+		{oidc.Claims{Email: "averyveryveryveryverylongusername123@example.com"}, "averyveryveryveryverylongusernam"},
+	}
+
+	for _, test := range tests {
+		result := oidcUsername(&test.claims)
+		if result != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, result)
+		}
+	}
+}
+
+func TestOIDCNames(t *testing.T) {
+	tests := []struct {
+		claims    oidc.Claims
+		firstname string
+		lastname  string
+	}{
+		{oidc.Claims{GivenName: "Jane", FamilyName: "Doe", Name: "Dr. Jane Doe"}, "Jane", "Doe"},
+		{oidc.Claims{Name: "Jane van Doe"}, "Jane", "van Doe"},
+		{oidc.Claims{Name: "Jane"}, "Jane", ""},
+		{oidc.Claims{}, "", ""},
+	}
+
+	for _, test := range tests {
+		firstname, lastname := oidcNames(&test.claims)
+		if firstname != test.firstname || lastname != test.lastname {
+			t.Errorf("Expected %q %q, got %q %q", test.firstname, test.lastname, firstname, lastname)
+		}
+	}
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		users    map[string]string
+		verified bool
+		claims   oidc.Claims
+		expected string
+		err      error
+	}{
+		{
+			name:     "new user",
+			claims:   oidc.Claims{Subject: "1", Email: "jane@corp.com", EmailVerified: true},
+			expected: "new",
+		},
+		{
+			name:   "email not verified by the provider",
+			claims: oidc.Claims{Subject: "1", Email: "jane@corp.com"},
+			err:    ErrEmailNotVerified,
+		},
+		{
+			name:     "linked by email",
+			users:    map[string]string{"usr_1": "jane@corp.com"},
+			verified: true,
+			claims:   oidc.Claims{Subject: "1", Email: "jane@corp.com", EmailVerified: true},
+			expected: "usr_1",
+		},
+		{
+			name:     "linked ignoring the case",
+			users:    map[string]string{"usr_1": "jane@corp.com"},
+			verified: true,
+			claims:   oidc.Claims{Subject: "1", Email: "Jane@Corp.com", EmailVerified: true},
+			expected: "usr_1",
+		},
+		{
+			name:     "linked with escaped email",
+			users:    map[string]string{"usr_1": html.EscapeString("o'neil&co@corp.com")},
+			verified: true,
+			claims:   oidc.Claims{Subject: "1", Email: "O'Neil&co@corp.com", EmailVerified: true},
+			expected: "usr_1",
+		},
+		{
+			name:   "not verified locally",
+			users:  map[string]string{"usr_1": "jane@corp.com"},
+			claims: oidc.Claims{Subject: "1", Email: "jane@corp.com", EmailVerified: true},
+			err:    ErrAccountNotLinked,
+		},
+		{
+			name:     "more users with the email",
+			users:    map[string]string{"usr_1": "jane@corp.com", "usr_2": "JANE@corp.com"},
+			verified: true,
+			claims:   oidc.Claims{Subject: "1", Email: "Jane@corp.com", EmailVerified: true},
+			err:      ErrAccountNotLinked,
+		},
+	}
+
+	for _, test := range tests {
+		db := newTestDB(t)
+		for id, email := range test.users {
+			newTestUser(t, db, id, email, test.verified)
+		}
+
+		service := NewOIDCService(context.Background(), db)
+		test.claims.Issuer = "https://sso.example.com"
+
+		userId, err := service.SignIn(&test.claims)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+
+		if test.expected != "new" && userId != test.expected {
+			t.Errorf("%s: Expected user %q, got %q", test.name, test.expected, userId)
+		}
+
+		user, err := NewUserService(context.Background(), db).GetById(userId)
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got %v", test.name, err)
+		}
+		if !user.Verified() {
+			t.Errorf("%s: Expected a verified user", test.name)
+		}
+
+		// the next signin finds the identity, even with a changed email
+		test.claims.Email = "other@corp.com"
+		test.claims.EmailVerified = false
+
+		again, err := service.SignIn(&test.claims)
+		if err != nil || again != userId {
+			t.Errorf("%s: Expected user %q again, got %q, %v", test.name, userId, again, err)
+		}
+	}
+}
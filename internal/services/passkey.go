@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pengoe/internal/utils"
+	"pengoe/internal/webauthn"
+	"time"
+)
+
+/*
+passkeyChallengeLifetime is how long a registration or a signin with a
+passkey can take, a bit more than the timeout of the browser.
+*/
+const passkeyChallengeLifetime = webauthn.Timeout + time.Minute
+
+/*
+ErrInvalidPasskeyChallenge is returned for unknown, expired or used challenges.
+*/
+var ErrInvalidPasskeyChallenge = errors.New("Invalid or expired passkey challenge")
+
+/*
+Passkey is a WebAuthn credential of a user.
+*/
+type Passkey struct {
+	Id           string
+	CredentialId []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Name         string
+	LastUsedAt   time.Time
+	CreatedAt    time.Time
+	UserId       string
+}
+
+/*
+Credential returns the passkey for the verification of a signin.
+*/
+func (p *Passkey) Credential() *webauthn.Credential {
+	return &webauthn.Credential{
+		ID:        p.CredentialId,
+		PublicKey: p.PublicKey,
+		SignCount: p.SignCount,
+	}
+}
+
+type PasskeyServiceInterface interface {
+	New(userId, name string, credential *webauthn.Credential) (*Passkey, error)
+	GetByUserId(userId string) ([]*Passkey, error)
+	GetByCredentialId(credentialId []byte) (*Passkey, error)
+	Use(passkey *Passkey, signCount uint32) error
+	Delete(id, userId string) error
+	NewChallenge(userId string) ([]byte, error)
+	UseChallenge(challenge []byte) (string, error)
+}
+
+type passkeyService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewPasskeyService(ctx context.Context, db *sql.DB) PasskeyServiceInterface {
+	return &passkeyService{ctx: ctx, db: db}
+}
+
+const passkeyColumns = `id, credential_id, public_key, sign_count, name, last_used_at, created_at, user_id`
+
+/*
+New stores a registered passkey of the user.
+*/
+func (s *passkeyService) New(userId, name string, credential *webauthn.Credential) (*Passkey, error) {
+	defer observe(s.ctx, "passkey", "New")()
+
+	passkey := &Passkey{
+		Id:           utils.NewUUID("pky"),
+		CredentialId: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		Name:         name,
+		CreatedAt:    time.Now().UTC(),
+		UserId:       userId,
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO passkey (
+			id,
+			credential_id,
+			public_key,
+			sign_count,
+			name,
+			created_at,
+			user_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		passkey.Id,
+		webauthn.Encode(passkey.CredentialId),
+		webauthn.Encode(passkey.PublicKey),
+		int64(passkey.SignCount),
+		passkey.Name,
+		passkey.CreatedAt,
+		passkey.UserId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+/*
+GetByUserId returns the passkeys of the user, the newest first.
+*/
+func (s *passkeyService) GetByUserId(userId string) ([]*Passkey, error) {
+	defer observe(s.ctx, "passkey", "GetByUserId")()
+
+	rows, err := s.db.Query(
+		`SELECT `+passkeyColumns+` FROM passkey
+		WHERE user_id = ?
+		ORDER BY created_at DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*Passkey{}
+
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+/*
+GetByCredentialId returns the passkey the authenticator signed in with.
+*/
+func (s *passkeyService) GetByCredentialId(credentialId []byte) (*Passkey, error) {
+	defer observe(s.ctx, "passkey", "GetByCredentialId")()
+
+	row := s.db.QueryRow(
+		`SELECT `+passkeyColumns+` FROM passkey
+		WHERE credential_id = ?`,
+		webauthn.Encode(credentialId),
+	)
+
+	return scanPasskey(row)
+}
+
+/*
+Use stores the sign count of a signin. If another signin with the same
+count was first, the passkey may be cloned, and this one fails.
+*/
+func (s *passkeyService) Use(passkey *Passkey, signCount uint32) error {
+	defer observe(s.ctx, "passkey", "Use")()
+
+	now := time.Now().UTC()
+
+	result, err := s.db.Exec(
+		`UPDATE passkey
+		SET
+			sign_count = ?,
+			last_used_at = ?
+		WHERE id = ? AND sign_count = ?`,
+		int64(signCount),
+		now,
+		passkey.Id,
+		int64(passkey.SignCount),
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return webauthn.ErrSignCount
+	}
+
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = now
+
+	return nil
+}
+
+/*
+Delete deletes a passkey of the user.
+*/
+func (s *passkeyService) Delete(id, userId string) error {
+	defer observe(s.ctx, "passkey", "Delete")()
+
+	result, err := s.db.Exec(
+		`DELETE FROM passkey
+		WHERE id = ? AND user_id = ?`,
+		id,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+/*
+NewChallenge creates the challenge of a registration of the user,
+or of a signin without a user. Only its hash is stored.
+The expired challenges of everyone are deleted.
+*/
+func (s *passkeyService) NewChallenge(userId string) ([]byte, error) {
+	defer observe(s.ctx, "passkey", "NewChallenge")()
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	_, err = s.db.Exec(
+		`DELETE FROM passkey_challenge
+		WHERE expires_at <= ?`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user := sql.NullString{String: userId, Valid: userId != ""}
+
+	_, err = s.db.Exec(
+		`INSERT INTO passkey_challenge (
+			id,
+			expires_at,
+			created_at,
+			user_id
+		) VALUES (?, ?, ?, ?)`,
+		hashToken(webauthn.Encode(challenge)),
+		now.Add(passkeyChallengeLifetime),
+		now,
+		user,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+/*
+UseChallenge uses up a challenge, and returns the user of the registration,
+or "" for a signin.
+*/
+func (s *passkeyService) UseChallenge(challenge []byte) (string, error) {
+	defer observe(s.ctx, "passkey", "UseChallenge")()
+
+	id := hashToken(webauthn.Encode(challenge))
+
+	var userId sql.NullString
+	var expiresAtStr string
+
+	err := s.db.QueryRow(
+		`SELECT user_id, expires_at FROM passkey_challenge
+		WHERE id = ?`,
+		id,
+	).Scan(&userId, &expiresAtStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidPasskeyChallenge
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// only one request can use it
+	result, err := s.db.Exec(
+		`DELETE FROM passkey_challenge
+		WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	if affected == 0 {
+		return "", ErrInvalidPasskeyChallenge
+	}
+
+	expiresAt, err := utils.ConvertToTime(expiresAtStr)
+	if err != nil {
+		return "", err
+	}
+
+	if !time.Now().Before(expiresAt) {
+		return "", ErrInvalidPasskeyChallenge
+	}
+
+	return userId.String, nil
+}
+
+func scanPasskey(row scanner) (*Passkey, error) {
+	passkey := &Passkey{}
+
+	var credentialIdStr string
+	var publicKeyStr string
+	var signCount int
+	var lastUsedAtStr sql.NullString
+	var createdAtStr string
+
+	err := row.Scan(
+		&passkey.Id,
+		&credentialIdStr,
+		&publicKeyStr,
+		&signCount,
+		&passkey.Name,
+		&lastUsedAtStr,
+		&createdAtStr,
+		&passkey.UserId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey.CredentialId, err = webauthn.Decode(credentialIdStr)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey.PublicKey, err = webauthn.Decode(publicKeyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey.SignCount = uint32(signCount)
+
+	passkey.CreatedAt, err = utils.ConvertToTime(createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsedAtStr.Valid {
+		passkey.LastUsedAt, err = utils.ConvertToTime(lastUsedAtStr.String)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return passkey, nil
+}
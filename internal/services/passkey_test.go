@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestScanPasskey(t *testing.T) {
+	row := fakeRow{
+		"pky_1",
+		"AQID",
+		"pQECAyYgASFYIA",
+		7,
+		"Laptop",
+		nil,
+		"2024-01-01T10:00:00Z",
+		"usr_1",
+	}
+
+	passkey, err := scanPasskey(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !bytes.Equal(passkey.CredentialId, []byte{1, 2, 3}) {
+		t.Errorf("Expected credential id 010203, got %x", passkey.CredentialId)
+	}
+
+	credential := passkey.Credential()
+	if credential.SignCount != 7 || !bytes.Equal(credential.PublicKey, passkey.PublicKey) {
+		t.Errorf("Expected the credential of the passkey, got %+v", credential)
+	}
+
+	if !passkey.LastUsedAt.IsZero() {
+		t.Errorf("Expected a passkey not used yet, got %v", passkey.LastUsedAt)
+	}
+
+	row[5] = "2024-01-02T10:00:00Z"
+
+	passkey, err = scanPasskey(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	if !passkey.LastUsedAt.Equal(expected) {
+		t.Errorf("Expected last used %v, got %v", expected, passkey.LastUsedAt)
+	}
+}
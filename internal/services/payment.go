@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"pengoe/internal/utils"
+	"time"
+)
+
+type Payment struct {
+	Id          string
+	Factor      int
+	Extra       int
+	Paid        bool
+	PaidAt      *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	EventId     string
+	RecipientId string
+}
+
+/*
+paymentAmountQuery selects every payment with its amount: the recipient's
+factor weighted share of the event's income minus the reserved part, plus
+the extra. Use it as a common table expression.
+*/
+const paymentAmountQuery = `SELECT
+	payment.id AS payment_id,
+	payment.paid AS paid,
+	payment.paid_at AS paid_at,
+	payment.recipient_id AS recipient_id,
+	event.id AS event_id,
+	event.account_id AS account_id,
+	event.delivered_at AS delivered_at,
+	COALESCE(
+		(event.income - event.reserved) * payment.factor / NULLIF((
+			SELECT SUM(other.factor) FROM payment AS other WHERE other.event_id = payment.event_id
+		), 0),
+		0
+	) + payment.extra AS amount
+FROM payment
+INNER JOIN event ON payment.event_id = event.id`
+
+type PaymentService interface {
+	New(id string, factor, extra int, eventId, recipientId string) error
+	GetByAccountId(accountId string) ([]*Payment, error)
+}
+
+type paymentService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewPaymentService(ctx context.Context, db *sql.DB) PaymentService {
+	return &paymentService{ctx: ctx, db: db}
+}
+
+/*
+New is a function that adds an unpaid payment to the database.
+*/
+func (s *paymentService) New(id string, factor, extra int, eventId, recipientId string) error {
+	defer observe(s.ctx, "payment", "New")()
+
+	now := time.Now().UTC()
+
+	_, err := s.db.Exec(
+		`INSERT INTO payment (
+			id,
+			factor,
+			extra,
+			paid,
+			paid_at,
+			created_at,
+			updated_at,
+			event_id,
+			recipient_id
+		) VALUES (?, ?, ?, 0, NULL, ?, ?, ?, ?);`,
+		id,
+		factor,
+		extra,
+		now,
+		now,
+		eventId,
+		recipientId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+GetByAccountId is a function that returns all payments for the events of an account.
+*/
+func (s *paymentService) GetByAccountId(accountId string) ([]*Payment, error) {
+	defer observe(s.ctx, "payment", "GetByAccountId")()
+
+	rows, err := s.db.Query(
+		`SELECT
+			payment.id,
+			payment.factor,
+			payment.extra,
+			payment.paid,
+			payment.paid_at,
+			payment.created_at,
+			payment.updated_at,
+			payment.event_id,
+			payment.recipient_id
+		FROM payment
+		INNER JOIN event ON payment.event_id = event.id
+		WHERE event.account_id = ?;`,
+		accountId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	payments := []*Payment{}
+
+	for rows.Next() {
+		payment := &Payment{}
+
+		var paidAtStr sql.NullString
+		var createdAtStr string
+		var updatedAtStr string
+
+		err := rows.Scan(
+			&payment.Id,
+			&payment.Factor,
+			&payment.Extra,
+			&payment.Paid,
+			&paidAtStr,
+			&createdAtStr,
+			&updatedAtStr,
+			&payment.EventId,
+			&payment.RecipientId,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if paidAtStr.Valid {
+			paidAt, err := utils.ConvertToTime(paidAtStr.String)
+			if err != nil {
+				return nil, err
+			}
+			payment.PaidAt = &paidAt
+		}
+
+		createdAt, err := utils.ConvertToTime(createdAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		updatedAt, err := utils.ConvertToTime(updatedAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		payment.CreatedAt = createdAt
+		payment.UpdatedAt = updatedAt
+
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"pengoe/internal/utils"
+	"time"
+)
+
+type Recipient struct {
+	Id        string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	AccessId  string
+}
+
+type RecipientService interface {
+	New(id, name, accessId string) error
+	GetByAccountId(accountId string) ([]*Recipient, error)
+}
+
+type recipientService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewRecipientService(ctx context.Context, db *sql.DB) RecipientService {
+	return &recipientService{ctx: ctx, db: db}
+}
+
+/*
+New is a function that adds a recipient to the database.
+*/
+func (s *recipientService) New(id, name, accessId string) error {
+	defer observe(s.ctx, "recipient", "New")()
+
+	now := time.Now().UTC()
+
+	_, err := s.db.Exec(
+		`INSERT INTO recipient (
+			id,
+			name,
+			created_at,
+			updated_at,
+			access_id
+		) VALUES (?, ?, ?, ?, ?);`,
+		id,
+		name,
+		now,
+		now,
+		accessId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+GetByAccountId is a function that returns all recipients for an account.
+*/
+func (s *recipientService) GetByAccountId(accountId string) ([]*Recipient, error) {
+	defer observe(s.ctx, "recipient", "GetByAccountId")()
+
+	rows, err := s.db.Query(
+		`SELECT
+			recipient.id,
+			recipient.name,
+			recipient.created_at,
+			recipient.updated_at,
+			recipient.access_id
+		FROM recipient
+		INNER JOIN access ON recipient.access_id = access.id
+		WHERE access.account_id = ?;`,
+		accountId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := []*Recipient{}
+
+	for rows.Next() {
+		recipient := &Recipient{}
+
+		var createdAtStr string
+		var updatedAtStr string
+
+		err := rows.Scan(
+			&recipient.Id,
+			&recipient.Name,
+			&createdAtStr,
+			&updatedAtStr,
+			&recipient.AccessId,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt, err := utils.ConvertToTime(createdAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		updatedAt, err := utils.ConvertToTime(updatedAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		recipient.CreatedAt = createdAt
+		recipient.UpdatedAt = updatedAt
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Granularity string
+
+const (
+	Day     Granularity = "day"
+	Week    Granularity = "week"
+	Month   Granularity = "month"
+	Quarter Granularity = "quarter"
+	Year    Granularity = "year"
+)
+
+/*
+ParseGranularity is a function that validates a granularity from user input.
+*/
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case Day, Week, Month, Quarter, Year:
+		return g, nil
+	}
+	return "", fmt.Errorf("Unknown granularity %q", s)
+}
+
+/*
+ReportFilter selects the accounts and the time range of a report.
+Accounts are limited to the ones of UserId and/or to AccountIds when set.
+From is inclusive, To is exclusive, zero values are unbounded.
+*/
+type ReportFilter struct {
+	UserId      string
+	AccountIds  []string
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+}
+
+/*
+PeriodTotals are the totals of an account in a period.
+Income and Reserved are counted by delivery, Paid by payment date,
+Outstanding is the unpaid share of the events delivered in the period.
+Balance is the running balance (income minus paid) at the end of the period,
+including everything before From. The Previous fields hold the totals
+of the preceding calendar period (zero if it had no activity),
+for period-over-period comparison.
+*/
+type PeriodTotals struct {
+	AccountId        string
+	Period           string
+	Income           int
+	Reserved         int
+	Paid             int
+	Outstanding      int
+	Balance          int
+	PreviousIncome   int
+	PreviousReserved int
+	PreviousPaid     int
+}
+
+/*
+IncomeChange is the relative change of the income to the previous period,
+in percent. It is zero when there was no previous income.
+*/
+func (t *PeriodTotals) IncomeChange() float64 {
+	if t.PreviousIncome == 0 {
+		return 0
+	}
+	return float64(t.Income-t.PreviousIncome) / float64(t.PreviousIncome) * 100
+}
+
+/*
+AccountTotals are the totals of an account in the filtered range.
+OpeningBalance is the balance before From, ClosingBalance before To.
+*/
+type AccountTotals struct {
+	AccountId      string
+	Income         int
+	Reserved       int
+	Available      int
+	Paid           int
+	Outstanding    int
+	OpeningBalance int
+	ClosingBalance int
+}
+
+/*
+RecipientTotals are the payments of a recipient
+for the events delivered in the filtered range.
+*/
+type RecipientTotals struct {
+	AccountId   string
+	RecipientId string
+	Name        string
+	Share       int
+	Paid        int
+	Outstanding int
+}
+
+type ReportService interface {
+	ByPeriod(filter ReportFilter) ([]*PeriodTotals, error)
+	ByAccount(filter ReportFilter) ([]*AccountTotals, error)
+	ByRecipient(filter ReportFilter) ([]*RecipientTotals, error)
+}
+
+type reportService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewReportService(ctx context.Context, db *sql.DB) ReportService {
+	return &reportService{ctx: ctx, db: db}
+}
+
+/*
+flowsQuery lists every money movement of the accounts: event income and
+reserved by delivery date, paid payments by payment date and unpaid
+payments by the delivery date of their event.
+*/
+const flowsQuery = `SELECT
+		account_id,
+		delivered_at AS at,
+		income,
+		reserved,
+		0 AS paid,
+		0 AS outstanding
+	FROM event
+	UNION ALL
+	SELECT account_id, paid_at, 0, 0, amount, 0 FROM amounts WHERE paid = 1
+	UNION ALL
+	SELECT account_id, delivered_at, 0, 0, 0, amount FROM amounts WHERE paid = 0`
+
+/*
+ByPeriod is a function that aggregates the flows of the accounts
+by account and period, ordered by account and period.
+*/
+func (s *reportService) ByPeriod(filter ReportFilter) ([]*PeriodTotals, error) {
+	defer observe(s.ctx, "report", "ByPeriod")()
+
+	period, err := periodExpression(filter.Granularity, "at")
+	if err != nil {
+		return nil, err
+	}
+
+	// the key of the period before, from the day before the period starts
+	start, err := periodStartExpression(filter.Granularity, "at")
+	if err != nil {
+		return nil, err
+	}
+	previous, err := periodExpression(filter.Granularity, fmt.Sprintf("date(%s, '-1 day')", start))
+	if err != nil {
+		return nil, err
+	}
+
+	scope, args := reportScope(filter, "flows.account_id")
+
+	// flows after To can not change the earlier periods
+	conditions := []string{scope}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "flows.at < ?")
+		args = append(args, filter.To)
+	}
+
+	// From is applied after the window functions,
+	// so the balance contains everything before it
+	outer := []string{}
+	if !filter.From.IsZero() {
+		from, _ := periodExpression(filter.Granularity, "x")
+		outer = append(outer, fmt.Sprintf("period >= (SELECT %s FROM (SELECT ? AS x))", from))
+		args = append(args, filter.From)
+	}
+
+	rows, err := s.db.Query(
+		`WITH amounts AS (`+paymentAmountQuery+`),
+		flows AS (`+flowsQuery+`),
+		totals AS (
+			SELECT
+				account_id,
+				`+period+` AS period,
+				MIN(`+previous+`) AS previous_period,
+				SUM(income) AS income,
+				SUM(reserved) AS reserved,
+				SUM(paid) AS paid,
+				SUM(outstanding) AS outstanding
+			FROM flows
+			`+where(conditions)+`
+			GROUP BY account_id, period
+		),
+		windowed AS (
+			SELECT
+				totals.account_id,
+				totals.period,
+				totals.income,
+				totals.reserved,
+				totals.paid,
+				totals.outstanding,
+				SUM(totals.income - totals.paid) OVER (
+					PARTITION BY totals.account_id ORDER BY totals.period ROWS UNBOUNDED PRECEDING
+				) AS balance,
+				COALESCE(previous.income, 0) AS previous_income,
+				COALESCE(previous.reserved, 0) AS previous_reserved,
+				COALESCE(previous.paid, 0) AS previous_paid
+			FROM totals
+			LEFT JOIN totals AS previous
+				ON previous.account_id = totals.account_id
+				AND previous.period = totals.previous_period
+		)
+		SELECT
+			account_id,
+			period,
+			income,
+			reserved,
+			paid,
+			outstanding,
+			balance,
+			previous_income,
+			previous_reserved,
+			previous_paid
+		FROM windowed
+		`+where(outer)+`
+		ORDER BY account_id, period;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*PeriodTotals{}
+
+	for rows.Next() {
+		totals := &PeriodTotals{}
+
+		err := rows.Scan(
+			&totals.AccountId,
+			&totals.Period,
+			&totals.Income,
+			&totals.Reserved,
+			&totals.Paid,
+			&totals.Outstanding,
+			&totals.Balance,
+			&totals.PreviousIncome,
+			&totals.PreviousReserved,
+			&totals.PreviousPaid,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, totals)
+	}
+
+	return result, nil
+}
+
+/*
+ByAccount is a function that aggregates the flows of the accounts by account.
+Accounts without any flow are included with zero totals.
+*/
+func (s *reportService) ByAccount(filter ReportFilter) ([]*AccountTotals, error) {
+	defer observe(s.ctx, "report", "ByAccount")()
+
+	scope, args := reportScope(filter, "account.id")
+
+	// the flows are compared to the range boundaries, unset boundaries
+	// are replaced with values that include everything
+	from := any(nil)
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	to := any(nil)
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+
+	rows, err := s.db.Query(
+		`WITH amounts AS (`+paymentAmountQuery+`),
+		flows AS (`+flowsQuery+`),
+		bounds AS (
+			SELECT
+				flows.*,
+				(? IS NULL OR at >= ?) AS after_from,
+				(? IS NULL OR at < ?) AS before_to
+			FROM flows
+		)
+		SELECT
+			account.id,
+			COALESCE(SUM(CASE WHEN after_from AND before_to THEN income END), 0),
+			COALESCE(SUM(CASE WHEN after_from AND before_to THEN reserved END), 0),
+			COALESCE(SUM(CASE WHEN after_from AND before_to THEN paid END), 0),
+			COALESCE(SUM(CASE WHEN after_from AND before_to THEN outstanding END), 0),
+			COALESCE(SUM(CASE WHEN NOT after_from THEN income - paid END), 0),
+			COALESCE(SUM(CASE WHEN before_to THEN income - paid END), 0)
+		FROM account
+		LEFT JOIN bounds ON bounds.account_id = account.id
+		WHERE `+scope+`
+		GROUP BY account.id
+		ORDER BY account.id;`,
+		append([]any{from, from, to, to}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*AccountTotals{}
+
+	for rows.Next() {
+		totals := &AccountTotals{}
+
+		err := rows.Scan(
+			&totals.AccountId,
+			&totals.Income,
+			&totals.Reserved,
+			&totals.Paid,
+			&totals.Outstanding,
+			&totals.OpeningBalance,
+			&totals.ClosingBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		totals.Available = totals.Income - totals.Reserved
+
+		result = append(result, totals)
+	}
+
+	return result, nil
+}
+
+/*
+ByRecipient is a function that aggregates the payments of the events
+delivered in the range by account and recipient.
+*/
+func (s *reportService) ByRecipient(filter ReportFilter) ([]*RecipientTotals, error) {
+	defer observe(s.ctx, "report", "ByRecipient")()
+
+	scope, args := reportScope(filter, "amounts.account_id")
+
+	conditions := []string{scope}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "amounts.delivered_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "amounts.delivered_at < ?")
+		args = append(args, filter.To)
+	}
+
+	rows, err := s.db.Query(
+		`WITH amounts AS (`+paymentAmountQuery+`)
+		SELECT
+			amounts.account_id,
+			recipient.id,
+			recipient.name,
+			SUM(amounts.amount),
+			SUM(CASE WHEN amounts.paid = 1 THEN amounts.amount ELSE 0 END)
+		FROM amounts
+		INNER JOIN recipient ON amounts.recipient_id = recipient.id
+		`+where(conditions)+`
+		GROUP BY amounts.account_id, recipient.id, recipient.name
+		ORDER BY amounts.account_id, recipient.name;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*RecipientTotals{}
+
+	for rows.Next() {
+		totals := &RecipientTotals{}
+
+		err := rows.Scan(
+			&totals.AccountId,
+			&totals.RecipientId,
+			&totals.Name,
+			&totals.Share,
+			&totals.Paid,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		totals.Outstanding = totals.Share - totals.Paid
+
+		result = append(result, totals)
+	}
+
+	return result, nil
+}
+
+/*
+periodExpression returns the SQL expression that maps a date to its period key.
+Keys sort in time order: 2024-01-31, 2024-W05, 2024-01, 2024-Q1, 2024.
+*/
+func periodExpression(granularity Granularity, column string) (string, error) {
+	switch granularity {
+	case Day:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s)", column), nil
+	case Week:
+		return fmt.Sprintf("strftime('%%Y-W%%W', %s)", column), nil
+	case Month:
+		return fmt.Sprintf("strftime('%%Y-%%m', %s)", column), nil
+	case Quarter:
+		return fmt.Sprintf(
+			"(strftime('%%Y', %s) || '-Q' || ((CAST(strftime('%%m', %s) AS INTEGER) + 2) / 3))",
+			column,
+			column,
+		), nil
+	case Year:
+		return fmt.Sprintf("strftime('%%Y', %s)", column), nil
+	}
+	return "", errors.New("Unknown granularity")
+}
+
+/*
+periodStartExpression returns the SQL expression of the first day of the
+period of a date, like the periods of periodExpression.
+Weeks start on monday, or at new year (week 00).
+*/
+func periodStartExpression(granularity Granularity, column string) (string, error) {
+	switch granularity {
+	case Day:
+		return fmt.Sprintf("date(%s)", column), nil
+	case Week:
+		return fmt.Sprintf(
+			"max(date(%s, 'weekday 0', '-6 days'), date(%s, 'start of year'))",
+			column,
+			column,
+		), nil
+	case Month:
+		return fmt.Sprintf("date(%s, 'start of month')", column), nil
+	case Quarter:
+		return fmt.Sprintf(
+			"date(%s, 'start of month', '-' || ((CAST(strftime('%%m', %s) AS INTEGER) - 1) %% 3) || ' months')",
+			column,
+			column,
+		), nil
+	case Year:
+		return fmt.Sprintf("date(%s, 'start of year')", column), nil
+	}
+	return "", errors.New("Unknown granularity")
+}
+
+/*
+PeriodKeys is a function that lists the period keys between from (inclusive)
+and to (exclusive), in the same format as the reports, to fill periods
+without activity.
+*/
+func PeriodKeys(granularity Granularity, from, to time.Time) []string {
+	keys := []string{}
+
+	from = from.UTC()
+	to = to.UTC()
+
+	// start at the beginning of the first period
+	switch granularity {
+	case Day:
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	case Week:
+		monday := (int(from.Weekday()) + 6) % 7
+		from = time.Date(from.Year(), from.Month(), from.Day()-monday, 0, 0, 0, 0, time.UTC)
+	case Month:
+		from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case Quarter:
+		from = time.Date(from.Year(), from.Month()-(from.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case Year:
+		from = time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return keys
+	}
+
+	for date := from; date.Before(to); {
+		switch granularity {
+		case Day:
+			keys = append(keys, date.Format("2006-01-02"))
+			date = date.AddDate(0, 0, 1)
+		case Week:
+			// same as SQLite's %W: weeks start on monday,
+			// days before the first monday are in week 00
+			monday := (int(date.Weekday()) + 6) % 7
+			week := (date.YearDay() - 1 + 7 - monday) / 7
+			key := fmt.Sprintf("%d-W%02d", date.Year(), week)
+			if len(keys) == 0 || keys[len(keys)-1] != key {
+				keys = append(keys, key)
+			}
+			// the next week starts on monday,
+			// or at new year, which starts week 00 or 01
+			next := date.AddDate(0, 0, 7-monday)
+			if next.Year() != date.Year() {
+				next = time.Date(next.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+			}
+			date = next
+		case Month:
+			keys = append(keys, date.Format("2006-01"))
+			date = date.AddDate(0, 1, 0)
+		case Quarter:
+			keys = append(keys, fmt.Sprintf("%d-Q%d", date.Year(), (int(date.Month())+2)/3))
+			date = date.AddDate(0, 3, 0)
+		case Year:
+			keys = append(keys, date.Format("2006"))
+			date = date.AddDate(1, 0, 0)
+		}
+	}
+
+	return keys
+}
+
+/*
+reportScope returns the condition limiting the account column to the
+accounts of the filter, with its arguments.
+*/
+func reportScope(filter ReportFilter, column string) (string, []any) {
+	conditions := []string{"1 = 1"}
+	args := []any{}
+
+	if filter.UserId != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"%s IN (SELECT account_id FROM access WHERE user_id = ?)",
+			column,
+		))
+		args = append(args, filter.UserId)
+	}
+
+	if filter.AccountIds != nil {
+		placeholders := []string{}
+		for _, id := range filter.AccountIds {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		if len(placeholders) == 0 {
+			conditions = append(conditions, "1 = 0")
+		} else {
+			conditions = append(conditions, fmt.Sprintf(
+				"%s IN (%s)",
+				column,
+				strings.Join(placeholders, ", "),
+			))
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPeriodKeys(t *testing.T) {
+	tests := []struct {
+		granularity Granularity
+		from        time.Time
+		to          time.Time
+		expected    []string
+	}{
+		{
+			Month,
+			time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2023-11", "2023-12", "2024-01"},
+		},
+		{
+			Quarter,
+			time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2023-Q4", "2024-Q1"},
+		},
+		{
+			// matches SQLite's strftime('%Y-W%W')
+			Week,
+			time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC),
+			[]string{"2024-W52", "2024-W53", "2025-W00", "2025-W01"},
+		},
+		{
+			Day,
+			time.Date(2024, 2, 28, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2024-02-28", "2024-02-29"},
+		},
+		{
+			Year,
+			time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2022", "2023"},
+		},
+	}
+
+	for _, test := range tests {
+		result := PeriodKeys(test.granularity, test.from, test.to)
+
+		if len(result) != len(test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, result)
+			continue
+		}
+
+		for i := range result {
+			if result[i] != test.expected[i] {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+				break
+			}
+		}
+	}
+}
+
+func TestReportScope(t *testing.T) {
+	scope, args := reportScope(ReportFilter{UserId: "usr_1", AccountIds: []string{"acc_1", "acc_2"}}, "event.account_id")
+
+	expected := "1 = 1 AND event.account_id IN (SELECT account_id FROM access WHERE user_id = ?) AND event.account_id IN (?, ?)"
+	if scope != expected {
+		t.Errorf("Expected %s, got %s", expected, scope)
+	}
+
+	if len(args) != 3 {
+		t.Errorf("Expected 3 args, got %d", len(args))
+	}
+
+	scope, _ = reportScope(ReportFilter{AccountIds: []string{}}, "event.account_id")
+
+	expected = "1 = 1 AND 1 = 0"
+	if scope != expected {
+		t.Errorf("Expected %s, got %s", expected, scope)
+	}
+}
+
+/*
+newTestEvent adds an event to the account, creating the account if needed.
+*/
+func newTestEvent(t *testing.T, db *sql.DB, accountId string, income int, deliveredAt time.Time) {
+	now := time.Now().UTC()
+
+	_, err := db.Exec(
+		`INSERT OR IGNORE INTO account (id, name, currency, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		accountId, accountId, "EUR", now, now,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO event (id, name, income, reserved, delivered_at, created_at, updated_at, account_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		fmt.Sprintf("evt_%s_%d", accountId, deliveredAt.Unix()), "Gig", income, 0, deliveredAt, now, now, accountId,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestByPeriodPreviousAfterGap(t *testing.T) {
+	db := newTestDB(t)
+
+	newTestEvent(t, db, "acc_1", 100, time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC))
+	newTestEvent(t, db, "acc_1", 300, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+	newTestEvent(t, db, "acc_1", 200, time.Date(2024, 4, 10, 12, 0, 0, 0, time.UTC))
+
+	reports := NewReportService(context.Background(), db)
+
+	result, err := reports.ByPeriod(ReportFilter{
+		Granularity: Month,
+		From:        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 periods, got %d", len(result))
+	}
+
+	// february had no activity, january is not the previous period of march
+	march := result[0]
+	if march.Period != "2024-03" || march.PreviousIncome != 0 || march.Balance != 400 {
+		t.Errorf("Expected 2024-03 with previous income 0 and balance 400, got %+v", march)
+	}
+
+	april := result[1]
+	if april.Period != "2024-04" || april.PreviousIncome != 300 || april.Balance != 600 {
+		t.Errorf("Expected 2024-04 with previous income 300 and balance 600, got %+v", april)
+	}
+}
+
+func TestPreviousPeriodExpression(t *testing.T) {
+	db := newTestDB(t)
+
+	from := time.Date(2023, 12, 20, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	for _, granularity := range []Granularity{Day, Week, Month, Quarter, Year} {
+		period, _ := periodExpression(granularity, "x")
+		start, _ := periodStartExpression(granularity, "x")
+		previous, _ := periodExpression(granularity, fmt.Sprintf("date(%s, '-1 day')", start))
+
+		// the period before is the one before in the list of PeriodKeys
+		keys := PeriodKeys(granularity, from.AddDate(-1, 0, 0), to)
+		before := map[string]string{}
+		for i := 1; i < len(keys); i++ {
+			before[keys[i]] = keys[i-1]
+		}
+
+		for date := from; date.Before(to); date = date.AddDate(0, 0, 1) {
+			var key, previousKey string
+			err := db.QueryRow(
+				fmt.Sprintf("SELECT %s, %s FROM (SELECT ? AS x)", period, previous),
+				date.Add(15*time.Hour),
+			).Scan(&key, &previousKey)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if previousKey != before[key] {
+				t.Errorf("%s %s: Expected %q before %q, got %q", granularity, date.Format("2006-01-02"), before[key], key, previousKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"pengoe/internal/utils"
+	"time"
+)
+
+/*
+resetTokenLifetime is how long a password reset link works.
+*/
+const resetTokenLifetime = time.Hour
+
+/*
+ErrInvalidResetToken is returned for unknown, used or expired reset tokens.
+*/
+var ErrInvalidResetToken = errors.New("Invalid or expired reset token")
+
+type PasswordResetServiceInterface interface {
+	New(userId string) (string, error)
+	Check(token string) (string, error)
+	Reset(token, password string) (string, error)
+}
+
+type passwordResetService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewPasswordResetService(ctx context.Context, db *sql.DB) PasswordResetServiceInterface {
+	return &passwordResetService{ctx: ctx, db: db}
+}
+
+/*
+New creates a reset token for the user, and returns it for the link.
+Only its hash is stored, so the database does not leak working links.
+*/
+func (s *passwordResetService) New(userId string) (string, error) {
+	defer observe(s.ctx, "password_reset", "New")()
+
+	token, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	_, err = s.db.Exec(
+		`INSERT INTO password_reset (
+			id,
+			token_hash,
+			expires_at,
+			created_at,
+			user_id
+		) VALUES (?, ?, ?, ?, ?)`,
+		utils.NewUUID("rst"),
+		hashToken(token),
+		now.Add(resetTokenLifetime),
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+/*
+Check returns the user of the reset token, if the token can be used.
+*/
+func (s *passwordResetService) Check(token string) (string, error) {
+	defer observe(s.ctx, "password_reset", "Check")()
+
+	var userId string
+
+	err := s.db.QueryRow(
+		`SELECT user_id FROM password_reset
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashToken(token),
+		time.Now().UTC(),
+	).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userId, nil
+}
+
+/*
+Reset sets the password of the user of the reset token, and uses up every
+reset token of the user. It returns the user id. The token is marked used
+in the same transaction, so it works only once.
+*/
+func (s *passwordResetService) Reset(token, password string) (string, error) {
+	defer observe(s.ctx, "password_reset", "Reset")()
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userId string
+
+	err = tx.QueryRow(
+		`SELECT user_id FROM password_reset
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashToken(token),
+		now,
+	).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the older links stop working too
+	result, err := tx.Exec(
+		`UPDATE password_reset
+		SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL`,
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	// another request used it in the meantime
+	if affected == 0 {
+		return "", ErrInvalidResetToken
+	}
+
+	_, err = tx.Exec(
+		`UPDATE user
+		SET
+			password = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		hashedPassword,
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return userId, tx.Commit()
+}
+
+/*
+hashToken returns the hex SHA-256 of a reset or verification token.
+The tokens are random, so a fast hash is enough, unlike for the passwords.
+*/
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHashToken(t *testing.T) {
+	hash := hashToken("token")
+
+	if len(hash) != 64 {
+		t.Errorf("Expected a hex SHA-256, got %q", hash)
+	}
+
+	if hash != hashToken("token") {
+		t.Errorf("Expected the same hash for the same token")
+	}
+
+	if hash == hashToken("other") || hash == "token" {
+		t.Errorf("Expected different hashes for different tokens")
+	}
+}
+
+func TestPasswordResetSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	resets := NewPasswordResetService(context.Background(), db)
+
+	older, err := resets.New("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token, err := resets.New("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	userId, err := resets.Check(token)
+	if err != nil || userId != "usr_1" {
+		t.Fatalf("Expected usr_1, got %q, %v", userId, err)
+	}
+
+	userId, err = resets.Reset(token, "new password")
+	if err != nil || userId != "usr_1" {
+		t.Fatalf("Expected usr_1, got %q, %v", userId, err)
+	}
+
+	// the new password works
+	users := NewUserService(context.Background(), db)
+	userId, err = users.Signin("usr_1", "new password")
+	if err != nil || userId != "usr_1" {
+		t.Errorf("Expected signin with the new password, got %q, %v", userId, err)
+	}
+
+	// the token and the older ones are used up
+	for _, used := range []string{token, older} {
+		_, err = resets.Check(used)
+		if !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("Expected ErrInvalidResetToken from Check, got %v", err)
+		}
+
+		_, err = resets.Reset(used, "other password")
+		if !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("Expected ErrInvalidResetToken from Reset, got %v", err)
+		}
+	}
+
+	_, err = resets.Reset("unknown", "other password")
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Expected ErrInvalidResetToken for an unknown token, got %v", err)
+	}
+}
+
+func TestPasswordResetExpired(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	resets := NewPasswordResetService(context.Background(), db)
+
+	token, err := resets.New("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = db.Exec(
+		`UPDATE password_reset SET expires_at = ?`,
+		time.Now().UTC().Add(-time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = resets.Check(token)
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Expected ErrInvalidResetToken from Check, got %v", err)
+	}
+
+	_, err = resets.Reset(token, "new password")
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Expected ErrInvalidResetToken from Reset, got %v", err)
+	}
+}
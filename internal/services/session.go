@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"pengoe/internal/utils"
@@ -13,32 +14,98 @@ type Session struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	UserId     string
+	UserAgent  string
+	IP         string
+	LastSeenAt time.Time
+	Remember   bool
 }
 
+/*
+SessionConfig is how long the sessions last. A session is extended on every
+use by Idle, or by Remember if the user asked to be remembered, but never
+beyond Absolute after the signin.
+*/
+type SessionConfig struct {
+	Idle     time.Duration
+	Remember time.Duration
+	Absolute time.Duration
+}
+
+/*
+SessionLifetime is the lifetime of the new and the extended sessions,
+the server sets it from the configuration.
+*/
+var SessionLifetime = SessionConfig{
+	Idle:     24 * time.Hour,
+	Remember: 30 * 24 * time.Hour,
+	Absolute: 90 * 24 * time.Hour,
+}
+
+/*
+ValidUntil returns the end of a session used now, signed in at createdAt.
+*/
+func (c SessionConfig) ValidUntil(createdAt, now time.Time, remember bool) time.Time {
+	window := c.Idle
+	if remember {
+		window = c.Remember
+	}
+
+	validUntil := now.Add(window)
+
+	max := createdAt.Add(c.Absolute)
+	if validUntil.After(max) {
+		validUntil = max
+	}
+
+	return validUntil.UTC()
+}
+
+/*
+maxUserAgentLength is the longest user agent stored, the rest is cut.
+*/
+const maxUserAgentLength = 512
+
 type SessionServiceInterface interface {
-	New(id, userId string) (*Session, error)
+	New(id, userId, userAgent, ip string, remember bool) (*Session, error)
 	GetActives() ([]*Session, error)
+	CountActives() (int, error)
 	GetById(id string) (*Session, error)
-	GetByUserID(usedId string) (*Session, error)
+	GetByUserID(usedId string) ([]*Session, error)
+	Touch(session *Session, ip string) error
 	Delete(id string) error
+	DeleteByUserID(userId string) ([]string, error)
 	CheckFromCookie(*http.Request) (*Session, error)
 }
 
 type sessionService struct {
-	db *sql.DB
+	ctx context.Context
+	db  *sql.DB
 }
 
-func NewSessionService(db *sql.DB) SessionServiceInterface {
-	return &sessionService{db: db}
+func NewSessionService(ctx context.Context, db *sql.DB) SessionServiceInterface {
+	return &sessionService{ctx: ctx, db: db}
 }
 
 /*
-New creates a new session for the given user in the database.
+New creates a new session for the given user in the database,
+with the user agent and the IP address of the device signing in.
 */
-func (s *sessionService) New(id, userId string) (*Session, error) {
+func (s *sessionService) New(id, userId, userAgent, ip string, remember bool) (*Session, error) {
+	defer observe(s.ctx, "session", "New")()
+
 	now := time.Now().UTC()
 
-	validUntil := now.Add(time.Hour * 24 * 7)
+	validUntil := SessionLifetime.ValidUntil(now, now, remember)
+
+	// the driver does not take bools
+	rememberInt := 0
+	if remember {
+		rememberInt = 1
+	}
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
 
 	_, err := s.db.Exec(
 		`INSERT INTO session (
@@ -46,13 +113,21 @@ func (s *sessionService) New(id, userId string) (*Session, error) {
 			valid_until,
 			created_at,
 			updated_at,
-			user_id
-		) VALUES (?, ?, ?, ?, ?)`,
+			user_id,
+			user_agent,
+			ip,
+			last_seen_at,
+			remember
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id,
 		validUntil,
 		now,
 		now,
 		userId,
+		userAgent,
+		ip,
+		now,
+		rememberInt,
 	)
 
 	if err != nil {
@@ -65,6 +140,10 @@ func (s *sessionService) New(id, userId string) (*Session, error) {
 		CreatedAt:  now,
 		UpdatedAt:  now,
 		UserId:     userId,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+		Remember:   remember,
 	}
 
 	return newSession, nil
@@ -74,15 +153,21 @@ func (s *sessionService) New(id, userId string) (*Session, error) {
 GetActiveSessions returns all active sessions from the database.
 */
 func (s *sessionService) GetActives() ([]*Session, error) {
+	defer observe(s.ctx, "session", "GetActives")()
+
 	rows, err := s.db.Query(
 		`SELECT
-      id,
-      valid_until,
-      created_at,
-      updated_at,
-			user_id
-    FROM session
-    WHERE valid_until > ?`,
+			id,
+			valid_until,
+			created_at,
+			updated_at,
+			user_id,
+			user_agent,
+			ip,
+			last_seen_at,
+			remember
+		FROM session
+		WHERE valid_until > ?`,
 		time.Now().UTC(),
 	)
 
@@ -90,128 +175,222 @@ func (s *sessionService) GetActives() ([]*Session, error) {
 		return nil, err
 	}
 
-	sessions := []*Session{}
-
-	for rows.Next() {
-		session := &Session{}
-		var validUntilStr string
-		var createdAtStr string
-		var updatedAtStr string
-
-		err := rows.Scan(
-			&session.Id,
-			&validUntilStr,
-			&createdAtStr,
-			&updatedAtStr,
-			&session.UserId,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		validUntil, err := utils.ConvertToTime(validUntilStr)
-		if err != nil {
-			return nil, err
-		}
-
-		createdAt, err := utils.ConvertToTime(createdAtStr)
-		if err != nil {
-			return nil, err
-		}
-
-		updatedAt, err := utils.ConvertToTime(updatedAtStr)
-		if err != nil {
-			return nil, err
-		}
+	return scanSessions(rows)
+}
 
-		session.ValidUntil = validUntil
-		session.CreatedAt = createdAt
-		session.UpdatedAt = updatedAt
+/*
+CountActives returns the number of sessions that have not expired.
+*/
+func (s *sessionService) CountActives() (int, error) {
+	defer observe(s.ctx, "session", "CountActives")()
 
-		sessions = append(sessions, session)
+	var count int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM session
+		WHERE valid_until > ?`,
+		time.Now().UTC(),
+	).Scan(&count)
+	if err != nil {
+		return 0, err
 	}
 
-	return sessions, nil
+	return count, nil
 }
 
 /*
 GetById returns the session with the given sessionID from the database.
 */
 func (s *sessionService) GetById(id string) (*Session, error) {
+	defer observe(s.ctx, "session", "GetById")()
+
 	row := s.db.QueryRow(
 		`SELECT
 			id,
 			valid_until,
 			created_at,
 			updated_at,
-			user_id
+			user_id,
+			user_agent,
+			ip,
+			last_seen_at,
+			remember
 		FROM session
 		WHERE id = ?`,
 		id,
 	)
 
-	session := &Session{}
+	return scanSession(row)
+}
 
-	var validUntilStr string
-	var createdAtStr string
-	var updatedAtStr string
+/*
+GetByUserID returns the active sessions of the user from the database,
+the most recently used first.
+*/
+func (s *sessionService) GetByUserID(userId string) ([]*Session, error) {
+	defer observe(s.ctx, "session", "GetByUserID")()
 
-	err := row.Scan(
-		&session.Id,
-		&validUntilStr,
-		&createdAtStr,
-		&updatedAtStr,
-		&session.UserId,
+	rows, err := s.db.Query(
+		`SELECT
+			id,
+			valid_until,
+			created_at,
+			updated_at,
+			user_id,
+			user_agent,
+			ip,
+			last_seen_at,
+			remember
+		FROM session
+		WHERE user_id = ? AND valid_until > ?
+		ORDER BY last_seen_at DESC, id`,
+		userId,
+		time.Now().UTC(),
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	validUntil, err := utils.ConvertToTime(validUntilStr)
+	return scanSessions(rows)
+}
+
+/*
+Touch records that the session was used now, from the given IP address,
+and extends it. The session is updated too.
+*/
+func (s *sessionService) Touch(session *Session, ip string) error {
+	defer observe(s.ctx, "session", "Touch")()
+
+	now := time.Now().UTC()
+	validUntil := SessionLifetime.ValidUntil(session.CreatedAt, now, session.Remember)
+
+	_, err := s.db.Exec(
+		`UPDATE session
+		SET
+			valid_until = ?,
+			last_seen_at = ?,
+			ip = ?
+		WHERE id = ?`,
+		validUntil,
+		now,
+		ip,
+		session.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	session.ValidUntil = validUntil
+	session.LastSeenAt = now
+	session.IP = ip
+
+	return nil
+}
+
+/*
+Delete deletes the session with the given sessionID from the database.
+*/
+func (s *sessionService) Delete(id string) error {
+	defer observe(s.ctx, "session", "Delete")()
+
+	_, err := s.db.Exec(
+		`DELETE FROM session
+		WHERE id = ?`,
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+DeleteByUserID deletes every session of the user from the database,
+and returns their ids, so their tokens can be deleted too.
+*/
+func (s *sessionService) DeleteByUserID(userId string) ([]string, error) {
+	defer observe(s.ctx, "session", "DeleteByUserID")()
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	createdAt, err := utils.ConvertToTime(createdAtStr)
+	rows, err := tx.Query(
+		`SELECT id FROM session
+		WHERE user_id = ?`,
+		userId,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	updatedAt, err := utils.ConvertToTime(updatedAtStr)
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM session
+		WHERE user_id = ?`,
+		userId,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	session.ValidUntil = validUntil
-	session.CreatedAt = createdAt
-	session.UpdatedAt = updatedAt
+	return ids, tx.Commit()
+}
+
+/*
+CheckCookie returns the session from the cookie in the request.
+*/
+func (s *sessionService) CheckFromCookie(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.GetById(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
 
 	return session, nil
 }
 
 /*
-GetByUserID returns the session with the given userID from the database.
+scanner is a row or the current row of rows.
 */
-func (s *sessionService) GetByUserID(userId string) (*Session, error) {
-	row := s.db.QueryRow(
-		`SELECT
-			id,
-			valid_until,
-			created_at,
-			updated_at,
-			user_id
-		FROM session
-		WHERE user_id = ?`,
-		userId,
-	)
+type scanner interface {
+	Scan(dest ...any) error
+}
 
+/*
+scanSession reads a session, the columns in the order of the queries above.
+*/
+func scanSession(row scanner) (*Session, error) {
 	session := &Session{}
 
 	var validUntilStr string
 	var createdAtStr string
 	var updatedAtStr string
+	var lastSeenAtStr sql.NullString
+	var remember int
 
 	err := row.Scan(
 		&session.Id,
@@ -219,6 +398,10 @@ func (s *sessionService) GetByUserID(userId string) (*Session, error) {
 		&createdAtStr,
 		&updatedAtStr,
 		&session.UserId,
+		&session.UserAgent,
+		&session.IP,
+		&lastSeenAtStr,
+		&remember,
 	)
 
 	if err != nil {
@@ -243,40 +426,37 @@ func (s *sessionService) GetByUserID(userId string) (*Session, error) {
 	session.ValidUntil = validUntil
 	session.CreatedAt = createdAt
 	session.UpdatedAt = updatedAt
+	session.Remember = remember == 1
+
+	// sessions from before the metadata were last seen when updated
+	session.LastSeenAt = updatedAt
+	if lastSeenAtStr.Valid {
+		lastSeenAt, err := utils.ConvertToTime(lastSeenAtStr.String)
+		if err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = lastSeenAt
+	}
 
 	return session, nil
 }
 
 /*
-Delete deletes the session with the given sessionID from the database.
+scanSessions reads all sessions from the rows and closes them.
 */
-func (s *sessionService) Delete(id string) error {
-	_, err := s.db.Exec(
-		`DELETE FROM session
-		WHERE id = ?`,
-		id,
-	)
+func scanSessions(rows *sql.Rows) ([]*Session, error) {
+	defer rows.Close()
 
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
+	sessions := []*Session{}
 
-/*
-CheckCookie returns the session from the cookie in the request.
-*/
-func (s *sessionService) CheckFromCookie(r *http.Request) (*Session, error) {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		return nil, err
-	}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
 
-	session, err := s.GetById(cookie.Value)
-	if err != nil {
-		return nil, err
+		sessions = append(sessions, session)
 	}
 
-	return session, nil
+	return sessions, rows.Err()
 }
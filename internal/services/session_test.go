@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+/*
+fakeRow scans the values into the destinations, like a row of the session table.
+*/
+type fakeRow []any
+
+func (r fakeRow) Scan(dest ...any) error {
+	for i, value := range r {
+		switch d := dest[i].(type) {
+		case *string:
+			*d = value.(string)
+		case *sql.NullString:
+			d.Scan(value)
+		case *int:
+			*d = value.(int)
+		}
+	}
+	return nil
+}
+
+func TestScanSession(t *testing.T) {
+	row := fakeRow{
+		"ses_1",
+		"2024-01-08T10:00:00Z",
+		"2024-01-01T10:00:00Z",
+		"2024-01-01T10:00:00Z",
+		"usr_1",
+		"curl/8.5.0",
+		"192.0.2.1",
+		"2024-01-02T10:00:00Z",
+		1,
+	}
+
+	session, err := scanSession(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if session.UserAgent != "curl/8.5.0" || session.IP != "192.0.2.1" || !session.Remember {
+		t.Errorf("Expected the device of the session, got %+v", session)
+	}
+
+	expected := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	if !session.LastSeenAt.Equal(expected) {
+		t.Errorf("Expected last seen %v, got %v", expected, session.LastSeenAt)
+	}
+
+	// sessions from before the metadata
+	row[7] = nil
+
+	session, err = scanSession(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !session.LastSeenAt.Equal(session.UpdatedAt) {
+		t.Errorf("Expected last seen at the update, got %v", session.LastSeenAt)
+	}
+}
+
+func TestSessionValidUntil(t *testing.T) {
+	config := SessionConfig{
+		Idle:     time.Hour,
+		Remember: 24 * time.Hour,
+		Absolute: 48 * time.Hour,
+	}
+
+	signin := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now      time.Time
+		remember bool
+		expected time.Time
+	}{
+		// slides with the use
+		{signin, false, signin.Add(time.Hour)},
+		{signin.Add(30 * time.Minute), false, signin.Add(90 * time.Minute)},
+		{signin, true, signin.Add(24 * time.Hour)},
+		// but not beyond the absolute maximum
+		{signin.Add(47 * time.Hour), false, signin.Add(48 * time.Hour)},
+		{signin.Add(30 * time.Hour), true, signin.Add(48 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		validUntil := config.ValidUntil(signin, test.now, test.remember)
+		if !validUntil.Equal(test.expected) {
+			t.Errorf("Expected %v at %v, got %v", test.expected, test.now, validUntil)
+		}
+	}
+}
+
+func TestCountActives(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	sessions := NewSessionService(context.Background(), db)
+
+	for _, id := range []string{"ses_1", "ses_2"} {
+		_, err := sessions.New(id, "usr_1", "test-agent", "127.0.0.1", false)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	_, err := db.Exec(
+		`UPDATE session SET valid_until = ? WHERE id = ?`,
+		time.Now().UTC().Add(-time.Minute),
+		"ses_2",
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	count, err := sessions.CountActives()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 active session, got %d", count)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+/*
+Statement is the summary of an account for a period, built from the reports.
+The balance is the income kept on the account: all income minus the paid payments.
+*/
+type Statement struct {
+	Account        *Account
+	From           time.Time
+	To             time.Time
+	OpeningBalance int
+	Income         int
+	Reserved       int
+	PaidOut        int
+	ClosingBalance int
+	Events         []*Event
+	Shares         []*RecipientTotals
+}
+
+type StatementService interface {
+	Get(accountId string, from, to time.Time) (*Statement, error)
+}
+
+type statementService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewStatementService(ctx context.Context, db *sql.DB) StatementService {
+	return &statementService{ctx: ctx, db: db}
+}
+
+/*
+Get is a function that builds the statement of an account
+for the period between from (inclusive) and to (exclusive).
+*/
+func (s *statementService) Get(accountId string, from, to time.Time) (*Statement, error) {
+	defer observe(s.ctx, "statement", "Get")()
+
+	account, err := NewAccountService(s.ctx, s.db).GetById(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		Account: account,
+		From:    from,
+		To:      to,
+		Events:  []*Event{},
+		Shares:  []*RecipientTotals{},
+	}
+
+	reportService := NewReportService(s.ctx, s.db)
+
+	filter := ReportFilter{
+		AccountIds: []string{accountId},
+		From:       from,
+		To:         to,
+	}
+
+	totals, err := reportService.ByAccount(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, total := range totals {
+		statement.OpeningBalance = total.OpeningBalance
+		statement.Income = total.Income
+		statement.Reserved = total.Reserved
+		statement.PaidOut = total.Paid
+		statement.ClosingBalance = total.ClosingBalance
+	}
+
+	shares, err := reportService.ByRecipient(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statement.Shares = shares
+
+	// events in the period
+	rows, err := s.db.Query(
+		`SELECT
+			id,
+			name,
+			description,
+			income,
+			reserved,
+			delivered_at,
+			created_at,
+			updated_at,
+			account_id
+		FROM event
+		WHERE account_id = ? AND delivered_at >= ? AND delivered_at < ?
+		ORDER BY delivered_at, id;`,
+		accountId,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	statement.Events = events
+
+	return statement, nil
+}
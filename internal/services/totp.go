@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"pengoe/internal/crypt"
+	"pengoe/internal/totp"
+	"pengoe/internal/utils"
+	"strings"
+	"time"
+)
+
+/*
+TOTPKey encrypts the TOTP secrets in the database,
+the server sets it from the configuration.
+*/
+var TOTPKey []byte
+
+/*
+recoveryCodeCount is the number of recovery codes a user gets.
+*/
+const recoveryCodeCount = 10
+
+var (
+	// ErrTOTPEnabled is returned when setting up TOTP for a user who has it
+	ErrTOTPEnabled = errors.New("Two-factor authentication is already enabled")
+
+	// ErrTOTPNotEnabled is returned when checking a code of a user without TOTP
+	ErrTOTPNotEnabled = errors.New("Two-factor authentication is not enabled")
+
+	// ErrInvalidCode is returned for wrong, expired or reused codes
+	ErrInvalidCode = errors.New("Invalid code")
+)
+
+type TOTP struct {
+	UserId    string
+	Secret    string
+	LastStep  int64
+	EnabledAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+/*
+Enabled tells if the setup was finished with a valid code.
+*/
+func (t *TOTP) Enabled() bool {
+	return !t.EnabledAt.IsZero()
+}
+
+type TOTPServiceInterface interface {
+	Get(userId string) (*TOTP, error)
+	Begin(userId string) (string, error)
+	Enable(userId, code string) ([]string, error)
+	Verify(userId, code string) error
+	UseRecoveryCode(userId, code string) error
+	RecoveryCodesLeft(userId string) (int, error)
+	Disable(userId string) error
+}
+
+type totpService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewTOTPService(ctx context.Context, db *sql.DB) TOTPServiceInterface {
+	return &totpService{ctx: ctx, db: db}
+}
+
+/*
+Get returns the TOTP of the user, with the decrypted secret.
+*/
+func (s *totpService) Get(userId string) (*TOTP, error) {
+	defer observe(s.ctx, "totp", "Get")()
+
+	row := s.db.QueryRow(
+		`SELECT
+			user_id,
+			secret,
+			last_step,
+			enabled_at,
+			created_at,
+			updated_at
+		FROM totp
+		WHERE user_id = ?`,
+		userId,
+	)
+
+	t := &TOTP{}
+
+	var encrypted string
+	var enabledAtStr sql.NullString
+	var createdAtStr string
+	var updatedAtStr string
+
+	err := row.Scan(
+		&t.UserId,
+		&encrypted,
+		&t.LastStep,
+		&enabledAtStr,
+		&createdAtStr,
+		&updatedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := crypt.Decrypt(TOTPKey, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	t.Secret = string(secret)
+
+	createdAt, err := utils.ConvertToTime(createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedAt, err := utils.ConvertToTime(updatedAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	t.CreatedAt = createdAt
+	t.UpdatedAt = updatedAt
+
+	if enabledAtStr.Valid {
+		enabledAt, err := utils.ConvertToTime(enabledAtStr.String)
+		if err != nil {
+			return nil, err
+		}
+		t.EnabledAt = enabledAt
+	}
+
+	return t, nil
+}
+
+/*
+Begin starts the setup: it stores a new secret for the user, not enabled
+until Enable gets a valid code of it. It returns the secret for the QR code.
+*/
+func (s *totpService) Begin(userId string) (string, error) {
+	defer observe(s.ctx, "totp", "Begin")()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := crypt.Encrypt(TOTPKey, []byte(secret))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	// a started setup is replaced, a finished one is kept
+	result, err := s.db.Exec(
+		`INSERT INTO totp (
+			user_id,
+			secret,
+			last_step,
+			created_at,
+			updated_at
+		) VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			last_step = 0,
+			updated_at = excluded.updated_at
+		WHERE totp.enabled_at IS NULL`,
+		userId,
+		encrypted,
+		now,
+		now,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	if affected == 0 {
+		return "", ErrTOTPEnabled
+	}
+
+	return secret, nil
+}
+
+/*
+Enable finishes the setup with a code of the new secret, and returns the
+recovery codes. They are shown once, only their hashes are stored.
+*/
+func (s *totpService) Enable(userId, code string) ([]string, error) {
+	defer observe(s.ctx, "totp", "Enable")()
+
+	t, err := s.Get(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Enabled() {
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := totp.Validate(t.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		hashes[i], err = utils.HashPassword(normalizeRecoveryCode(codes[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE totp
+		SET
+			last_step = ?,
+			enabled_at = ?,
+			updated_at = ?
+		WHERE user_id = ? AND enabled_at IS NULL`,
+		step,
+		now,
+		now,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrTOTPEnabled
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM recovery_code
+		WHERE user_id = ?`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		_, err = tx.Exec(
+			`INSERT INTO recovery_code (
+				id,
+				code_hash,
+				created_at,
+				user_id
+			) VALUES (?, ?, ?, ?)`,
+			utils.NewUUID("rcv"),
+			hash,
+			now,
+			userId,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, tx.Commit()
+}
+
+/*
+Verify checks a code of the user at signin. A code works only once:
+the step of the last accepted code is stored, and only later ones pass.
+*/
+func (s *totpService) Verify(userId, code string) error {
+	defer observe(s.ctx, "totp", "Verify")()
+
+	t, err := s.Get(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if !t.Enabled() {
+		return ErrTOTPNotEnabled
+	}
+
+	step, ok := totp.Validate(t.Secret, code, time.Now())
+	if !ok || step <= t.LastStep {
+		return ErrInvalidCode
+	}
+
+	// two requests with the same code, only one wins
+	result, err := s.db.Exec(
+		`UPDATE totp
+		SET
+			last_step = ?,
+			updated_at = ?
+		WHERE user_id = ? AND last_step < ?`,
+		step,
+		time.Now().UTC(),
+		userId,
+		step,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+/*
+UseRecoveryCode checks a recovery code of the user, and uses it up.
+*/
+func (s *totpService) UseRecoveryCode(userId, code string) error {
+	defer observe(s.ctx, "totp", "UseRecoveryCode")()
+
+	rows, err := s.db.Query(
+		`SELECT id, code_hash FROM recovery_code
+		WHERE user_id = ? AND used_at IS NULL`,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	code = normalizeRecoveryCode(code)
+	matched := ""
+
+	for rows.Next() {
+		var id, hash string
+		err := rows.Scan(&id, &hash)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		if matched == "" && utils.CheckPasswordHash(hash, code) {
+			matched = id
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if matched == "" {
+		return ErrInvalidCode
+	}
+
+	result, err := s.db.Exec(
+		`UPDATE recovery_code
+		SET used_at = ?
+		WHERE id = ? AND used_at IS NULL`,
+		time.Now().UTC(),
+		matched,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+/*
+RecoveryCodesLeft returns the number of the unused recovery codes of the user.
+*/
+func (s *totpService) RecoveryCodesLeft(userId string) (int, error) {
+	defer observe(s.ctx, "totp", "RecoveryCodesLeft")()
+
+	var count int
+
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM recovery_code
+		WHERE user_id = ? AND used_at IS NULL`,
+		userId,
+	).Scan(&count)
+
+	return count, err
+}
+
+/*
+Disable turns off TOTP for the user, and deletes the recovery codes.
+*/
+func (s *totpService) Disable(userId string) error {
+	defer observe(s.ctx, "totp", "Disable")()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM totp
+		WHERE user_id = ?`,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM recovery_code
+		WHERE user_id = ?`,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+/*
+newRecoveryCode returns a random code of 10 characters (50 bits),
+eg. "k3m9q-x2p7w".
+*/
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+/*
+normalizeRecoveryCode accepts the codes typed without the dash,
+with spaces or in upper case.
+*/
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"pengoe/internal/crypt"
+	"pengoe/internal/totp"
+	"testing"
+	"time"
+)
+
+/*
+newTestTOTP enables TOTP for the user, and returns the secret and the
+recovery codes. The code of the current step is used up by the setup.
+*/
+func newTestTOTP(t *testing.T, service TOTPServiceInterface, userId string) (string, []string) {
+	previous := TOTPKey
+	TOTPKey = make([]byte, crypt.KeySize)
+	t.Cleanup(func() {
+		TOTPKey = previous
+	})
+
+	secret, err := service.Begin(userId)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	codes, err := service.Enable(userId, code)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return secret, codes
+}
+
+func TestTOTPVerifyRejectsReplay(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	service := NewTOTPService(context.Background(), db)
+	secret, _ := newTestTOTP(t, service, "usr_1")
+
+	now := time.Now()
+
+	// the code of the setup and the ones before it are used up
+	for _, at := range []time.Time{now, now.Add(-totp.Period)} {
+		code, _ := totp.Code(secret, at)
+		err := service.Verify("usr_1", code)
+		if !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("Expected ErrInvalidCode for a used step, got %v", err)
+		}
+	}
+
+	// the next code works once
+	next, _ := totp.Code(secret, now.Add(totp.Period))
+
+	err := service.Verify("usr_1", next)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = service.Verify("usr_1", next)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected ErrInvalidCode for a replayed code, got %v", err)
+	}
+
+	err = service.Verify("usr_1", "000000x")
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected ErrInvalidCode for a wrong code, got %v", err)
+	}
+
+	err = service.Verify("usr_2", next)
+	if !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Errorf("Expected ErrTOTPNotEnabled for a user without TOTP, got %v", err)
+	}
+}
+
+func TestTOTPRecoveryCodeSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", true)
+
+	service := NewTOTPService(context.Background(), db)
+	_, codes := newTestTOTP(t, service, "usr_1")
+
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	err := service.UseRecoveryCode("usr_1", codes[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = service.UseRecoveryCode("usr_1", codes[0])
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected ErrInvalidCode for a used recovery code, got %v", err)
+	}
+
+	left, err := service.RecoveryCodesLeft("usr_1")
+	if err != nil || left != recoveryCodeCount-1 {
+		t.Errorf("Expected %d recovery codes left, got %d, %v", recoveryCodeCount-1, left, err)
+	}
+
+	_, err = service.Begin("usr_1")
+	if !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("Expected ErrTOTPEnabled for a new setup, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"pengoe/internal/utils"
@@ -16,6 +17,16 @@ type User struct {
 	Password  string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// zero until the user opens the link of the verification email
+	EmailVerifiedAt time.Time
+}
+
+/*
+Verified tells if the user has verified the email address.
+*/
+func (u *User) Verified() bool {
+	return !u.EmailVerifiedAt.IsZero()
 }
 
 type UserServiceInterface interface {
@@ -27,17 +38,20 @@ type UserServiceInterface interface {
 }
 
 type userService struct {
-	db *sql.DB
+	ctx context.Context
+	db  *sql.DB
 }
 
-func NewUserService(db *sql.DB) UserServiceInterface {
-	return &userService{db: db}
+func NewUserService(ctx context.Context, db *sql.DB) UserServiceInterface {
+	return &userService{ctx: ctx, db: db}
 }
 
 /*
 Signup is a function that adds a new user to the database.
 */
 func (s *userService) Signup(id, username, email, firstname, lastname, password string) error {
+	defer observe(s.ctx, "user", "Signup")()
+
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
 		return err
@@ -78,6 +92,8 @@ and checks if the passwords match.
 If correct, it returns the user's id.
 */
 func (s *userService) Signin(usernameOrEmail, password string) (id string, err error) {
+	defer observe(s.ctx, "user", "Signin")()
+
 	query, err := s.db.Query(
 		`SELECT
 			id,
@@ -116,130 +132,78 @@ func (s *userService) Signin(usernameOrEmail, password string) (id string, err e
 GetById is a function that gets a user from the database by username.
 */
 func (s *userService) GetById(id string) (*User, error) {
-	query, err := s.db.Query(
-		"SELECT * FROM user WHERE id = ?",
+	defer observe(s.ctx, "user", "GetById")()
+
+	row := s.db.QueryRow(
+		`SELECT `+userColumns+` FROM user WHERE id = ?`,
 		id,
 	)
-	if err != nil {
-		return nil, err
-	}
 
-	user := User{}
-
-	var createdAtStr string
-	var updatedAtStr string
-
-	for query.Next() {
-		err := query.Scan(
-			&user.Id,
-			&user.Username,
-			&user.Email,
-			&user.Fistname,
-			&user.Lastname,
-			&user.Password,
-			&createdAtStr,
-			&updatedAtStr,
-		)
-		if err != nil {
-			return &user, err
-		}
-	}
-
-	createdAt, err := utils.ConvertToTime(createdAtStr)
-	if err != nil {
-		return nil, err
-	}
-
-	updatedAt, err := utils.ConvertToTime(updatedAtStr)
-	if err != nil {
-		return nil, err
-	}
-
-	user.Password = ""
-	user.CreatedAt = createdAt
-	user.UpdatedAt = updatedAt
-
-	return &user, nil
+	return scanUser(row)
 }
 
 /*
 GetByUsername is a function that gets a user from the database by username.
 */
 func (s *userService) GetByUsername(username string) (*User, error) {
-	query, err := s.db.Query(
-		"SELECT * FROM user WHERE username = ?",
+	defer observe(s.ctx, "user", "GetByUsername")()
+
+	row := s.db.QueryRow(
+		`SELECT `+userColumns+` FROM user WHERE username = ?`,
 		username,
 	)
-	if err != nil {
-		return nil, err
-	}
-
-	user := User{}
-
-	var createdAtStr string
-	var updatedAtStr string
-
-	for query.Next() {
-		err := query.Scan(
-			&user.Id,
-			&user.Username,
-			&user.Email,
-			&user.Fistname,
-			&user.Lastname,
-			&user.Password,
-			&createdAtStr,
-			&updatedAtStr,
-		)
-		if err != nil {
-			return &user, err
-		}
-	}
 
-	createdAt, err := utils.ConvertToTime(createdAtStr)
-	if err != nil {
-		return nil, err
-	}
+	return scanUser(row)
+}
 
-	updatedAt, err := utils.ConvertToTime(updatedAtStr)
-	if err != nil {
-		return nil, err
-	}
+/*
+GetByEmail is a function that gets a user from the database by email.
+*/
+func (s *userService) GetByEmail(email string) (*User, error) {
+	defer observe(s.ctx, "user", "GetByEmail")()
 
-	user.Password = ""
-	user.CreatedAt = createdAt
-	user.UpdatedAt = updatedAt
+	row := s.db.QueryRow(
+		`SELECT `+userColumns+` FROM user WHERE email = ?`,
+		email,
+	)
 
-	return &user, nil
+	return scanUser(row)
 }
 
 /*
-GetByEmail is a function that gets a user from the database by email.
+userColumns are the columns read by scanUser, without the password.
 */
-func (s *userService) GetByEmail(email string) (*User, error) {
-	query, err := s.db.Query("SELECT * FROM user WHERE email = ?", email)
-	if err != nil {
-		return nil, err
-	}
+const userColumns = `id,
+		username,
+		email,
+		firstname,
+		lastname,
+		created_at,
+		updated_at,
+		email_verified_at`
 
-	user := User{}
+/*
+scanUser reads a user, the columns in the order of userColumns.
+*/
+func scanUser(row scanner) (*User, error) {
+	user := &User{}
 
 	var createdAtStr string
 	var updatedAtStr string
-
-	for query.Next() {
-		err := query.Scan(
-			&user.Id,
-			&user.Username,
-			&user.Email,
-			&user.Fistname,
-			&user.Lastname,
-			&user.Password,
-			&createdAtStr,
-			&updatedAtStr,
-		)
-		if err != nil {
-			return &user, err
-		}
+	var emailVerifiedAtStr sql.NullString
+
+	err := row.Scan(
+		&user.Id,
+		&user.Username,
+		&user.Email,
+		&user.Fistname,
+		&user.Lastname,
+		&createdAtStr,
+		&updatedAtStr,
+		&emailVerifiedAtStr,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	createdAt, err := utils.ConvertToTime(createdAtStr)
@@ -252,9 +216,16 @@ func (s *userService) GetByEmail(email string) (*User, error) {
 		return nil, err
 	}
 
-	user.Password = ""
 	user.CreatedAt = createdAt
 	user.UpdatedAt = updatedAt
 
-	return &user, nil
+	if emailVerifiedAtStr.Valid {
+		emailVerifiedAt, err := utils.ConvertToTime(emailVerifiedAtStr.String)
+		if err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = emailVerifiedAt
+	}
+
+	return user, nil
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanUser(t *testing.T) {
+	row := fakeRow{
+		"usr_1",
+		"jane",
+		"jane@example.com",
+		"Jane",
+		"Doe",
+		"2024-01-01T10:00:00Z",
+		"2024-01-01T10:00:00Z",
+		nil,
+	}
+
+	user, err := scanUser(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.Email != "jane@example.com" || user.Verified() {
+		t.Errorf("Expected an unverified user, got %+v", user)
+	}
+
+	row[7] = "2024-01-02T10:00:00Z"
+
+	user, err = scanUser(row)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !user.Verified() {
+		t.Errorf("Expected a verified user, got %+v", user)
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(code) != 11 || code[5] != '-' {
+		t.Errorf("Expected a code like xxxxx-xxxxx, got %q", code)
+	}
+
+	other, _ := newRecoveryCode()
+	if code == other {
+		t.Errorf("Expected different codes")
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	for _, typed := range []string{code, code[:5] + code[6:], " " + strings.ToUpper(code) + " "} {
+		if normalizeRecoveryCode(typed) != normalized {
+			t.Errorf("Expected %q to be accepted as %q", typed, normalized)
+		}
+	}
+}
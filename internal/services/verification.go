@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pengoe/internal/utils"
+	"time"
+)
+
+/*
+verificationTokenLifetime is how long a verification link works.
+*/
+const verificationTokenLifetime = 24 * time.Hour
+
+/*
+verificationResendInterval is the shortest time between two verification emails.
+*/
+const verificationResendInterval = time.Minute
+
+var (
+	// ErrInvalidVerificationToken is returned for unknown, used or expired tokens
+	ErrInvalidVerificationToken = errors.New("Invalid or expired verification token")
+
+	// ErrVerificationTooSoon is returned if the last email was sent a moment ago
+	ErrVerificationTooSoon = errors.New("Verification email sent recently")
+)
+
+type EmailVerificationServiceInterface interface {
+	New(userId string) (string, error)
+	Verify(token string) (string, error)
+}
+
+type emailVerificationService struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewEmailVerificationService(ctx context.Context, db *sql.DB) EmailVerificationServiceInterface {
+	return &emailVerificationService{ctx: ctx, db: db}
+}
+
+/*
+New creates a verification token for the user, and returns it for the link.
+Like the reset tokens, only its hash is stored.
+*/
+func (s *emailVerificationService) New(userId string) (string, error) {
+	defer observe(s.ctx, "email_verification", "New")()
+
+	now := time.Now().UTC()
+
+	var recent int
+
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM email_verification
+		WHERE user_id = ? AND created_at > ?`,
+		userId,
+		now.Add(-verificationResendInterval),
+	).Scan(&recent)
+	if err != nil {
+		return "", err
+	}
+
+	if recent > 0 {
+		return "", ErrVerificationTooSoon
+	}
+
+	token, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO email_verification (
+			id,
+			token_hash,
+			expires_at,
+			created_at,
+			user_id
+		) VALUES (?, ?, ?, ?, ?)`,
+		utils.NewUUID("evf"),
+		hashToken(token),
+		now.Add(verificationTokenLifetime),
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+/*
+Verify marks the email of the user of the token verified, and uses up every
+verification token of the user. It returns the user id.
+*/
+func (s *emailVerificationService) Verify(token string) (string, error) {
+	defer observe(s.ctx, "email_verification", "Verify")()
+
+	now := time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userId string
+
+	err = tx.QueryRow(
+		`SELECT user_id FROM email_verification
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashToken(token),
+		now,
+	).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE email_verification
+		SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL`,
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// the first verification counts
+	_, err = tx.Exec(
+		`UPDATE user
+		SET
+			email_verified_at = ?,
+			updated_at = ?
+		WHERE id = ? AND email_verified_at IS NULL`,
+		now,
+		now,
+		userId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return userId, tx.Commit()
+}
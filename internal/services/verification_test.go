@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEmailVerification(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", false)
+
+	verifications := NewEmailVerificationService(context.Background(), db)
+	users := NewUserService(context.Background(), db)
+
+	token, err := verifications.New("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the next email only after the resend interval
+	_, err = verifications.New("usr_1")
+	if !errors.Is(err, ErrVerificationTooSoon) {
+		t.Errorf("Expected ErrVerificationTooSoon, got %v", err)
+	}
+
+	userId, err := verifications.Verify(token)
+	if err != nil || userId != "usr_1" {
+		t.Fatalf("Expected usr_1, got %q, %v", userId, err)
+	}
+
+	user, err := users.GetById("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !user.Verified() {
+		t.Errorf("Expected the user to be verified")
+	}
+
+	_, err = verifications.Verify(token)
+	if !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("Expected ErrInvalidVerificationToken for a used token, got %v", err)
+	}
+}
+
+func TestEmailVerificationExpired(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "usr_1", "jane@example.com", false)
+
+	verifications := NewEmailVerificationService(context.Background(), db)
+	users := NewUserService(context.Background(), db)
+
+	token, err := verifications.New("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = db.Exec(
+		`UPDATE email_verification SET expires_at = ?`,
+		time.Now().UTC().Add(-time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = verifications.Verify(token)
+	if !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("Expected ErrInvalidVerificationToken, got %v", err)
+	}
+
+	user, err := users.GetById("usr_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if user.Verified() {
+		t.Errorf("Expected the user not to be verified")
+	}
+}
@@ -0,0 +1,66 @@
+package token
+
+import (
+	"net/http"
+	"time"
+)
+
+/*
+SessionCookie returns the cookie with the session id. Without expires it is
+cleared when the browser closes, an expires in the past deletes it.
+Secure cookies are for production, they are SameSite=Lax too.
+*/
+func SessionCookie(sessionId string, expires time.Time, secure bool) *http.Cookie {
+	sameSite := http.SameSiteDefaultMode
+	if secure {
+		sameSite = http.SameSiteLaxMode
+	}
+
+	return &http.Cookie{
+		Name:     "session",
+		Value:    sessionId,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	}
+}
+
+/*
+ChallengeCookie returns the cookie of a signin waiting for its second step.
+It is only sent to the signin pages, and a maxAge below 0 deletes it.
+*/
+func ChallengeCookie(challenge string, maxAge int, secure bool) *http.Cookie {
+	sameSite := http.SameSiteDefaultMode
+	if secure {
+		sameSite = http.SameSiteLaxMode
+	}
+
+	return &http.Cookie{
+		Name:     "signin_challenge",
+		Value:    challenge,
+		Path:     "/signin",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	}
+}
+
+/*
+OIDCStateCookie returns the cookie of a signin at the provider, only sent
+to the OIDC signin pages. It is SameSite=Lax, so it is sent when the
+provider redirects back. A maxAge below 0 deletes it.
+*/
+func OIDCStateCookie(state string, maxAge int, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     "oidc_state",
+		Value:    state,
+		Path:     "/signin/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
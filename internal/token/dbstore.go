@@ -0,0 +1,137 @@
+package token
+
+import (
+	"database/sql"
+	"errors"
+	"pengoe/internal/utils"
+	"time"
+)
+
+type dbStore struct {
+	db *sql.DB
+}
+
+/*
+NewDBStore returns a store keeping the tokens in the csrf_token table.
+*/
+func NewDBStore(db *sql.DB) Store {
+	return &dbStore{db: db}
+}
+
+func (s *dbStore) Get(sessionId string) (*Token, error) {
+	row := s.db.QueryRow(
+		`SELECT
+			value,
+			valid_until
+		FROM csrf_token
+		WHERE session_id = ?`,
+		sessionId,
+	)
+
+	token := &Token{SessionID: sessionId}
+
+	var validStr string
+
+	err := row.Scan(&token.Value, &validStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.Valid, err = utils.ConvertToTime(validStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (s *dbStore) Set(token Token) error {
+	_, err := s.db.Exec(
+		`INSERT INTO csrf_token (
+			session_id,
+			value,
+			valid_until
+		) VALUES (?, ?, ?)
+		ON CONFLICT (session_id) DO UPDATE SET
+			value = excluded.value,
+			valid_until = excluded.valid_until`,
+		token.SessionID,
+		token.Value,
+		token.Valid.UTC(),
+	)
+
+	return err
+}
+
+func (s *dbStore) CompareAndSwap(sessionId, old string, next Token) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE csrf_token
+		SET
+			value = ?,
+			valid_until = ?
+		WHERE session_id = ? AND value = ?`,
+		next.Value,
+		next.Valid.UTC(),
+		sessionId,
+		old,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected == 0 {
+		// unknown session, or the token was replaced
+		_, err := s.Get(sessionId)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *dbStore) Delete(sessionId string) error {
+	_, err := s.db.Exec(
+		`DELETE FROM csrf_token WHERE session_id = ?`,
+		sessionId,
+	)
+
+	return err
+}
+
+/*
+EvictStale removes the tokens of the expired or deleted sessions,
+the session table knows when a session is over (idle is not used).
+*/
+func (s *dbStore) EvictStale(idle time.Duration) (int, error) {
+	result, err := s.db.Exec(
+		`DELETE FROM csrf_token
+		WHERE session_id NOT IN (
+			SELECT id FROM session WHERE valid_until > ?
+		)`,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	evicted, err := result.RowsAffected()
+	return int(evicted), err
+}
+
+func (s *dbStore) Count() (int, error) {
+	var count int
+
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM csrf_token`).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
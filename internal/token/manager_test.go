@@ -0,0 +1,189 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerifyOrRenew(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(store)
+
+	created, _ := manager.Create("ses_1")
+
+	renewed, err := manager.VerifyOrRenewCSRFToken("ses_1", created.Value)
+	if err != nil || renewed != nil {
+		t.Errorf("Expected a valid token, got %v, %v", renewed, err)
+	}
+
+	_, err = manager.VerifyOrRenewCSRFToken("ses_1", "wrong")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+
+	// expire it
+	store.Set(Token{SessionID: "ses_1", Value: created.Value, Valid: time.Now().Add(-time.Second).UTC()})
+
+	renewed, err = manager.VerifyOrRenewCSRFToken("ses_1", created.Value)
+	if err != nil || renewed == nil || renewed.Value == created.Value {
+		t.Fatalf("Expected a renewed token, got %v, %v", renewed, err)
+	}
+
+	token, _ := manager.Get("ses_1")
+	if token.Value != renewed.Value {
+		t.Errorf("Expected %q in the store, got %q", renewed.Value, token.Value)
+	}
+}
+
+/*
+Concurrent requests with the same expired token all get the same new token.
+*/
+func TestVerifyOrRenewConcurrent(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(store)
+
+	store.Set(Token{SessionID: "ses_1", Value: "expired", Valid: time.Now().Add(-time.Second).UTC()})
+
+	var wg sync.WaitGroup
+	results := make([]string, 50)
+
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			renewed, err := manager.VerifyOrRenewCSRFToken("ses_1", "expired")
+			if err != nil {
+				// the token was rotated before this request read it
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			results[i] = renewed.Value
+		}(i)
+	}
+
+	wg.Wait()
+
+	current, _ := manager.Get("ses_1")
+	for _, result := range results {
+		if result != "" && result != current.Value {
+			t.Errorf("Expected %q, got %q", current.Value, result)
+		}
+	}
+}
+
+/*
+Of the concurrent requests with the same token, only one is accepted.
+*/
+func TestVerifyAndRotateSingleUse(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+
+	created, _ := manager.Create("ses_1")
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := manager.VerifyAndRotate("ses_1", created.Value)
+			if err == nil {
+				accepted.Add(1)
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if accepted.Load() != 1 {
+		t.Errorf("Expected 1 accepted request, got %d", accepted.Load())
+	}
+}
+
+/*
+Run with -race: many sessions created, read, rotated and deleted at once.
+*/
+func TestManagerStress(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			sessionId := fmt.Sprintf("ses_%d", i%5)
+
+			for j := 0; j < 100; j++ {
+				switch j % 5 {
+				case 0:
+					manager.Create(sessionId)
+				case 1:
+					token, err := manager.Get(sessionId)
+					if err == nil {
+						manager.VerifyAndRotate(sessionId, token.Value)
+					}
+				case 2:
+					token, err := manager.Get(sessionId)
+					if err == nil {
+						manager.VerifyOrRenewCSRFToken(sessionId, token.Value)
+					}
+				case 3:
+					manager.RenewToken(sessionId)
+				case 4:
+					if j%20 == 4 {
+						manager.Delete(sessionId)
+					}
+					manager.Count()
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	count, _ := manager.Count()
+	if count > 5 {
+		t.Errorf("Expected at most 5 tokens, got %d", count)
+	}
+}
+
+func TestEvictStale(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+
+	manager.Create("ses_old")
+	time.Sleep(20 * time.Millisecond)
+	manager.Create("ses_new")
+
+	evicted, err := manager.EvictStale(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if evicted != 1 {
+		t.Errorf("Expected 1 evicted token, got %d", evicted)
+	}
+
+	_, err = manager.Get("ses_old")
+	if !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+
+	_, err = manager.Get("ses_new")
+	if err != nil {
+		t.Errorf("Expected the new token, got %v", err)
+	}
+}
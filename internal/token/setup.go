@@ -1,14 +1,11 @@
 package token
 
 import (
+	"crypto/subtle"
 	"errors"
-	"fmt"
 	"net/http"
-	"pengoe/internal/db"
-	"pengoe/internal/logger"
-	"pengoe/internal/services"
+	"pengoe/internal/metrics"
 	"pengoe/internal/utils"
-	"sync"
 	"time"
 )
 
@@ -18,154 +15,224 @@ type Token struct {
 	Valid     time.Time
 }
 
+/*
+lifetime is how long a CSRF token is valid, it is renewed on the first use after.
+TODO: change to 10 minutes
+*/
+const lifetime = 10 * time.Second
+
+/*
+ErrInvalidToken is returned if the token of the request is not the one of the session.
+*/
+var ErrInvalidToken = errors.New("CSRF token is invalid")
+
 type tokenManagerInterface interface {
 	Create(sessionId string) (*Token, error)
 	Get(sessionId string) (*Token, error)
 	Delete(sessionId string) error
 	RenewToken(sessionId string) (*Token, error)
 	VerifyOrRenewCSRFToken(sessionId string, tokenFromRequest string) (*Token, error)
+	VerifyAndRotate(sessionId string, tokenFromRequest string) (*Token, error)
+	EvictStale(idle time.Duration) (int, error)
+	Count() (int, error)
 }
 
+/*
+TokenManager creates, checks and rotates the tokens. It has no lock of its
+own: the store makes every operation atomic, so concurrent requests of the
+same session wait for each other instead of failing.
+*/
 type TokenManager struct {
-	tokens map[string]Token // sessionID -> token
-	mutex  sync.Mutex
+	store Store
 }
 
-func newManager() tokenManagerInterface {
+/*
+NewManager returns a token manager keeping the tokens in the store.
+*/
+func NewManager(store Store) tokenManagerInterface {
 	return &TokenManager{
-		tokens: make(map[string]Token),
-		mutex:  sync.Mutex{},
+		store: store,
 	}
 }
 
-var Manager tokenManagerInterface = newManager()
+/*
+Global Manager, in memory until the server sets the configured store.
+*/
+var Manager tokenManagerInterface = NewManager(NewMemoryStore())
 
 /*
-Create creates a new token in memory. Server session and
+Create creates a new token in the store. Server session and
 tokens are linked by the sessionID, they should be in sync.
 */
 func (m *TokenManager) Create(sessionID string) (*Token, error) {
-	csrfToken, tokenErr := utils.GenerateCSRFToken()
-	if tokenErr != nil {
-		return &Token{}, tokenErr
+	token, err := newToken(sessionID)
+	if err != nil {
+		return &Token{}, err
 	}
 
-	locked := m.mutex.TryLock()
-	if !locked {
-		return &Token{}, errors.New("Could not lock token manager")
+	err = m.store.Set(*token)
+	if err != nil {
+		return &Token{}, err
 	}
 
-	defer m.mutex.Unlock()
+	return token, nil
+}
 
-	token := Token{
-		SessionID: sessionID,
-		Value:     csrfToken,
-		// TODO: change to 9 minutes
-		Valid: time.Now().Add(10 * time.Second).UTC(),
+/*
+Get returns the token with the given sessionID.
+*/
+func (m *TokenManager) Get(sessionId string) (*Token, error) {
+	token, err := m.store.Get(sessionId)
+	if err != nil {
+		return &Token{}, err
 	}
 
-	m.tokens[sessionID] = token
+	return token, nil
+}
 
-	return &token, nil
+/*
+Delete deletes the token with the given sessionID.
+*/
+func (m *TokenManager) Delete(sessionId string) error {
+	return m.store.Delete(sessionId)
 }
 
 /*
-Get returns the token with the given sessionID.
+RenewToken generates a new token for the given sessionID.
 */
-func (m *TokenManager) Get(sessionId string) (*Token, error) {
-	locked := m.mutex.TryLock()
-	if !locked {
-		return &Token{}, errors.New("Could not lock token manager")
+func (m *TokenManager) RenewToken(sessionId string) (*Token, error) {
+	_, err := m.store.Get(sessionId)
+	if err != nil {
+		return nil, err
 	}
-	defer m.mutex.Unlock()
 
-	token, ok := m.tokens[sessionId]
-	if !ok {
-		return &Token{}, errors.New(fmt.Sprintf("Session %s not found", sessionId))
+	token, err := newToken(sessionId)
+	if err != nil {
+		return nil, err
 	}
 
-	return &token, nil
+	err = m.store.Set(*token)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.CSRFRenewals.Inc()
+
+	return token, nil
 }
 
 /*
-Delete deletes the token with the given sessionID.
+VerifyOrRenewCSRFToken checks the token of the request. It returns nothing if
+the token is valid, and a new token if it is expired. Concurrent requests with
+the same expired token get the same new token.
 */
-func (m *TokenManager) Delete(sessionId string) error {
-	locked := m.mutex.TryLock()
-	if !locked {
-		return errors.New("Could not lock token manager")
+func (m *TokenManager) VerifyOrRenewCSRFToken(sessionId string, tokenFromRequest string) (*Token, error) {
+	// check if there is a server session
+	token, err := m.store.Get(sessionId)
+	if err != nil {
+		return nil, err
 	}
-	defer m.mutex.Unlock()
 
-	delete(m.tokens, sessionId)
+	// check if the csrf token is valid
+	if !equal(token.Value, tokenFromRequest) {
+		return nil, ErrInvalidToken
+	}
+
+	// csrf token is valid
+	if !token.Valid.Before(time.Now().UTC()) {
+		return nil, nil
+	}
 
-	return nil
+	// csrf token is expired, renew it unless another request did already
+	next, swapped, err := m.rotate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !swapped {
+		return m.store.Get(sessionId)
+	}
+
+	return next, nil
 }
 
 /*
-RenewCSRFToken generates a new token for the given sessionID.
+VerifyAndRotate checks the token of the request, and replaces it with a new
+one in the same step, so a token is accepted only once: of two concurrent
+requests with the same token, only one succeeds. Expired tokens are rotated too.
 */
-func (m *TokenManager) RenewToken(sessionId string) (*Token, error) {
-	token, tokenErr := m.Get(sessionId)
-	if tokenErr != nil {
-		return nil, tokenErr
+func (m *TokenManager) VerifyAndRotate(sessionId string, tokenFromRequest string) (*Token, error) {
+	token, err := m.store.Get(sessionId)
+	if err != nil {
+		return nil, err
 	}
 
-	locked := m.mutex.TryLock()
-	if !locked {
-		return nil, errors.New("Could not lock token manager")
+	if !equal(token.Value, tokenFromRequest) {
+		return nil, ErrInvalidToken
 	}
-	defer m.mutex.Unlock()
 
-	newToken, newTokenErr := utils.GenerateCSRFToken()
-	if newTokenErr != nil {
-		return nil, newTokenErr
+	next, swapped, err := m.rotate(token)
+	if err != nil {
+		return nil, err
 	}
 
-	newCsrfToken := &Token{
-		SessionID: sessionId,
-		Value:     newToken,
-		// TODO: change to 10 minutes
-		Valid: time.Now().Add(10 * time.Second).UTC(),
+	// another request used the token first
+	if !swapped {
+		return nil, ErrInvalidToken
 	}
 
-	token = newCsrfToken
+	return next, nil
+}
 
-	m.tokens[sessionId] = *token
+/*
+EvictStale removes the tokens of the sessions that are over, so the store does
+not grow with sessions that never signed out. It returns the number removed.
+*/
+func (m *TokenManager) EvictStale(idle time.Duration) (int, error) {
+	return m.store.EvictStale(idle)
+}
 
-	return newCsrfToken, nil
+/*
+Count returns the number of tokens, so the number of active sessions.
+*/
+func (m *TokenManager) Count() (int, error) {
+	return m.store.Count()
 }
 
 /*
-GetOrRenewCSRFToken returns a new token or nothing if the token is valid.
+rotate replaces the token with a new one, if it was not replaced since it was read.
 */
-func (m *TokenManager) VerifyOrRenewCSRFToken(sessionId string, tokenFromRequest string) (*Token, error) {
-	// check if there is a server session
-	token, tokenErr := m.Get(sessionId)
-	if tokenErr != nil {
-		return nil, tokenErr
+func (m *TokenManager) rotate(current *Token) (*Token, bool, error) {
+	next, err := newToken(current.SessionID)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// check if the csrf token is valid
-	if token.Value != tokenFromRequest {
-		return nil, errors.New("CSRF token is invalid")
+	swapped, err := m.store.CompareAndSwap(current.SessionID, current.Value, *next)
+	if err != nil || !swapped {
+		return nil, false, err
 	}
 
-	// check if the csrf token is expired
-	if token.Valid.Before(time.Now().UTC()) {
-		// csrf token is expired
+	metrics.CSRFRenewals.Inc()
 
-		// renew csrf token
-		newToken, tokenErr := Manager.RenewToken(sessionId)
-		if tokenErr != nil {
-			return nil, tokenErr
-		}
+	return next, true, nil
+}
 
-		return newToken, nil
+func newToken(sessionId string) (*Token, error) {
+	value, err := utils.GenerateCSRFToken()
+	if err != nil {
+		return nil, err
 	}
 
-	// csrf token is valid
-	return nil, nil
+	return &Token{
+		SessionID: sessionId,
+		Value:     value,
+		Valid:     time.Now().Add(lifetime).UTC(),
+	}, nil
+}
+
+func equal(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
 func GetSessionFromCookie(r *http.Request) (*Token, error) {
@@ -181,30 +248,3 @@ func GetSessionFromCookie(r *http.Request) (*Token, error) {
 
 	return token, nil
 }
-
-func init() {
-	log := logger.Get()
-
-	// connect to the database
-	dbManager := db.NewDBManager()
-	db, dbErr := dbManager.GetDB()
-	if dbErr != nil {
-		log.Fatal(dbErr.Error())
-	}
-	defer db.Close()
-	sessionService := services.NewSessionService(db)
-
-	// get all active sessions from the database
-	sessions, err := sessionService.GetActives()
-	if err != nil {
-		log.Fatal(err.Error())
-	}
-
-	// create a new token for each active session
-	for _, session := range sessions {
-		_, err = Manager.Create(session.Id)
-		if err != nil {
-			log.Fatal(err.Error())
-		}
-	}
-}
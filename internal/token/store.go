@@ -0,0 +1,171 @@
+package token
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ErrTokenNotFound is returned by the stores for sessions without a token.
+*/
+var ErrTokenNotFound = errors.New("Token not found")
+
+/*
+Store keeps the CSRF tokens by session id.
+The memory store is enough for a single instance, the database store
+survives restarts and is shared by the instances behind a load balancer.
+CompareAndSwap replaces the token only if its value is still the old one,
+it is what makes verify-and-rotate atomic.
+*/
+type Store interface {
+	Get(sessionId string) (*Token, error)
+	Set(token Token) error
+	CompareAndSwap(sessionId, old string, next Token) (bool, error)
+	Delete(sessionId string) error
+	EvictStale(idle time.Duration) (int, error)
+	Count() (int, error)
+}
+
+/*
+shardCount is the number of shards of the memory store. Requests of
+different sessions rarely wait for each other.
+*/
+const shardCount = 32
+
+type memoryStore struct {
+	shards [shardCount]shard
+}
+
+type shard struct {
+	mutex   sync.RWMutex
+	entries map[string]*entry // sessionID -> token
+}
+
+type entry struct {
+	token Token
+
+	// lastSeen is the unix nano time of the last use, updated under the read lock
+	lastSeen atomic.Int64
+}
+
+/*
+NewMemoryStore returns a store keeping the tokens in a sharded map.
+*/
+func NewMemoryStore() Store {
+	s := &memoryStore{}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*entry)
+	}
+	return s
+}
+
+func (s *memoryStore) shard(sessionId string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(sessionId))
+	return &s.shards[h.Sum32()%shardCount]
+}
+
+func newEntry(token Token) *entry {
+	e := &entry{token: token}
+	e.lastSeen.Store(time.Now().UnixNano())
+	return e
+}
+
+func (s *memoryStore) Get(sessionId string) (*Token, error) {
+	sh := s.shard(sessionId)
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	e, ok := sh.entries[sessionId]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	e.lastSeen.Store(time.Now().UnixNano())
+
+	token := e.token
+	return &token, nil
+}
+
+func (s *memoryStore) Set(token Token) error {
+	sh := s.shard(token.SessionID)
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	sh.entries[token.SessionID] = newEntry(token)
+
+	return nil
+}
+
+func (s *memoryStore) CompareAndSwap(sessionId, old string, next Token) (bool, error) {
+	sh := s.shard(sessionId)
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	e, ok := sh.entries[sessionId]
+	if !ok {
+		return false, ErrTokenNotFound
+	}
+
+	if e.token.Value != old {
+		return false, nil
+	}
+
+	sh.entries[sessionId] = newEntry(next)
+
+	return true, nil
+}
+
+func (s *memoryStore) Delete(sessionId string) error {
+	sh := s.shard(sessionId)
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	delete(sh.entries, sessionId)
+
+	return nil
+}
+
+/*
+EvictStale removes the tokens not used for longer than idle.
+*/
+func (s *memoryStore) EvictStale(idle time.Duration) (int, error) {
+	cutoff := time.Now().Add(-idle).UnixNano()
+	evicted := 0
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+
+		sh.mutex.Lock()
+		for sessionId, e := range sh.entries {
+			if e.lastSeen.Load() < cutoff {
+				delete(sh.entries, sessionId)
+				evicted++
+			}
+		}
+		sh.mutex.Unlock()
+	}
+
+	return evicted, nil
+}
+
+func (s *memoryStore) Count() (int, error) {
+	count := 0
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+
+		sh.mutex.RLock()
+		count += len(sh.entries)
+		sh.mutex.RUnlock()
+	}
+
+	return count, nil
+}
@@ -0,0 +1,88 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	_, err := store.Get("ses_1")
+	if !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+
+	valid := time.Now().Add(time.Minute).UTC()
+
+	err = store.Set(Token{SessionID: "ses_1", Value: "first", Valid: valid})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = store.Set(Token{SessionID: "ses_1", Value: "second", Valid: valid})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token, err := store.Get("ses_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if token.Value != "second" || !token.Valid.Equal(valid) {
+		t.Errorf("Expected the second token, got %+v", token)
+	}
+
+	count, _ := store.Count()
+	if count != 1 {
+		t.Errorf("Expected 1 token, got %d", count)
+	}
+
+	store.Delete("ses_1")
+
+	count, _ = store.Count()
+	if count != 0 {
+		t.Errorf("Expected 0 tokens, got %d", count)
+	}
+}
+
+func TestManagerStore(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(store)
+
+	created, err := manager.Create("ses_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the token is in the store, another manager of the store sees it
+	other := NewManager(store)
+
+	token, err := other.Get("ses_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if token.Value != created.Value {
+		t.Errorf("Expected %q, got %q", created.Value, token.Value)
+	}
+
+	renewed, err := manager.RenewToken("ses_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token, _ = other.Get("ses_1")
+	if token.Value != renewed.Value || renewed.Value == created.Value {
+		t.Errorf("Expected the renewed token %q, got %q", renewed.Value, token.Value)
+	}
+
+	manager.Delete("ses_1")
+
+	_, err = other.Get("ses_1")
+	if err == nil {
+		t.Errorf("Expected error for deleted token")
+	}
+}
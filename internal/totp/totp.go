@@ -0,0 +1,153 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+The parameters of the codes, the defaults of the authenticator apps:
+6 digits, a new code every 30 seconds, HMAC-SHA1 (RFC 6238).
+*/
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	// secretSize is the size of the secrets in bytes, 160 bits as RFC 4226 recommends
+	secretSize = 20
+
+	// skew is how many periods a code is accepted before and after its own,
+	// for the clock of the phone and the time of typing
+	skew = 1
+)
+
+/*
+ErrInvalidSecret is returned for secrets that are not base32.
+*/
+var ErrInvalidSecret = errors.New("Invalid TOTP secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+/*
+GenerateSecret returns a new random secret, base32 encoded.
+*/
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+/*
+Step returns the number of the period of the time, the counter of the code.
+*/
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+/*
+Code returns the code of the secret at the time.
+*/
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, uint64(Step(t)), Digits), nil
+}
+
+/*
+Validate checks the code against the codes of the secret around the time.
+It returns the step of the matching code, so the caller can reject the
+codes of the same or earlier steps (a code works only once).
+*/
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	step := Step(t)
+	matched := int64(0)
+	ok := false
+
+	// every step is compared, so the time does not tell which one matched
+	for i := -skew; i <= skew; i++ {
+		expected := hotp(key, uint64(step+int64(i)), Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			matched = step + int64(i)
+			ok = true
+		}
+	}
+
+	return matched, ok
+}
+
+/*
+URI returns the otpauth URI of the secret for the authenticator apps, the
+content of the QR code. Eg. otpauth://totp/pengoe:jane?secret=...&issuer=pengoe
+*/
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+/*
+hotp returns the HOTP code of the counter (RFC 4226), with the dynamic truncation.
+*/
+func hotp(key []byte, counter uint64, digits int) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
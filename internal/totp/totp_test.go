@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+The SHA1 test vectors of RFC 6238 appendix B, 8 digits,
+the secret is the ASCII "12345678901234567890".
+*/
+func TestRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, test := range tests {
+		step := Step(time.Unix(test.unix, 0))
+
+		code := hotp(key, uint64(step), 8)
+		if code != test.expected {
+			t.Errorf("Expected %s at %d, got %s", test.expected, test.unix, code)
+		}
+	}
+}
+
+func TestCodeAndValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the last 6 digits of the 8 digit vector
+	if code != "081804" {
+		t.Errorf("Expected 081804, got %s", code)
+	}
+
+	step, ok := Validate(secret, code, now)
+	if !ok || step != Step(now) {
+		t.Errorf("Expected the code to be valid at step %d, got %d, %v", Step(now), step, ok)
+	}
+
+	// a period late is still fine
+	_, ok = Validate(secret, code, now.Add(Period))
+	if !ok {
+		t.Errorf("Expected the code to be valid a period later")
+	}
+
+	// two periods late is not
+	_, ok = Validate(secret, code, now.Add(2*Period))
+	if ok {
+		t.Errorf("Expected the code to be invalid two periods later")
+	}
+
+	for _, invalid := range []string{"", "12345", "000000", "0818045"} {
+		_, ok = Validate(secret, invalid, now)
+		if ok {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+
+	_, ok = Validate("not base32!", code, now)
+	if ok {
+		t.Errorf("Expected an invalid secret to fail")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 160 bits in base32
+	if len(secret) != 32 {
+		t.Errorf("Expected 32 characters, got %q", secret)
+	}
+
+	other, _ := GenerateSecret()
+	if secret == other {
+		t.Errorf("Expected different secrets")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("pengoe", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	expected := []string{
+		"otpauth://totp/pengoe:jane@example.com?",
+		"secret=JBSWY3DPEHPK3PXP",
+		"issuer=pengoe",
+		"digits=6",
+		"period=30",
+	}
+
+	for _, part := range expected {
+		if !strings.Contains(uri, part) {
+			t.Errorf("Expected %q in %q", part, uri)
+		}
+	}
+}
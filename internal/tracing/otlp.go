@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+/*
+OTLPExporter sends spans to an OpenTelemetry collector with OTLP/HTTP,
+JSON encoded. Eg. http://localhost:4318/v1/traces
+*/
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+/*
+NewOTLPExporter is a function that returns an exporter to the traces endpoint
+of a collector, with the headers added to every request (eg. an api key).
+*/
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Headers:  headers,
+		Client:   &http.Client{},
+	}
+}
+
+/*
+Export is a function that posts the spans to the collector.
+*/
+func (e *OTLPExporter) Export(ctx context.Context, serviceName string, spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// drain the body, so the connection can be reused
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("Collector responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+/*
+Shutdown is a function that closes the idle connections to the collector.
+*/
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.Client.CloseIdleConnections()
+	return nil
+}
+
+/*
+The OTLP JSON messages, see opentelemetry-proto trace/v1/trace.proto.
+Ids are hex strings, 64-bit integers are decimal strings.
+*/
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func otlpRequest(serviceName string, spans []SpanData) otlpTraces {
+	otlpSpans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		otlpSpans[i] = otlpSpan{
+			TraceId:           span.TraceID.String(),
+			SpanId:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+
+		if span.ParentSpanID.IsValid() {
+			otlpSpans[i].ParentSpanId = span.ParentSpanID.String()
+		}
+
+		for _, event := range span.Events {
+			otlpSpans[i].Events = append(otlpSpans[i].Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+				Name:         event.Name,
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+	}
+
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes([]Attribute{String("service.name", serviceName)}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "pengoe/internal/tracing"},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attributes []Attribute) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attributes))
+	for _, attribute := range attributes {
+		result = append(result, otlpKeyValue{Key: attribute.Key, Value: toOTLPValue(attribute.Value)})
+	}
+	return result
+}
+
+func toOTLPValue(v any) otlpValue {
+	switch value := v.(type) {
+	case string:
+		return otlpValue{StringValue: &value}
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &value}
+	case bool:
+		return otlpValue{BoolValue: &value}
+	}
+
+	s := fmt.Sprint(v)
+	return otlpValue{StringValue: &s}
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+/*
+TraceparentHeader is the W3C Trace Context header: version-traceid-spanid-flags.
+Eg. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+*/
+const TraceparentHeader = "traceparent"
+
+/*
+Extract is a function that returns a context with the remote parent of the
+traceparent header, so the spans of the request join the caller's trace.
+Invalid headers are ignored.
+*/
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+/*
+Inject is a function that sets the traceparent header from the current span,
+for requests to other services.
+*/
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+/*
+FormatTraceparent is a function that returns the traceparent header of a span, sampled.
+*/
+func FormatTraceparent(sc SpanContext) string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"
+}
+
+/*
+ParseTraceparent is a function that parses a traceparent header.
+*/
+func ParseTraceparent(s string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, traceId, spanId, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// version ff is invalid, version 00 has exactly four parts
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if len(traceId) != 32 || len(spanId) != 16 || len(flags) != 2 {
+		return SpanContext{}, false
+	}
+	if !isLowerHex(version + traceId + spanId + flags) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	hex.Decode(sc.TraceID[:], []byte(traceId))
+	hex.Decode(sc.SpanID[:], []byte(spanId))
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
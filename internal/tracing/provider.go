@@ -0,0 +1,232 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"pengoe/internal/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Exporter sends ended spans somewhere: a collector, a file, stdout.
+*/
+type Exporter interface {
+	Export(ctx context.Context, serviceName string, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+/*
+Options configure tracing.
+Spans are exported in batches of BatchSize, or every FlushInterval if fewer.
+At most QueueSize spans wait for export, the rest are dropped.
+*/
+type Options struct {
+	ServiceName   string
+	Exporters     []Exporter
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+}
+
+/*
+provider batches the ended spans and exports them in the background.
+*/
+type provider struct {
+	options Options
+	queue   chan SpanData
+	flush   chan chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// the provider of the process, nil while tracing is disabled
+var current atomic.Pointer[provider]
+
+/*
+Setup is a function that enables tracing with the given exporters, or
+disables it if there are none. The previous exporters are shut down.
+*/
+func Setup(options Options) error {
+	if options.ServiceName == "" {
+		options.ServiceName = "pengoe"
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 512
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 5 * time.Second
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 4096
+	}
+
+	var p *provider
+	if len(options.Exporters) > 0 {
+		p = &provider{
+			options: options,
+			queue:   make(chan SpanData, options.QueueSize),
+			flush:   make(chan chan struct{}),
+			done:    make(chan struct{}),
+		}
+		go p.run()
+	}
+
+	previous := current.Swap(p)
+	if previous != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), options.FlushInterval)
+		defer cancel()
+		return previous.shutdown(ctx)
+	}
+
+	return nil
+}
+
+/*
+Enabled is a function that checks if tracing is enabled.
+*/
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+/*
+Flush is a function that exports the queued spans now.
+*/
+func Flush(ctx context.Context) error {
+	p := current.Load()
+	if p == nil {
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+Shutdown is a function that disables tracing, exports the queued spans
+and shuts down the exporters. Call it at shutdown.
+*/
+func Shutdown(ctx context.Context) error {
+	p := current.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	return p.shutdown(ctx)
+}
+
+func (p *provider) enqueue(span SpanData) {
+	if p == nil {
+		return
+	}
+
+	select {
+	case p.queue <- span:
+	case <-p.done:
+	default:
+		// the exporters can not keep up, the span is lost
+		p.dropped.Add(1)
+	}
+}
+
+func (p *provider) shutdown(ctx context.Context) error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+
+	// wait for the last batch, the worker exits after it
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
+
+	errs := []error{}
+	for _, exporter := range p.options.Exporters {
+		err := exporter.Shutdown(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if dropped := p.dropped.Load(); dropped > 0 {
+		logger.Get().Warn("Spans dropped", "count", dropped)
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+run is the worker that collects the spans into batches and exports them.
+It exits after the flush requested by shutdown.
+*/
+func (p *provider) run() {
+	ticker := time.NewTicker(p.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := []SpanData{}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.options.BatchSize {
+				p.export(batch)
+				batch = []SpanData{}
+			}
+
+		case <-ticker.C:
+			p.export(batch)
+			batch = []SpanData{}
+
+		case flushed := <-p.flush:
+			// take what is in the queue now
+			for len(p.queue) > 0 {
+				batch = append(batch, <-p.queue)
+			}
+			p.export(batch)
+			batch = []SpanData{}
+			close(flushed)
+
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (p *provider) export(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.FlushInterval)
+	defer cancel()
+
+	for _, exporter := range p.options.Exporters {
+		err := exporter.Export(ctx, p.options.ServiceName, batch)
+		if err != nil {
+			logger.Get().Error("Could not export spans", "error", err.Error(), "count", len(batch))
+		}
+	}
+}
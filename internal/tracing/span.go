@@ -0,0 +1,319 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+TraceID identifies a trace, all the spans of a request share it.
+*/
+type TraceID [16]byte
+
+/*
+SpanID identifies a span inside its trace.
+*/
+type SpanID [8]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+/*
+IsValid is a function that checks if the id is not all zeros.
+*/
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+/*
+IsValid is a function that checks if the id is not all zeros.
+*/
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+/*
+SpanContext is the part of a span that is propagated to other services.
+*/
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+/*
+IsValid is a function that checks if both ids are set.
+*/
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+/*
+Kind is the role of the span, the values are the ones of OTLP.
+*/
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+/*
+StatusCode is the outcome of the span, the values are the ones of OTLP.
+*/
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+/*
+Attribute is a key-value pair of a span or an event.
+The value is a string, an int64, a float64 or a bool.
+*/
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+/*
+String, Int, Float and Bool are functions that return an attribute.
+*/
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Float(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+/*
+Event is something that happened during a span, eg. an error.
+*/
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+/*
+SpanData is the snapshot of an ended span, what the exporters get.
+*/
+type SpanData struct {
+	Name          string
+	Kind          Kind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+}
+
+/*
+Span is an operation of a trace. A nil span is valid and does nothing,
+it is what Start returns while tracing is disabled.
+*/
+type Span struct {
+	mutex    sync.Mutex
+	provider *provider
+	data     SpanData
+	ended    bool
+}
+
+/*
+SpanContext is a function that returns the ids of the span.
+*/
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID}
+}
+
+/*
+SetKind is a function that sets the role of the span, internal by default.
+*/
+func (s *Span) SetKind(kind Kind) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Kind = kind
+}
+
+/*
+SetAttributes is a function that adds attributes to the span.
+*/
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+/*
+SetStatus is a function that sets the outcome of the span.
+*/
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Status = code
+	s.data.StatusMessage = message
+}
+
+/*
+RecordError is a function that adds an "exception" event to the span,
+and sets its status to error.
+*/
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Events = append(s.data.Events, Event{
+		Name: "exception",
+		Time: time.Now(),
+		Attributes: []Attribute{
+			String("exception.type", fmt.Sprintf("%T", err)),
+			String("exception.message", err.Error()),
+		},
+	})
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+/*
+End is a function that ends the span and queues it for the exporters.
+Only the first call counts.
+*/
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	s.provider.enqueue(data)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+/*
+ContextWithSpan is a function that returns a context with the span as the
+current one, the parent of the spans started from the context.
+*/
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+/*
+SpanFromContext is a function that returns the current span, or nil.
+*/
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+/*
+Start is a function that starts a span, the child of the current span of the
+context or of the remote parent extracted from the request. The returned
+context has the new span as the current one. Call End on the span when the
+operation is done.
+*/
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	p := current.Load()
+	if p == nil {
+		return ctx, nil
+	}
+
+	data := SpanData{
+		Name:       name,
+		Kind:       KindInternal,
+		SpanID:     newSpanID(),
+		Start:      time.Now(),
+		Attributes: attributes,
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		data.TraceID = parent.data.TraceID
+		data.ParentSpanID = parent.data.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		data.TraceID = remote.TraceID
+		data.ParentSpanID = remote.SpanID
+	} else {
+		data.TraceID = newTraceID()
+	}
+
+	span := &Span{provider: p, data: data}
+	return ContextWithSpan(ctx, span), span
+}
@@ -0,0 +1,251 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+collector is a stand-in OTLP/HTTP collector, it keeps the received requests.
+*/
+type collector struct {
+	mutex    sync.Mutex
+	requests []otlpTraces
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var traces otlpTraces
+	err := json.NewDecoder(r.Body).Decode(&traces)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	c.requests = append(c.requests, traces)
+	c.headers = append(c.headers, r.Header.Clone())
+	c.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, "{}")
+}
+
+func (c *collector) spans() []otlpSpan {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	spans := []otlpSpan{}
+	for _, request := range c.requests {
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func setupTest(t *testing.T, exporters ...Exporter) {
+	err := Setup(Options{
+		ServiceName:   "pengoe-test",
+		Exporters:     exporters,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Cleanup(func() {
+		Shutdown(context.Background())
+	})
+}
+
+func TestOTLPExporter(t *testing.T) {
+	stand := &collector{}
+	server := httptest.NewServer(stand)
+	defer server.Close()
+
+	setupTest(t, NewOTLPExporter(server.URL+"/v1/traces", map[string]string{"X-Api-Key": "secret"}))
+
+	ctx, root := Start(context.Background(), "GET /account/:id", String("http.route", "/account/:id"))
+	root.SetKind(KindServer)
+
+	_, child := Start(ctx, "db event.Search")
+	child.SetAttributes(Int("rows", 3))
+	child.RecordError(errors.New("no such table"))
+	child.End()
+
+	root.End()
+
+	err := Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spans := stand.spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	if stand.headers[0].Get("X-Api-Key") != "secret" {
+		t.Errorf("Expected the api key header, got %q", stand.headers[0].Get("X-Api-Key"))
+	}
+
+	resource := stand.requests[0].ResourceSpans[0].Resource.Attributes[0]
+	if resource.Key != "service.name" || *resource.Value.StringValue != "pengoe-test" {
+		t.Errorf("Expected service.name pengoe-test, got %v", resource)
+	}
+
+	childSpan, rootSpan := spans[0], spans[1]
+
+	if rootSpan.Name != "GET /account/:id" || rootSpan.Kind != KindServer || rootSpan.ParentSpanId != "" {
+		t.Errorf("Expected a root server span, got %+v", rootSpan)
+	}
+
+	if childSpan.TraceId != rootSpan.TraceId {
+		t.Errorf("Expected trace id %s, got %s", rootSpan.TraceId, childSpan.TraceId)
+	}
+
+	if childSpan.ParentSpanId != rootSpan.SpanId {
+		t.Errorf("Expected parent %s, got %s", rootSpan.SpanId, childSpan.ParentSpanId)
+	}
+
+	if childSpan.Status.Code != StatusError || childSpan.Status.Message != "no such table" {
+		t.Errorf("Expected error status, got %+v", childSpan.Status)
+	}
+
+	if len(childSpan.Events) != 1 || childSpan.Events[0].Name != "exception" {
+		t.Errorf("Expected an exception event, got %+v", childSpan.Events)
+	}
+
+	if len(childSpan.Attributes) != 1 || *childSpan.Attributes[0].Value.IntValue != "3" {
+		t.Errorf("Expected rows=3, got %+v", childSpan.Attributes)
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL+"/v1/traces", nil)
+
+	err := exporter.Export(context.Background(), "pengoe", []SpanData{{Name: "test"}})
+	if err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	setupTest(t, NewWriterExporter(&buf))
+
+	_, span := Start(context.Background(), "middleware.DB")
+	span.End()
+	// ending twice does not export twice
+	span.End()
+
+	err := Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+
+	var line writerSpan
+	err = json.Unmarshal([]byte(lines[0]), &line)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if line.Name != "middleware.DB" || line.Service != "pengoe-test" || line.Kind != "internal" {
+		t.Errorf("Expected middleware.DB span, got %+v", line)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Shutdown(context.Background())
+
+	ctx := context.Background()
+	result, span := Start(ctx, "nothing")
+
+	if span != nil || result != ctx {
+		t.Errorf("Expected no span while disabled")
+	}
+
+	// a nil span does nothing
+	span.SetAttributes(String("a", "b"))
+	span.RecordError(errors.New("error"))
+	span.End()
+}
+
+func TestTraceparent(t *testing.T) {
+	var buf bytes.Buffer
+	setupTest(t, NewWriterExporter(&buf))
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := Extract(context.Background(), header)
+	ctx, span := Start(ctx, "GET /")
+
+	sc := span.SpanContext()
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the remote trace id, got %s", sc.TraceID)
+	}
+	if span.data.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the remote parent, got %s", span.data.ParentSpanID)
+	}
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + sc.SpanID.String() + "-01"
+	if outgoing.Get(TraceparentHeader) != expected {
+		t.Errorf("Expected %q, got %q", expected, outgoing.Get(TraceparentHeader))
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		_, ok := ParseTraceparent(test.header)
+		if ok != test.expected {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.header, ok)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+WriterExporter writes the spans as JSON lines, for local debugging.
+*/
+type WriterExporter struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+/*
+NewWriterExporter is a function that returns an exporter to w, eg. os.Stdout.
+*/
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+/*
+NewFileExporter is a function that returns an exporter appending to a file,
+closed on shutdown.
+*/
+func NewFileExporter(path string) (*WriterExporter, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WriterExporter{w: file, closer: file}, nil
+}
+
+type writerSpan struct {
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	TraceId       string         `json:"traceId"`
+	SpanId        string         `json:"spanId"`
+	ParentSpanId  string         `json:"parentSpanId,omitempty"`
+	Service       string         `json:"service"`
+	Start         time.Time      `json:"start"`
+	DurationMs    float64        `json:"durationMs"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Events        []writerEvent  `json:"events,omitempty"`
+	Status        string         `json:"status,omitempty"`
+	StatusMessage string         `json:"statusMessage,omitempty"`
+}
+
+type writerEvent struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+/*
+Export is a function that writes one line per span.
+*/
+func (e *WriterExporter) Export(ctx context.Context, serviceName string, spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+
+	for _, span := range spans {
+		line := writerSpan{
+			Name:          span.Name,
+			Kind:          span.Kind.String(),
+			TraceId:       span.TraceID.String(),
+			SpanId:        span.SpanID.String(),
+			Service:       serviceName,
+			Start:         span.Start.UTC(),
+			DurationMs:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Attributes:    attributeMap(span.Attributes),
+			StatusMessage: span.StatusMessage,
+		}
+
+		if span.ParentSpanID.IsValid() {
+			line.ParentSpanId = span.ParentSpanID.String()
+		}
+
+		switch span.Status {
+		case StatusOK:
+			line.Status = "ok"
+		case StatusError:
+			line.Status = "error"
+		}
+
+		for _, event := range span.Events {
+			line.Events = append(line.Events, writerEvent{
+				Name:       event.Name,
+				Time:       event.Time.UTC(),
+				Attributes: attributeMap(event.Attributes),
+			})
+		}
+
+		err := encoder.Encode(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Shutdown is a function that closes the file of a file exporter.
+*/
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closer == nil {
+		return nil
+	}
+
+	err := e.closer.Close()
+	e.closer = nil
+	return err
+}
+
+func attributeMap(attributes []Attribute) map[string]any {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	result := map[string]any{}
+	for _, attribute := range attributes {
+		result[attribute.Key] = attribute.Value
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	r.RemoteAddr = "192.0.2.1:1234"
+	if ip := ClientIP(r); ip != "192.0.2.1" {
+		t.Errorf("Expected 192.0.2.1, got %s", ip)
+	}
+
+	r.RemoteAddr = "[2001:db8::1]:1234"
+	if ip := ClientIP(r); ip != "2001:db8::1" {
+		t.Errorf("Expected 2001:db8::1, got %s", ip)
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                                  "Firefox on Linux",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0":           "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36":                            "Chrome on Android",
+		"curl/8.5.0": "curl",
+		"":           "Unknown device",
+	}
+
+	for userAgent, expected := range tests {
+		if device := DescribeDevice(userAgent); device != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, userAgent, device)
+		}
+	}
+}
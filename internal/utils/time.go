@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"time"
 )
 
@@ -13,3 +14,23 @@ func ConvertToTime(date string) (time.Time, error) {
 
 	return t, nil
 }
+
+/*
+GetPeriodBounds returns the start and the exclusive end of the "month" or
+"quarter" containing date, in UTC.
+*/
+func GetPeriodBounds(period string, date time.Time) (time.Time, time.Time, error) {
+	date = date.UTC()
+
+	switch period {
+	case "month":
+		from := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, 0), nil
+	case "quarter":
+		month := date.Month() - (date.Month()-1)%3
+		from := time.Date(date.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 3, 0), nil
+	}
+
+	return time.Time{}, time.Time{}, errors.New("Unknown period")
+}
@@ -18,3 +18,34 @@ func TestConvertToTime(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, converted)
 	}
 }
+
+func TestGetPeriodBounds(t *testing.T) {
+	date := time.Date(2024, 5, 17, 10, 0, 0, 0, time.UTC)
+
+	from, to, err := GetPeriodBounds("month", date)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expectedFrom := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	expectedTo := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if from != expectedFrom || to != expectedTo {
+		t.Errorf("Expected %v - %v, got %v - %v", expectedFrom, expectedTo, from, to)
+	}
+
+	from, to, err = GetPeriodBounds("quarter", date)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expectedFrom = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	expectedTo = time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	if from != expectedFrom || to != expectedTo {
+		t.Errorf("Expected %v - %v, got %v - %v", expectedFrom, expectedTo, from, to)
+	}
+
+	_, _, err = GetPeriodBounds("decade", date)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
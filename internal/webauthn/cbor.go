@@ -0,0 +1,170 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+maxDepth is the deepest nesting of arrays and maps decoded,
+the WebAuthn structures are only a few levels deep.
+*/
+const maxDepth = 16
+
+var errCBOR = errors.New("Invalid CBOR")
+
+/*
+decodeCBOR decodes the first CBOR item of b, and returns it with the number
+of bytes read. It only knows what WebAuthn uses: integers (int64), byte
+strings ([]byte), text strings (string), arrays ([]any), maps (map[any]any)
+and the simple values false, true and null.
+*/
+func decodeCBOR(b []byte) (any, int, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: too deep", errCBOR)
+	}
+
+	if len(b) == 0 {
+		return nil, 0, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+
+	// the simple values have no argument
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22:
+			return nil, 1, nil
+		}
+		return nil, 0, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+	}
+
+	arg, n, err := decodeArgument(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, 0, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return int64(arg), n, nil
+
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, 0, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return -1 - int64(arg), n, nil
+
+	case 2, 3:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, fmt.Errorf("%w: string longer than the data", errCBOR)
+		}
+		end := n + int(arg)
+		if major == 2 {
+			return append([]byte{}, b[n:end]...), end, nil
+		}
+		return string(b[n:end]), end, nil
+
+	case 4:
+		// every item is at least one byte
+		if arg > uint64(len(b)-n) {
+			return nil, 0, fmt.Errorf("%w: array longer than the data", errCBOR)
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, read, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += read
+		}
+		return items, n, nil
+
+	case 5:
+		if arg > uint64(len(b)-n)/2 {
+			return nil, 0, fmt.Errorf("%w: map longer than the data", errCBOR)
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, read, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += read
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, fmt.Errorf("%w: unsupported map key", errCBOR)
+			}
+
+			value, read, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += read
+
+			if _, found := items[key]; found {
+				return nil, 0, fmt.Errorf("%w: duplicate map key", errCBOR)
+			}
+			items[key] = value
+		}
+		return items, n, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+}
+
+/*
+decodeArgument returns the argument of the head of an item (a value,
+a length or a count), and the length of the head.
+*/
+func decodeArgument(b []byte) (uint64, int, error) {
+	info := b[0] & 0x1f
+
+	if info < 24 {
+		return uint64(info), 1, nil
+	}
+
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		// indefinite lengths are not used by WebAuthn
+		return 0, 0, fmt.Errorf("%w: unsupported length", errCBOR)
+	}
+
+	if len(b) < 1+size {
+		return 0, 0, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[1]), 2, nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b[1:])), 3, nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b[1:])), 5, nil
+	}
+	return binary.BigEndian.Uint64(b[1:]), 9, nil
+}
@@ -0,0 +1,141 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+COSE algorithms of the public keys, the ones in the registration options.
+*/
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+/*
+ErrUnsupportedKey is returned for public keys of other types or algorithms.
+*/
+var ErrUnsupportedKey = errors.New("Unsupported public key")
+
+/*
+COSE key parameters, RFC 9053.
+*/
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+/*
+publicKey is a parsed COSE key with its algorithm.
+*/
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+/*
+parsePublicKey parses a COSE_Key of ES256, EdDSA (Ed25519) or RS256.
+*/
+func parsePublicKey(cose []byte) (*publicKey, error) {
+	decoded, n, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(cose) {
+		return nil, fmt.Errorf("%w: trailing data", errCBOR)
+	}
+
+	params, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: key}, nil
+
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := params[int64(coseN)].([]byte)
+		e, _ := params[int64(coseE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+		return &publicKey{alg: alg, key: key}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+/*
+verify checks the signature of the message with the key.
+*/
+func (k *publicKey) verify(message, signature []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, signature)
+
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	return false
+}
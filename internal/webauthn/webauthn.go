@@ -0,0 +1,434 @@
+/*
+Package webauthn registers passkeys and verifies the signins with them
+(WebAuthn level 2). It asks for no attestation, so the attestation statement
+is not checked: a passkey is trusted as the key the user registered, not as
+a device of a known vendor. The keys can be ES256, EdDSA or RS256.
+*/
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Timeout is how long the browser waits for the authenticator.
+*/
+const Timeout = 2 * time.Minute
+
+/*
+challengeSize is the length of the random challenges in bytes.
+*/
+const challengeSize = 32
+
+/*
+Errors of the verification, the responses are rejected with them.
+*/
+var (
+	ErrClientData     = errors.New("Invalid client data")
+	ErrChallenge      = errors.New("Challenge does not match")
+	ErrOrigin         = errors.New("Origin does not match")
+	ErrAuthData       = errors.New("Invalid authenticator data")
+	ErrRelyingParty   = errors.New("Relying party does not match")
+	ErrNotVerified    = errors.New("User was not present or not verified")
+	ErrSignature      = errors.New("Invalid signature")
+	ErrSignCount      = errors.New("Sign count did not increase, the authenticator may be cloned")
+	ErrAttestation    = errors.New("Invalid attestation object")
+	ErrNoCredentialId = errors.New("Credential id is missing")
+)
+
+/*
+Flags of the authenticator data.
+*/
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+/*
+RelyingParty is the site the passkeys belong to. ID is its domain,
+Origin is the URL the pages are served from, eg. https://pengoe.com.
+*/
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+/*
+User is the account a passkey is registered to. ID is stored on the
+authenticator, and comes back as the user handle at signin.
+*/
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+/*
+Credential is a registered passkey. PublicKey is the COSE key.
+*/
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+/*
+CreationOptions are the options of navigator.credentials.create,
+with the binary values in base64url.
+*/
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+/*
+RequestOptions are the options of navigator.credentials.get,
+with the binary values in base64url. There are no allowed credentials,
+the authenticator offers the passkeys it has for the site.
+*/
+type RequestOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	Timeout          int64  `json:"timeout"`
+	UserVerification string `json:"userVerification"`
+}
+
+/*
+NewChallenge returns a random challenge for a registration or a signin.
+*/
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+/*
+CreationOptions returns the options to register a passkey for the user.
+The passkeys the user already has are excluded, so an authenticator
+is not registered twice.
+*/
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte) CreationOptions {
+	excluded := []credentialDescriptor{}
+	for _, id := range exclude {
+		excluded = append(excluded, credentialDescriptor{Type: "public-key", ID: Encode(id)})
+	}
+
+	return CreationOptions{
+		Challenge: Encode(challenge),
+		RP:        relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User: userEntity{
+			ID:          Encode(user.ID),
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+		},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout.Milliseconds(),
+		ExcludeCredentials: excluded,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+}
+
+/*
+RequestOptions returns the options to sign in with a passkey.
+*/
+func (rp *RelyingParty) RequestOptions(challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        Encode(challenge),
+		RPID:             rp.ID,
+		Timeout:          Timeout.Milliseconds(),
+		UserVerification: "required",
+	}
+}
+
+/*
+clientData is the clientDataJSON of a response.
+*/
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func parseClientData(raw []byte) (*clientData, error) {
+	data := &clientData{}
+	err := json.Unmarshal(raw, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrClientData, err)
+	}
+	return data, nil
+}
+
+/*
+Challenge returns the challenge of a response, to look up the ceremony
+it belongs to. The response is not verified yet.
+*/
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	data, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := Decode(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("%w: invalid challenge", ErrClientData)
+	}
+
+	return challenge, nil
+}
+
+/*
+verifyClientData checks the type, the challenge and the origin.
+*/
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	data, err := parseClientData(raw)
+	if err != nil {
+		return err
+	}
+
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: type %q", ErrClientData, data.Type)
+	}
+
+	received, err := Decode(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return ErrChallenge
+	}
+
+	if data.Origin != rp.Origin {
+		return fmt.Errorf("%w: %q", ErrOrigin, data.Origin)
+	}
+
+	return nil
+}
+
+/*
+authenticatorData is the parsed authenticator data.
+The credential is only in the data of a registration.
+*/
+type authenticatorData struct {
+	rpIdHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialId []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: too short", ErrAuthData)
+	}
+
+	data := &authenticatorData{
+		rpIdHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if data.flags&flagAttested == 0 {
+		return data, nil
+	}
+
+	// aaguid (16), credential id length (2), credential id, public key
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: credential data too short", ErrAuthData)
+	}
+
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || len(rest) < idLength {
+		return nil, fmt.Errorf("%w: invalid credential id", ErrAuthData)
+	}
+
+	data.credentialId = append([]byte{}, rest[:idLength]...)
+	rest = rest[idLength:]
+
+	// the key is followed by the extensions, if any
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthData, err)
+	}
+	data.publicKey = append([]byte{}, rest[:n]...)
+
+	return data, nil
+}
+
+/*
+verifyAuthenticatorData checks the relying party, and that the user was
+present and verified (with a PIN or biometrics).
+*/
+func (rp *RelyingParty) verifyAuthenticatorData(data *authenticatorData) error {
+	rpIdHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.rpIdHash, rpIdHash[:]) {
+		return ErrRelyingParty
+	}
+
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return ErrNotVerified
+	}
+
+	return nil
+}
+
+/*
+VerifyRegistration verifies the response of navigator.credentials.create
+for the challenge, and returns the new credential.
+*/
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAttestation, err)
+	}
+
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, ErrAttestation
+	}
+
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrAttestation
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.credentialId == nil {
+		return nil, ErrNoCredentialId
+	}
+
+	_, err = parsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        authData.credentialId,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+/*
+VerifyAssertion verifies the response of navigator.credentials.get for the
+challenge with the stored credential, and returns the new sign count.
+Authenticators without a counter always send 0, the others must count up.
+*/
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, credential *Credential, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	err = rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+
+	if !key.verify(message, signature) {
+		return 0, ErrSignature
+	}
+
+	counting := authData.signCount != 0 || credential.SignCount != 0
+	if counting && authData.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.signCount, nil
+}
+
+/*
+Encode encodes binary values for the browser, in base64url without padding.
+*/
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+/*
+Decode decodes base64url, with or without padding.
+*/
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,350 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+)
+
+var rp = &RelyingParty{
+	ID:     "localhost",
+	Name:   "pengoe",
+	Origin: "http://localhost:8080",
+}
+
+/*
+encodeCBOR encodes the values the authenticator sends: integers, strings,
+byte strings and maps, with the keys in a stable order.
+*/
+func encodeCBOR(value any) []byte {
+	head := func(major byte, arg uint64) []byte {
+		switch {
+		case arg < 24:
+			return []byte{major<<5 | byte(arg)}
+		case arg < 1<<8:
+			return []byte{major<<5 | 24, byte(arg)}
+		case arg < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+		}
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case map[any]any:
+		keys := [][]byte{}
+		for key := range v {
+			keys = append(keys, append(encodeCBOR(key), encodeCBOR(v[key])...))
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		return append(head(5, uint64(len(v))), bytes.Join(keys, nil)...)
+	}
+	panic("unsupported value")
+}
+
+/*
+authenticator is a software authenticator with one ES256 passkey.
+*/
+type authenticator struct {
+	rpId         string
+	origin       string
+	flags        byte
+	credentialId []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+	noCounter    bool
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+
+	return &authenticator{
+		rpId:         rp.ID,
+		origin:       rp.Origin,
+		flags:        flagUserPresent | flagUserVerified,
+		credentialId: credentialId,
+		key:          key,
+	}
+}
+
+func (a *authenticator) publicKey() []byte {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+
+	return encodeCBOR(map[any]any{
+		coseKty: ktyEC2,
+		coseAlg: AlgES256,
+		coseCrv: crvP256,
+		coseX:   x,
+		coseY:   y,
+	})
+}
+
+func (a *authenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   Encode(challenge),
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	return data
+}
+
+func (a *authenticator) authData(flags byte, credential []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if credential != nil {
+		data = append(data, make([]byte, 16)...) // aaguid
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialId)))
+		data = append(data, a.credentialId...)
+		data = append(data, credential...)
+	}
+
+	return data
+}
+
+/*
+create answers navigator.credentials.create.
+*/
+func (a *authenticator) create(challenge []byte) (clientDataJSON, attestationObject []byte) {
+	clientDataJSON = a.clientData("webauthn.create", challenge)
+	attestationObject = encodeCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(a.flags|flagAttested, a.publicKey()),
+	})
+	return clientDataJSON, attestationObject
+}
+
+/*
+get answers navigator.credentials.get, counting the signature if it counts.
+*/
+func (a *authenticator) get(t *testing.T, challenge []byte) (clientDataJSON, authenticatorData, signature []byte) {
+	if !a.noCounter {
+		a.signCount++
+	}
+
+	clientDataJSON = a.clientData("webauthn.get", challenge)
+	authenticatorData = a.authData(a.flags, nil)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return clientDataJSON, authenticatorData, signature
+}
+
+func register(t *testing.T, a *authenticator) *Credential {
+	challenge, _ := NewChallenge()
+	clientDataJSON, attestationObject := a.create(challenge)
+
+	credential, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	a := newAuthenticator(t)
+
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	clientDataJSON, attestationObject := a.create(challenge)
+
+	received, err := Challenge(clientDataJSON)
+	if err != nil || !bytes.Equal(received, challenge) {
+		t.Errorf("Expected the challenge of the response, got %v, %v", received, err)
+	}
+
+	credential, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !bytes.Equal(credential.ID, a.credentialId) {
+		t.Errorf("Expected credential id %x, got %x", a.credentialId, credential.ID)
+	}
+
+	if !bytes.Equal(credential.PublicKey, a.publicKey()) {
+		t.Errorf("Expected the public key of the authenticator")
+	}
+}
+
+func TestRegistrationInvalid(t *testing.T) {
+	challenge, _ := NewChallenge()
+	other, _ := NewChallenge()
+
+	tests := []struct {
+		name     string
+		change   func(a *authenticator)
+		expected error
+	}{
+		{"other origin", func(a *authenticator) { a.origin = "https://evil.com" }, ErrOrigin},
+		{"other relying party", func(a *authenticator) { a.rpId = "evil.com" }, ErrRelyingParty},
+		{"not verified", func(a *authenticator) { a.flags = flagUserPresent }, ErrNotVerified},
+	}
+
+	for _, test := range tests {
+		a := newAuthenticator(t)
+		test.change(a)
+
+		clientDataJSON, attestationObject := a.create(challenge)
+
+		_, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+
+	a := newAuthenticator(t)
+	clientDataJSON, attestationObject := a.create(other)
+
+	_, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if !errors.Is(err, ErrChallenge) {
+		t.Errorf("Expected %v, got %v", ErrChallenge, err)
+	}
+
+	// a signin response is not a registration
+	clientDataJSON, _, _ = a.get(t, challenge)
+
+	_, err = rp.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if !errors.Is(err, ErrClientData) {
+		t.Errorf("Expected %v, got %v", ErrClientData, err)
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	credential := register(t, a)
+
+	for i := 1; i <= 2; i++ {
+		challenge, _ := NewChallenge()
+		clientDataJSON, authenticatorData, signature := a.get(t, challenge)
+
+		signCount, err := rp.VerifyAssertion(challenge, credential, clientDataJSON, authenticatorData, signature)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if signCount != uint32(i) {
+			t.Errorf("Expected sign count %d, got %d", i, signCount)
+		}
+
+		credential.SignCount = signCount
+	}
+}
+
+func TestAssertionInvalid(t *testing.T) {
+	a := newAuthenticator(t)
+	credential := register(t, a)
+
+	challenge, _ := NewChallenge()
+	clientDataJSON, authenticatorData, signature := a.get(t, challenge)
+
+	// signed by another key
+	other := newAuthenticator(t)
+	_, err := rp.VerifyAssertion(challenge, &Credential{PublicKey: other.publicKey()}, clientDataJSON, authenticatorData, signature)
+	if !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected %v, got %v", ErrSignature, err)
+	}
+
+	// changed after signing
+	tampered := append([]byte{}, authenticatorData...)
+	tampered[len(tampered)-1]++
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, tampered, signature)
+	if !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected %v, got %v", ErrSignature, err)
+	}
+
+	// a clone is behind the counter of the original
+	credential.SignCount = 5
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authenticatorData, signature)
+	if !errors.Is(err, ErrSignCount) {
+		t.Errorf("Expected %v, got %v", ErrSignCount, err)
+	}
+
+	// without a counter
+	a.noCounter = true
+	a.signCount = 0
+	credential.SignCount = 0
+	clientDataJSON, authenticatorData, signature = a.get(t, challenge)
+	_, err = rp.VerifyAssertion(challenge, credential, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		t.Errorf("Expected no error without a counter, got %v", err)
+	}
+}
+
+func TestEdDSAKey(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	key, err := parsePublicKey(encodeCBOR(map[any]any{
+		coseKty: ktyOKP,
+		coseAlg: AlgEdDSA,
+		coseCrv: crvEd25519,
+		coseX:   []byte(public),
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	message := []byte("message")
+	if !key.verify(message, ed25519.Sign(private, message)) {
+		t.Errorf("Expected a valid signature")
+	}
+}
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	invalid := [][]byte{
+		{},
+		{0x5a, 0xff, 0xff, 0xff, 0xff}, // byte string longer than the data
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // huge array
+		{0xa2, 0x01, 0x01, 0x01, 0x02},                         // duplicate key
+		{0x9f},                                                 // indefinite length
+		bytes.Repeat([]byte{0x81}, 100),                        // too deep
+	}
+
+	for _, b := range invalid {
+		_, _, err := decodeCBOR(b)
+		if err == nil {
+			t.Errorf("Expected error for %x", b)
+		}
+	}
+}
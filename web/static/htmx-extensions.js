@@ -136,3 +136,27 @@ function toggleFocus(element) {
     element.focus();
   }
 }
+
+/**
+ * CSRF token of every htmx request, from the #csrf input
+ * the server checks it in the CSRF middleware
+ * */
+document.addEventListener("htmx:configRequest", function(evt) {
+  const csrf = document.getElementById("csrf");
+  if (csrf) {
+    evt.detail.headers["X-CSRF-Token"] = csrf.value;
+  }
+});
+
+/**
+ * the next CSRF token, if the server rotates it on every request
+ * (an expired token is swapped into #csrf instead, then the element
+ * sends the request again on "csrf-renewed")
+ * */
+document.addEventListener("htmx:afterRequest", function(evt) {
+  const next = evt?.detail?.xhr?.getResponseHeader("X-CSRF-Token");
+  const csrf = document.getElementById("csrf");
+  if (next && csrf) {
+    csrf.value = next;
+  }
+});
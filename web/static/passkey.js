@@ -0,0 +1,131 @@
+/**
+ * Passkeys (WebAuthn) for the signin and the settings
+ * <form hx-trigger="passkey-ready" onsubmit="event.preventDefault(); getPasskey(this)">
+ * the form gets the response of the authenticator in its "credential" input,
+ * then "passkey-ready" sends it with htmx
+ * */
+
+/**
+ * Encode binary values for the server
+ * @param {ArrayBuffer} buffer - binary value
+ * @returns {string} base64url without padding
+ */
+function toBase64url(buffer) {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  bytes.forEach((b) => {
+    binary += String.fromCharCode(b);
+  });
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+/**
+ * Decode binary values from the server
+ * @param {string} value - base64url
+ * @returns {Uint8Array} binary value
+ */
+function fromBase64url(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const binary = atob(base64.padEnd(base64.length + (4 - (base64.length % 4)) % 4, "="));
+  return Uint8Array.from(binary, (c) => c.charCodeAt(0));
+}
+
+/**
+ * Get the options of a ceremony, with a new challenge
+ * @param {string} url - options endpoint
+ */
+async function passkeyOptions(url) {
+  const response = await fetch(url, { credentials: "same-origin" });
+  if (!response.ok) {
+    throw new Error("Could not start, reload the page");
+  }
+  return response.json();
+}
+
+/**
+ * Show an error in the ".passkey-error" element of the form
+ * @param {HTMLFormElement} form - passkey form
+ * @param {Error} err - error of the ceremony
+ */
+function showPasskeyError(form, err) {
+  const message =
+    err?.name === "NotAllowedError"
+      ? "Cancelled or timed out"
+      : err?.message || "Something went wrong";
+  form.querySelector(".passkey-error").textContent = message;
+}
+
+/**
+ * Check if the browser has passkeys, show an error if not
+ * @param {HTMLFormElement} form - passkey form
+ */
+function hasPasskeys(form) {
+  if (window.PublicKeyCredential) {
+    return true;
+  }
+  showPasskeyError(form, new Error("This browser does not support passkeys"));
+  return false;
+}
+
+/**
+ * Register a new passkey, on the settings page
+ * @param {HTMLFormElement} form - form with "credential" and "name" inputs
+ */
+async function createPasskey(form) {
+  if (!hasPasskeys(form)) {
+    return;
+  }
+
+  try {
+    const options = await passkeyOptions("/settings/passkeys/options");
+    options.challenge = fromBase64url(options.challenge);
+    options.user.id = fromBase64url(options.user.id);
+    options.excludeCredentials = options.excludeCredentials.map((credential) => ({
+      ...credential,
+      id: fromBase64url(credential.id),
+    }));
+
+    const credential = await navigator.credentials.create({ publicKey: options });
+
+    form.querySelector("[name=credential]").value = JSON.stringify({
+      id: credential.id,
+      clientDataJSON: toBase64url(credential.response.clientDataJSON),
+      attestationObject: toBase64url(credential.response.attestationObject),
+    });
+
+    htmx.trigger(form, "passkey-ready");
+  } catch (err) {
+    showPasskeyError(form, err);
+  }
+}
+
+/**
+ * Sign in with a passkey, on the signin page
+ * @param {HTMLFormElement} form - form with a "credential" input
+ */
+async function getPasskey(form) {
+  if (!hasPasskeys(form)) {
+    return;
+  }
+
+  try {
+    const options = await passkeyOptions("/signin/passkey/options");
+    options.challenge = fromBase64url(options.challenge);
+
+    const credential = await navigator.credentials.get({ publicKey: options });
+
+    form.querySelector("[name=credential]").value = JSON.stringify({
+      id: credential.id,
+      clientDataJSON: toBase64url(credential.response.clientDataJSON),
+      authenticatorData: toBase64url(credential.response.authenticatorData),
+      signature: toBase64url(credential.response.signature),
+      userHandle: credential.response.userHandle
+        ? toBase64url(credential.response.userHandle)
+        : "",
+    });
+
+    htmx.trigger(form, "passkey-ready");
+  } catch (err) {
+    showPasskeyError(form, err);
+  }
+}
@@ -1,6 +1,6 @@
 // Code generated by templ - DO NOT EDIT.
 
-// templ: version: 0.2.476
+// templ: version: v0.2.543
 package components
 
 //lint:file-ignore SA4006 This context is only used if a nested component is present.
@@ -31,7 +31,7 @@ func NewEventCard(props NewEventCardProps) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = EventCard(props.EventCardProps).Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = NewEventFormButton().Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -39,7 +39,7 @@ func NewEventCard(props NewEventCardProps) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = NewEventFormButton().Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = EventCard(props.EventCardProps).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -1,6 +1,6 @@
 // Code generated by templ - DO NOT EDIT.
 
-// templ: version: 0.2.476
+// templ: version: v0.2.543
 package components
 
 //lint:file-ignore SA4006 This context is only used if a nested component is present.
@@ -31,34 +31,7 @@ func Topbar(props TopbarProps) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex w-full items-start justify-between\"><!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var2 := ` menu button `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var2)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><div class=\"p-2\"><button class=\"p-2 text-2xl\" aria-label=\"Open menu\" hx-on:click=\"emit(&#39;menu-open&#39;)\" tabindex=\"0\" hx-ext=\"receiver\" on-event:menu-open=\"\n					toggleTabIndex(this);\n					this.blur();\n				\" on-event:menu-close=\"\n					toggleTabIndex(this);\n					this.focus();\n				\"><!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var3 := ` menu right icon `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var3)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><svg stroke=\"currentColor\" fill=\"currentColor\" stroke-width=\"0\" viewBox=\"0 0 24 24\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M21 17.9995V19.9995H3V17.9995H21ZM17.4038 3.90332L22 8.49951L17.4038 13.0957L15.9896 11.6815L19.1716 8.49951L15.9896 5.31753L17.4038 3.90332ZM12 10.9995V12.9995H3V10.9995H12ZM12 3.99951V5.99951H3V3.99951H12Z\"></path></svg></button></div><!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var4 := ` account selector `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var4)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("-->")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex w-full items-start justify-between\"><!-- menu button --><div class=\"p-2\"><button class=\"p-2 text-2xl\" aria-label=\"Open menu\" hx-on:click=\"emit(&#39;menu-open&#39;)\" tabindex=\"0\" hx-ext=\"receiver\" on-event:menu-open=\"\n					toggleTabIndex(this);\n					this.blur();\n				\" on-event:menu-close=\"\n					toggleTabIndex(this);\n					this.focus();\n				\"><!-- menu right icon --><svg stroke=\"currentColor\" fill=\"currentColor\" stroke-width=\"0\" viewBox=\"0 0 24 24\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M21 17.9995V19.9995H3V17.9995H21ZM17.4038 3.90332L22 8.49951L17.4038 13.0957L15.9896 11.6815L19.1716 8.49951L15.9896 5.31753L17.4038 3.90332ZM12 10.9995V12.9995H3V10.9995H12ZM12 3.99951V5.99951H3V3.99951H12Z\"></path></svg></button></div><!-- account selector -->")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -68,16 +41,7 @@ func Topbar(props TopbarProps) templ.Component {
 				return templ_7745c5c3_Err
 			}
 			if props.SelectedAccountId == "" {
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div>")
-				if templ_7745c5c3_Err != nil {
-					return templ_7745c5c3_Err
-				}
-				templ_7745c5c3_Var5 := `Accounts`
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var5)
-				if templ_7745c5c3_Err != nil {
-					return templ_7745c5c3_Err
-				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</div>")
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div>Accounts</div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -88,8 +52,12 @@ func Topbar(props TopbarProps) templ.Component {
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
-						var templ_7745c5c3_Var6 string = account.Name
-						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+						var templ_7745c5c3_Var2 string
+						templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(account.Name)
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/components/topbar.templ`, Line: 62, Col: 27}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
@@ -100,25 +68,7 @@ func Topbar(props TopbarProps) templ.Component {
 					}
 				}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!--")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Var7 := ` chevron down icon `
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var7)
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><div class=\"text-xs\" hx-ext=\"receiver\" on-event:accounts-toggle=\"\n							this.classList.toggle(&#39;text-accent&#39;);\n						\"><svg stroke=\"currentColor\" fill=\"currentColor\" stroke-width=\"0\" viewBox=\"0 0 448 512\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M207.029 381.476L12.686 187.132c-9.373-9.373-9.373-24.569 0-33.941l22.667-22.667c9.357-9.357 24.522-9.375 33.901-.04L224 284.505l154.745-154.021c9.379-9.335 24.544-9.317 33.901.04l22.667 22.667c9.373 9.373 9.373 24.569 0 33.941L240.971 381.476c-9.373 9.372-24.569 9.372-33.942 0z\"></path></svg></div></summary><!--")
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Var8 := ` account selector dropdown `
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var8)
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><div class=\"border-primary absolute top-14 w-fit rounded-lg border bg-white opacity-0 shadow-lg shadow-gray-100 transition-opacity duration-200 left-1/2 transform -translate-x-1/2\" hx-ext=\"receiver\" on-event:accounts-toggle=\"\n					this.classList.toggle(&#39;opacity-0&#39;);\n					this.classList.toggle(&#39;opacity-100&#39;);\n					this.querySelectorAll(&#39;button&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n					this.querySelectorAll(&#39;a&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n				\"><ul>")
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- chevron down icon --><div class=\"text-xs\" hx-ext=\"receiver\" on-event:accounts-toggle=\"\n							this.classList.toggle(&#39;text-accent&#39;);\n						\"><svg stroke=\"currentColor\" fill=\"currentColor\" stroke-width=\"0\" viewBox=\"0 0 448 512\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M207.029 381.476L12.686 187.132c-9.373-9.373-9.373-24.569 0-33.941l22.667-22.667c9.357-9.357 24.522-9.375 33.901-.04L224 284.505l154.745-154.021c9.379-9.335 24.544-9.317 33.901.04l22.667 22.667c9.373 9.373 9.373 24.569 0 33.941L240.971 381.476c-9.373 9.372-24.569 9.372-33.942 0z\"></path></svg></div></summary><!-- account selector dropdown --><div class=\"border-primary absolute top-14 w-fit rounded-lg border bg-white opacity-0 shadow-lg shadow-gray-100 transition-opacity duration-200 left-1/2 transform -translate-x-1/2\" hx-ext=\"receiver\" on-event:accounts-toggle=\"\n					this.classList.toggle(&#39;opacity-0&#39;);\n					this.classList.toggle(&#39;opacity-100&#39;);\n					this.querySelectorAll(&#39;button&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n					this.querySelectorAll(&#39;a&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n				\"><ul>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -158,43 +108,7 @@ func Topbar(props TopbarProps) templ.Component {
 				}
 			}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var9 := ` profile `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var9)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><details class=\"p-2\"><summary class=\"text-2xl rounded-lg list-none cursor-pointer\" onclick=\"emit(&#39;profile-toggle&#39;)\" aria-label=\"Profile\"><!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var10 := ` profile pic icon `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var10)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><div hx-ext=\"receiver\" class=\"rounded-lg p-2\" on-event:profile-toggle=\"\n						this.classList.toggle(&#39;bg-primary&#39;);\n						this.classList.toggle(&#39;text-accent&#39;);\n					\"><svg stroke=\"currentColor\" fill=\"none\" stroke-width=\"0\" viewBox=\"0 0 15 15\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path fill-rule=\"evenodd\" clip-rule=\"evenodd\" d=\"M0.877014 7.49988C0.877014 3.84219 3.84216 0.877045 7.49985 0.877045C11.1575 0.877045 14.1227 3.84219 14.1227 7.49988C14.1227 11.1575 11.1575 14.1227 7.49985 14.1227C3.84216 14.1227 0.877014 11.1575 0.877014 7.49988ZM7.49985 1.82704C4.36683 1.82704 1.82701 4.36686 1.82701 7.49988C1.82701 8.97196 2.38774 10.3131 3.30727 11.3213C4.19074 9.94119 5.73818 9.02499 7.50023 9.02499C9.26206 9.02499 10.8093 9.94097 11.6929 11.3208C12.6121 10.3127 13.1727 8.97172 13.1727 7.49988C13.1727 4.36686 10.6328 1.82704 7.49985 1.82704ZM10.9818 11.9787C10.2839 10.7795 8.9857 9.97499 7.50023 9.97499C6.01458 9.97499 4.71624 10.7797 4.01845 11.9791C4.97952 12.7272 6.18765 13.1727 7.49985 13.1727C8.81227 13.1727 10.0206 12.727 10.9818 11.9787ZM5.14999 6.50487C5.14999 5.207 6.20212 4.15487 7.49999 4.15487C8.79786 4.15487 9.84999 5.207 9.84999 6.50487C9.84999 7.80274 8.79786 8.85487 7.49999 8.85487C6.20212 8.85487 5.14999 7.80274 5.14999 6.50487ZM7.49999 5.10487C6.72679 5.10487 6.09999 5.73167 6.09999 6.50487C6.09999 7.27807 6.72679 7.90487 7.49999 7.90487C8.27319 7.90487 8.89999 7.27807 8.89999 6.50487C8.89999 5.73167 8.27319 5.10487 7.49999 5.10487Z\" fill=\"currentColor\"></path></svg></div></summary><!--")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var11 := ` profile dropdown `
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var11)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("--><div class=\"border-primary absolute right-2 top-14 w-fit rounded-lg border bg-white opacity-0 shadow-lg shadow-gray-100 transition-opacity duration-200\" hx-ext=\"receiver\" on-event:profile-toggle=\"\n					this.classList.toggle(&#39;opacity-0&#39;);\n					this.classList.toggle(&#39;opacity-100&#39;);\n					this.querySelectorAll(&#39;button&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n				\"><button aria-label=\"Signout\" class=\"rounded-inner hover:bg-primary focus:bg-primary hover:text-text focus:text-text group flex w-full items-center justify-between gap-2 p-2\" hx-post=\"/signout\" hx-swap=\"outerHTML\" hx-target=\"body\" hx-push-url=\"true\" tabindex=\"-1\">")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Var12 := `Signout`
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ_7745c5c3_Var12)
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</button></div></details></div>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- profile --><details class=\"p-2\"><summary class=\"text-2xl rounded-lg list-none cursor-pointer\" onclick=\"emit(&#39;profile-toggle&#39;)\" aria-label=\"Profile\"><!-- profile pic icon --><div hx-ext=\"receiver\" class=\"rounded-lg p-2\" on-event:profile-toggle=\"\n						this.classList.toggle(&#39;bg-primary&#39;);\n						this.classList.toggle(&#39;text-accent&#39;);\n					\"><svg stroke=\"currentColor\" fill=\"none\" stroke-width=\"0\" viewBox=\"0 0 15 15\" height=\"1em\" width=\"1em\" xmlns=\"http://www.w3.org/2000/svg\"><path fill-rule=\"evenodd\" clip-rule=\"evenodd\" d=\"M0.877014 7.49988C0.877014 3.84219 3.84216 0.877045 7.49985 0.877045C11.1575 0.877045 14.1227 3.84219 14.1227 7.49988C14.1227 11.1575 11.1575 14.1227 7.49985 14.1227C3.84216 14.1227 0.877014 11.1575 0.877014 7.49988ZM7.49985 1.82704C4.36683 1.82704 1.82701 4.36686 1.82701 7.49988C1.82701 8.97196 2.38774 10.3131 3.30727 11.3213C4.19074 9.94119 5.73818 9.02499 7.50023 9.02499C9.26206 9.02499 10.8093 9.94097 11.6929 11.3208C12.6121 10.3127 13.1727 8.97172 13.1727 7.49988C13.1727 4.36686 10.6328 1.82704 7.49985 1.82704ZM10.9818 11.9787C10.2839 10.7795 8.9857 9.97499 7.50023 9.97499C6.01458 9.97499 4.71624 10.7797 4.01845 11.9791C4.97952 12.7272 6.18765 13.1727 7.49985 13.1727C8.81227 13.1727 10.0206 12.727 10.9818 11.9787ZM5.14999 6.50487C5.14999 5.207 6.20212 4.15487 7.49999 4.15487C8.79786 4.15487 9.84999 5.207 9.84999 6.50487C9.84999 7.80274 8.79786 8.85487 7.49999 8.85487C6.20212 8.85487 5.14999 7.80274 5.14999 6.50487ZM7.49999 5.10487C6.72679 5.10487 6.09999 5.73167 6.09999 6.50487C6.09999 7.27807 6.72679 7.90487 7.49999 7.90487C8.27319 7.90487 8.89999 7.27807 8.89999 6.50487C8.89999 5.73167 8.27319 5.10487 7.49999 5.10487Z\" fill=\"currentColor\"></path></svg></div></summary><!-- profile dropdown --><div class=\"border-primary absolute right-2 top-14 w-fit rounded-lg border bg-white opacity-0 shadow-lg shadow-gray-100 transition-opacity duration-200\" hx-ext=\"receiver\" on-event:profile-toggle=\"\n					this.classList.toggle(&#39;opacity-0&#39;);\n					this.classList.toggle(&#39;opacity-100&#39;);\n					this.querySelectorAll(&#39;button&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n					this.querySelectorAll(&#39;a&#39;).forEach((el) =&gt; {\n						toggleTabIndex(el);\n					});\n				\"><a href=\"/settings/sessions\" class=\"rounded-inner hover:bg-primary focus:bg-primary hover:text-text focus:text-text group flex w-full items-center justify-between gap-2 p-2\" tabindex=\"-1\">Sessions</a> <a href=\"/settings/two-factor\" class=\"rounded-inner hover:bg-primary focus:bg-primary hover:text-text focus:text-text group flex w-full items-center justify-between gap-2 p-2\" tabindex=\"-1\">Two-factor</a> <a href=\"/settings/passkeys\" class=\"rounded-inner hover:bg-primary focus:bg-primary hover:text-text focus:text-text group flex w-full items-center justify-between gap-2 p-2\" tabindex=\"-1\">Passkeys</a> <button aria-label=\"Signout\" class=\"rounded-inner hover:bg-primary focus:bg-primary hover:text-text focus:text-text group flex w-full items-center justify-between gap-2 p-2\" hx-post=\"/signout\" hx-swap=\"outerHTML\" hx-target=\"body\" hx-push-url=\"true\" tabindex=\"-1\">Signout</button></div></details></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}